@@ -0,0 +1,142 @@
+/*
+ * Copyright 2020 Aletheia Ware LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package labgo
+
+import (
+	"crypto/rsa"
+	"errors"
+	"fmt"
+	"github.com/AletheiaWareLLC/aliasgo"
+	"github.com/AletheiaWareLLC/bcgo"
+	"github.com/AletheiaWareLLC/cryptogo"
+	"sort"
+)
+
+const (
+	ERROR_ACCESS_DENIED       = "Record not shared with %s"
+	ERROR_RECIPIENT_NOT_FOUND = "Could not find public key for recipient: %s"
+)
+
+// GetAccess returns the public keys of the given recipient aliases, registered through aliasgo, for encrypting records so only they can read them.
+// The node's own alias is always included so it can read what it writes. If there are no recipients the result is nil, and records are public.
+func GetAccess(node *Node, recipients []string) (map[string]*rsa.PublicKey, error) {
+	if len(recipients) == 0 {
+		return nil, nil
+	}
+	return getAccess(node, append([]string{node.Alias}, recipients...))
+}
+
+// getAccess returns the public keys of the given aliases.
+func getAccess(node *Node, aliases []string) (map[string]*rsa.PublicKey, error) {
+	acl := make(map[string]*rsa.PublicKey)
+	channel := getAliasChannel(node)
+	for _, alias := range aliases {
+		if _, ok := acl[alias]; ok {
+			continue
+		}
+		if alias == node.Alias {
+			acl[alias] = &node.Key.PublicKey
+			continue
+		}
+		key, err := aliasgo.GetPublicKey(channel, node.Cache, node.Network, alias)
+		if err != nil {
+			return nil, errors.New(fmt.Sprintf(ERROR_RECIPIENT_NOT_FOUND, alias))
+		}
+		acl[alias] = key
+	}
+	return acl, nil
+}
+
+// Recipients returns the sorted aliases in the access list.
+func Recipients(acl map[string]*rsa.PublicKey) []string {
+	var aliases []string
+	for alias := range acl {
+		aliases = append(aliases, alias)
+	}
+	sort.Strings(aliases)
+	return aliases
+}
+
+// getExperimentAccess returns the access list of the latest record in the channel, so later records are shared with the same recipients.
+// This is only used for private experiments created before membership was recorded.
+func getExperimentAccess(node *Node, channel *bcgo.Channel) (map[string]*rsa.PublicKey, error) {
+	if channel.Head == nil {
+		return nil, nil
+	}
+	block, err := bcgo.GetBlock(channel.Name, node.Cache, node.Network, channel.Head)
+	if err != nil {
+		return nil, err
+	}
+	if len(block.Entry) == 0 {
+		return nil, nil
+	}
+	var recipients []string
+	for _, a := range block.Entry[len(block.Entry)-1].Record.Access {
+		recipients = append(recipients, a.Alias)
+	}
+	return GetAccess(node, recipients)
+}
+
+// readPayload returns the payload of the entry, decrypted with the node's key if the record is encrypted.
+// The record is first verified according to the node's verification mode.
+func readPayload(node *Node, entry *bcgo.BlockEntry) ([]byte, error) {
+	if err := verifyEntry(node, entry); err != nil {
+		return nil, err
+	}
+	if len(entry.Record.Access) == 0 {
+		// Public record
+		return entry.Record.Payload, nil
+	}
+	return decryptPayload(node, entry)
+}
+
+// decryptPayload returns the payload of the encrypted record in the entry, decrypted with the node's key.
+func decryptPayload(node *Node, entry *bcgo.BlockEntry) ([]byte, error) {
+	record := entry.Record
+	key, err := recordSecretKey(node, entry)
+	if err != nil {
+		return nil, err
+	}
+	switch record.EncryptionAlgorithm {
+	case cryptogo.EncryptionAlgorithm_AES_GCM_NOPADDING:
+		return cryptogo.DecryptAESGCM(key, record.Payload)
+	default:
+		return nil, errors.New(fmt.Sprintf(cryptogo.ERROR_UNSUPPORTED_ENCRYPTION, record.EncryptionAlgorithm.String()))
+	}
+}
+
+// recordSecretKey returns the secret key of the encrypted record in the entry, either from the record's access list or as shared when the node was invited.
+func recordSecretKey(node *Node, entry *bcgo.BlockEntry) ([]byte, error) {
+	for _, a := range entry.Record.Access {
+		if a.Alias == node.Alias {
+			return cryptogo.DecryptKey(a.EncryptionAlgorithm, a.SecretKey, node.Key)
+		}
+	}
+	if secret, ok := node.sharedKey(entry.RecordHash); ok {
+		return cryptogo.DecryptKey(cryptogo.EncryptionAlgorithm_RSA_ECB_OAEPPADDING, secret, node.Key)
+	}
+	return nil, errors.New(fmt.Sprintf(ERROR_ACCESS_DENIED, node.Alias))
+}
+
+func getAliasChannel(node *Node) *bcgo.Channel {
+	if channel, err := node.GetChannel(aliasgo.ALIAS); err == nil {
+		return channel
+	}
+	channel := aliasgo.OpenAliasChannel()
+	loadChannel(node, channel)
+	return channel
+}
@@ -0,0 +1,137 @@
+/*
+ * Copyright 2020 Aletheia Ware LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package labgo_test
+
+import (
+	"bytes"
+	"github.com/AletheiaWareLLC/aliasgo"
+	"github.com/AletheiaWareLLC/bcgo"
+	"github.com/AletheiaWareLLC/labgo"
+	"github.com/AletheiaWareLLC/testinggo"
+	"io/ioutil"
+	"strings"
+	"testing"
+)
+
+// makeRegisteredNode returns a node sharing the given cache whose alias is registered in the cache's alias channel.
+func makeRegisteredNode(t *testing.T, alias string, cache bcgo.Cache) *labgo.Node {
+	t.Helper()
+	node := makeNode(t, alias)
+	node.Cache = cache
+	aliases := aliasgo.OpenAliasChannel()
+	aliases.LoadCachedHead(cache)
+	record, err := aliasgo.CreateSignedAliasRecord(alias, node.Key)
+	testinggo.AssertNoError(t, err)
+	reference, err := bcgo.WriteRecord(aliasgo.ALIAS, cache, record)
+	testinggo.AssertNoError(t, err)
+	// Mine only this record, as the shared cache holds records already mined by other nodes
+	_, _, err = node.MineEntries(aliases, aliasgo.ALIAS_THRESHOLD, nil, []*bcgo.BlockEntry{
+		&bcgo.BlockEntry{
+			RecordHash: reference.RecordHash,
+			Record:     record,
+		},
+	})
+	testinggo.AssertNoError(t, err)
+	return node
+}
+
+func TestGetAccess(t *testing.T) {
+	cache := bcgo.NewMemoryCache(10)
+	alice := makeRegisteredNode(t, "Alice", cache)
+	bob := makeRegisteredNode(t, "Bob", cache)
+	t.Run("Public", func(t *testing.T) {
+		acl, err := labgo.GetAccess(alice, nil)
+		testinggo.AssertNoError(t, err)
+		if acl != nil {
+			t.Fatalf("Expected public access, got '%v'", labgo.Recipients(acl))
+		}
+	})
+	t.Run("Recipients", func(t *testing.T) {
+		acl, err := labgo.GetAccess(alice, []string{"Bob"})
+		testinggo.AssertNoError(t, err)
+		if got := strings.Join(labgo.Recipients(acl), ","); got != "Alice,Bob" {
+			t.Fatalf("Incorrect recipients; expected 'Alice,Bob', got '%s'", got)
+		}
+		if acl["Bob"].N.Cmp(bob.Key.PublicKey.N) != 0 {
+			t.Fatalf("Incorrect public key")
+		}
+	})
+	t.Run("Unknown", func(t *testing.T) {
+		_, err := labgo.GetAccess(alice, []string{"Charlie"})
+		testinggo.AssertError(t, "Could not find public key for recipient: Charlie", err)
+	})
+}
+
+func TestCreateFromReader_Encrypted(t *testing.T) {
+	cache := bcgo.NewMemoryCache(10)
+	alice := makeRegisteredNode(t, "Alice", cache)
+	bob := makeRegisteredNode(t, "Bob", cache)
+	charlie := makeRegisteredNode(t, "Charlie", cache)
+	content := randomBytes(t, 1, 2*labgo.CHUNK_MIN_SIZE)
+	experiment, err := labgo.CreateFromReader(alice, nil, []string{"Bob"}, labgo.THRESHOLD_LAN, "foo.bin", ioutil.NopCloser(bytes.NewReader(content)))
+	testinggo.AssertNoError(t, err)
+	_, err = labgo.PostChat(alice, nil, experiment.Chat, experiment.Access, "Hello Bob")
+	testinggo.AssertNoError(t, err)
+
+	// Records are encrypted, and content is not shared in public chunks
+	for _, channel := range alice.GetChannels() {
+		if !strings.HasPrefix(channel.Name, labgo.LAB_PREFIX) || strings.HasPrefix(channel.Name, labgo.LAB_PREFIX_MEMBER) || strings.HasPrefix(channel.Name, labgo.LAB_PREFIX_SETTINGS) {
+			// Members and settings are public
+			continue
+		}
+		if strings.HasPrefix(channel.Name, labgo.LAB_PREFIX_CHUNK) {
+			t.Fatalf("Unexpected chunk channel: %s", channel.Name)
+		}
+		testinggo.AssertNoError(t, bcgo.Iterate(channel.Name, channel.Head, nil, cache, nil, func(hash []byte, block *bcgo.Block) error {
+			for _, entry := range block.Entry {
+				if len(entry.Record.Access) != 2 {
+					t.Fatalf("Record not encrypted in %s", channel.Name)
+				}
+			}
+			return nil
+		}))
+	}
+
+	t.Run("Recipient", func(t *testing.T) {
+		opened, err := labgo.Open(bob, experiment.ID)
+		testinggo.AssertNoError(t, err)
+		if got := strings.Join(labgo.Recipients(opened.Access), ","); got != "Alice,Bob" {
+			t.Fatalf("Incorrect recipients; expected 'Alice,Bob', got '%s'", got)
+		}
+		id, err := labgo.GetFileId(bob, opened.Path, []string{"foo.bin"})
+		testinggo.AssertNoError(t, err)
+		buffer, err := labgo.ChannelToBuffer(bob, labgo.GetFileChannel(bob, id))
+		testinggo.AssertNoError(t, err)
+		if !bytes.Equal(buffer, content) {
+			t.Fatalf("Incorrect content")
+		}
+		var messages []string
+		testinggo.AssertNoError(t, labgo.IterateChat(bob, opened.Chat, func(hash []byte, record *bcgo.Record, chat *labgo.Chat) error {
+			messages = append(messages, chat.Text)
+			return nil
+		}))
+		if len(messages) != 1 || messages[0] != "Hello Bob" {
+			t.Fatalf("Incorrect messages; got '%v'", messages)
+		}
+	})
+	t.Run("Other", func(t *testing.T) {
+		_, err := labgo.Open(charlie, experiment.ID)
+		testinggo.AssertNoError(t, err)
+		_, err = labgo.ListFiles(charlie, experiment.Path)
+		testinggo.AssertError(t, "Record not shared with Charlie", err)
+	})
+}
@@ -0,0 +1,193 @@
+/*
+ * Copyright 2020 Aletheia Ware LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package labgo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/AletheiaWareLLC/bcgo"
+	"github.com/golang/protobuf/proto"
+	"sync"
+)
+
+const (
+	// Maximum size of the records mined into a single block, and of the records pending in a batch before it is flushed
+	MAX_BATCH_SIZE = 64 * 1024 * 1024 // 64Mb
+
+	ERROR_BATCH_ACTIVE = "Batch already active for %s"
+	ERROR_BATCH_CLOSED = "Batch closed"
+)
+
+// Batch accumulates the records written by a node, instead of mining a block for each record, and mines them into as few blocks as possible when flushed.
+// Pending records are not visible to readers of their channels until the batch is flushed.
+type Batch struct {
+	sync.Mutex
+	node     *Node
+	listener bcgo.MiningListener
+	// Channels in the order they were first written to, so channels are validated after the channels they depend on, such as members
+	channels []*bcgo.Channel
+	entries  map[string][]*bcgo.BlockEntry
+	size     uint64
+	closed   bool
+}
+
+// StartBatch starts batching the records written by the node until the batch is closed.
+func StartBatch(node *Node, listener bcgo.MiningListener) (*Batch, error) {
+	node.lock.Lock()
+	defer node.lock.Unlock()
+	if node.batch != nil {
+		return nil, errors.New(fmt.Sprintf(ERROR_BATCH_ACTIVE, node.Alias))
+	}
+	b := &Batch{
+		node:     node,
+		listener: listener,
+		entries:  make(map[string][]*bcgo.BlockEntry),
+	}
+	node.batch = b
+	return b, nil
+}
+
+// Flush mines the pending records of each channel into as few blocks as possible, and pushes the channels to peers, or adds them to the node's outbox if peers are unreachable.
+func (b *Batch) Flush() error {
+	b.Lock()
+	defer b.Unlock()
+	return b.flush()
+}
+
+func (b *Batch) flush() error {
+	for len(b.channels) > 0 {
+		channel := b.channels[0]
+		if err := mineEntries(b.node, b.listener, channel, b.entries[channel.Name], func(remaining []*bcgo.BlockEntry) {
+			b.entries[channel.Name] = remaining
+		}); err != nil {
+			return err
+		}
+		if _, err := pushChannel(b.node, channel); err != nil {
+			return err
+		}
+		delete(b.entries, channel.Name)
+		b.channels = b.channels[1:]
+	}
+	b.size = 0
+	return nil
+}
+
+// Close flushes the batch, and stops batching the records written by the node.
+func (b *Batch) Close() error {
+	b.node.lock.Lock()
+	if b.node.batch == b {
+		b.node.batch = nil
+	}
+	b.node.lock.Unlock()
+	b.Lock()
+	defer b.Unlock()
+	if b.closed {
+		return nil
+	}
+	b.closed = true
+	return b.flush()
+}
+
+// add adds the entry to the pending records of the channel, flushing the batch if it grows too large.
+func (b *Batch) add(channel *bcgo.Channel, entry *bcgo.BlockEntry) error {
+	b.Lock()
+	defer b.Unlock()
+	if b.closed {
+		return errors.New(ERROR_BATCH_CLOSED)
+	}
+	if _, ok := b.entries[channel.Name]; !ok {
+		b.channels = append(b.channels, channel)
+	}
+	b.entries[channel.Name] = append(b.entries[channel.Name], entry)
+	b.size += uint64(proto.Size(entry))
+	if b.size > MAX_BATCH_SIZE {
+		return b.flush()
+	}
+	return nil
+}
+
+// pending returns true if the batch holds records for the channel which have not yet been mined.
+func (b *Batch) pending(channel string) bool {
+	b.Lock()
+	defer b.Unlock()
+	return len(b.entries[channel]) > 0
+}
+
+// pendingEntries returns a copy of the records the batch holds for the channel which have not yet been mined, oldest first.
+func (b *Batch) pendingEntries(channel string) []*bcgo.BlockEntry {
+	b.Lock()
+	defer b.Unlock()
+	return append([]*bcgo.BlockEntry(nil), b.entries[channel]...)
+}
+
+// mineEntries mines the entries into as few blocks of the channel as possible, calling the callback with the entries remaining after each block.
+func mineEntries(node *Node, listener bcgo.MiningListener, channel *bcgo.Channel, entries []*bcgo.BlockEntry, callback func([]*bcgo.BlockEntry)) error {
+	for len(entries) > 0 {
+		// Fill block up to the maximum size, with at least one entry
+		count := 1
+		size := uint64(proto.Size(entries[0]))
+		for count < len(entries) {
+			s := uint64(proto.Size(entries[count]))
+			if size+s > MAX_BATCH_SIZE {
+				break
+			}
+			size += s
+			count++
+		}
+		if _, _, err := mineBlock(context.Background(), node, listener, channel, entries[:count]); err != nil {
+			return err
+		}
+		entries = entries[count:]
+		if callback != nil {
+			callback(entries)
+		}
+	}
+	return nil
+}
+
+// getBatch returns the node's active batch, or nil if the node is not batching.
+func getBatch(node *Node) *Batch {
+	node.lock.RLock()
+	defer node.lock.RUnlock()
+	return node.batch
+}
+
+// flushBatch flushes the node's active batch, if any, so readers see every record written.
+func flushBatch(node *Node) error {
+	if b := getBatch(node); b != nil {
+		return b.Flush()
+	}
+	return nil
+}
+
+// batchWrites calls the function with the records written by the node batched, unless the node is already batching, and flushes the batch afterwards.
+func batchWrites(node *Node, listener bcgo.MiningListener, function func() error) error {
+	if getBatch(node) != nil {
+		// Records are flushed with the active batch
+		return function()
+	}
+	batch, err := StartBatch(node, listener)
+	if err != nil {
+		return err
+	}
+	err = function()
+	if e := batch.Close(); err == nil {
+		err = e
+	}
+	return err
+}
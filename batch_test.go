@@ -0,0 +1,97 @@
+/*
+ * Copyright 2020 Aletheia Ware LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package labgo_test
+
+import (
+	"fmt"
+	"github.com/AletheiaWareLLC/bcgo"
+	"github.com/AletheiaWareLLC/labgo"
+	"github.com/AletheiaWareLLC/testinggo"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// countBlocks returns the number of blocks, and entries, in the channel.
+func countBlocks(t *testing.T, node *labgo.Node, channel *bcgo.Channel) (blocks, entries int) {
+	t.Helper()
+	testinggo.AssertNoError(t, bcgo.Iterate(channel.Name, channel.Head, nil, node.Cache, node.Network, func(hash []byte, block *bcgo.Block) error {
+		blocks++
+		entries += len(block.Entry)
+		return nil
+	}))
+	return
+}
+
+func TestBatch(t *testing.T) {
+	node := makeNode(t, "Alice")
+	experiment, err := labgo.CreateFromReader(node, nil, nil, labgo.THRESHOLD_LAN, "", nil)
+	testinggo.AssertNoError(t, err)
+	channel := experiment.Chat
+	batch, err := labgo.StartBatch(node, nil)
+	testinggo.AssertNoError(t, err)
+	_, err = labgo.StartBatch(node, nil)
+	testinggo.AssertError(t, fmt.Sprintf(labgo.ERROR_BATCH_ACTIVE, "Alice"), err)
+	for i := 0; i < 10; i++ {
+		_, err := labgo.PostChat(node, nil, channel, nil, fmt.Sprintf("Message %d", i))
+		testinggo.AssertNoError(t, err)
+	}
+	if channel.Head != nil {
+		t.Fatalf("Expected records to be pending")
+	}
+	testinggo.AssertNoError(t, batch.Flush())
+	if blocks, entries := countBlocks(t, node, channel); blocks != 1 || entries != 10 {
+		t.Fatalf("Incorrect blocks; expected '1' with '10' entries, got '%d' with '%d'", blocks, entries)
+	}
+	_, err = labgo.PostChat(node, nil, channel, nil, "Last Message")
+	testinggo.AssertNoError(t, err)
+	testinggo.AssertNoError(t, batch.Close())
+	if blocks, entries := countBlocks(t, node, channel); blocks != 2 || entries != 11 {
+		t.Fatalf("Incorrect blocks; expected '2' with '11' entries, got '%d' with '%d'", blocks, entries)
+	}
+	// Records are mined individually once the batch is closed
+	_, err = labgo.PostChat(node, nil, channel, nil, "After Close")
+	testinggo.AssertNoError(t, err)
+	if blocks, _ := countBlocks(t, node, channel); blocks != 3 {
+		t.Fatalf("Incorrect blocks; expected '3', got '%d'", blocks)
+	}
+}
+
+func TestCreateFromPaths_Batched(t *testing.T) {
+	dir, err := ioutil.TempDir("", "lab")
+	testinggo.AssertNoError(t, err)
+	defer os.RemoveAll(dir)
+	for i := 0; i < 10; i++ {
+		testinggo.AssertNoError(t, ioutil.WriteFile(filepath.Join(dir, fmt.Sprintf("%d.txt", i)), []byte("foobar"), 0666))
+	}
+	node := makeNode(t, "Alice")
+	experiment, err := labgo.CreateFromPaths(node, nil, nil, labgo.THRESHOLD_LAN, dir)
+	testinggo.AssertNoError(t, err)
+	if blocks, entries := countBlocks(t, node, experiment.Path); blocks != 1 || entries != 10 {
+		t.Fatalf("Incorrect blocks; expected '1' with '10' entries, got '%d' with '%d'", blocks, entries)
+	}
+	files, err := labgo.ListFiles(node, experiment.Path)
+	testinggo.AssertNoError(t, err)
+	if len(files) != 10 {
+		t.Fatalf("Incorrect files; expected '%d', got '%d'", 10, len(files))
+	}
+	// Node is no longer batching
+	batch, err := labgo.StartBatch(node, nil)
+	testinggo.AssertNoError(t, err)
+	testinggo.AssertNoError(t, batch.Close())
+}
@@ -0,0 +1,146 @@
+/*
+ * Copyright 2020 Aletheia Ware LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package labgo
+
+import (
+	"bytes"
+	"github.com/AletheiaWareLLC/bcgo"
+	"unicode/utf8"
+)
+
+// Span is a range of bytes in a file, attributed to the Delta record which added them.
+type Span struct {
+	Offset uint64
+	Length uint64
+	Hash   []byte
+	Record *bcgo.Record
+}
+
+// Line is a line of a text file, attributed to the most recent Delta record which added any of its bytes.
+type Line struct {
+	Number int
+	Text   []byte
+	Hash   []byte
+	Record *bcgo.Record
+}
+
+// Blame reconstructs the current content of a file and attributes each byte range to the delta which added it.
+// The returned spans are in order and cover the content without gaps.
+func Blame(node *Node, channel *bcgo.Channel) ([]byte, []*Span, error) {
+	table := &PieceTable{}
+	var spans []*Span
+	if err := IterateDeltas(node, channel, func(h []byte, r *bcgo.Record, d *Delta) error {
+		if d.Snapshot != nil {
+			// Snapshots do not change the file
+			return nil
+		}
+		table.Apply(d)
+		spans = deltaToSpans(d, h, r, spans)
+		return nil
+	}); err != nil {
+		return nil, nil, err
+	}
+	return table.Bytes(), spans, nil
+}
+
+// BlameLines splits the buffer into lines and attributes each to the most recent of the spans it overlaps.
+func BlameLines(buffer []byte, spans []*Span) []*Line {
+	var lines []*Line
+	var start uint64
+	index := 0
+	for number := 1; start < uint64(len(buffer)); number++ {
+		end := uint64(len(buffer))
+		if i := bytes.IndexByte(buffer[start:], '\n'); i >= 0 {
+			end = start + uint64(i) + 1
+		}
+		line := &Line{
+			Number: number,
+			Text:   bytes.TrimRight(buffer[start:end], "\r\n"),
+		}
+		// Skip spans which end before this line
+		for index < len(spans) && spans[index].Offset+spans[index].Length <= start {
+			index++
+		}
+		for i := index; i < len(spans) && spans[i].Offset < end; i++ {
+			if line.Record == nil || spans[i].Record.Timestamp > line.Record.Timestamp {
+				line.Hash = spans[i].Hash
+				line.Record = spans[i].Record
+			}
+		}
+		lines = append(lines, line)
+		start = end
+	}
+	return lines
+}
+
+// IsText returns true if the buffer looks like text, that is valid UTF-8 without any null bytes.
+func IsText(buffer []byte) bool {
+	return utf8.Valid(buffer) && bytes.IndexByte(buffer, 0) < 0
+}
+
+// deltaToSpans updates the spans to reflect the bytes removed and added by the delta.
+func deltaToSpans(delta *Delta, hash []byte, record *bcgo.Record, spans []*Span) []*Span {
+	start := delta.Offset
+	end := start + uint64(len(delta.Remove))
+	added := uint64(len(delta.Add))
+	var result []*Span
+	inserted := false
+	insert := func() {
+		if !inserted && added > 0 {
+			result = append(result, &Span{
+				Offset: start,
+				Length: added,
+				Hash:   hash,
+				Record: record,
+			})
+		}
+		inserted = true
+	}
+	for _, s := range spans {
+		sEnd := s.Offset + s.Length
+		if sEnd <= start {
+			// Before delta
+			result = append(result, s)
+			continue
+		}
+		if s.Offset < start {
+			// Keep part before delta
+			result = append(result, &Span{
+				Offset: s.Offset,
+				Length: start - s.Offset,
+				Hash:   s.Hash,
+				Record: s.Record,
+			})
+		}
+		insert()
+		if sEnd > end {
+			// Keep part after delta, shifted by the change in length
+			offset := s.Offset
+			if offset < end {
+				offset = end
+			}
+			result = append(result, &Span{
+				Offset: offset - (end - start) + added,
+				Length: sEnd - offset,
+				Hash:   s.Hash,
+				Record: s.Record,
+			})
+		}
+	}
+	insert()
+	return result
+}
@@ -0,0 +1,98 @@
+/*
+ * Copyright 2020 Aletheia Ware LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package labgo_test
+
+import (
+	"bytes"
+	"github.com/AletheiaWareLLC/labgo"
+	"github.com/AletheiaWareLLC/testinggo"
+	"testing"
+)
+
+func TestBlame(t *testing.T) {
+	node := makeNode(t, "Alice")
+	channel := labgo.OpenFileChannel(node, "foobar", labgo.THRESHOLD_DEFAULT)
+	node.AddChannel(channel)
+	var hashes [][]byte
+	for _, d := range []*labgo.Delta{
+		&labgo.Delta{
+			Add: []byte("foo\nbar\n"),
+		},
+		&labgo.Delta{
+			Offset: 4,
+			Remove: []byte("bar"),
+			Add:    []byte("baz"),
+		},
+		&labgo.Delta{
+			Add: []byte("hi\n"),
+		},
+	} {
+		hash, err := labgo.WriteProto(node, nil, channel, nil, nil, d)
+		testinggo.AssertNoError(t, err)
+		hashes = append(hashes, hash)
+	}
+	buffer, spans, err := labgo.Blame(node, channel)
+	testinggo.AssertNoError(t, err)
+	if got := string(buffer); got != "hi\nfoo\nbaz\n" {
+		t.Fatalf("Incorrect buffer; expected '%s', got '%s'", "hi\nfoo\nbaz\n", got)
+	}
+	expected := []struct {
+		offset, length uint64
+		hash           []byte
+	}{
+		{0, 3, hashes[2]},
+		{3, 4, hashes[0]},
+		{7, 3, hashes[1]},
+		{10, 1, hashes[0]},
+	}
+	if len(spans) != len(expected) {
+		t.Fatalf("Incorrect spans; expected '%d', got '%d'", len(expected), len(spans))
+	}
+	for i, e := range expected {
+		s := spans[i]
+		if s.Offset != e.offset || s.Length != e.length || !bytes.Equal(s.Hash, e.hash) {
+			t.Fatalf("Incorrect span %d; expected '%d+%d', got '%d+%d'", i, e.offset, e.length, s.Offset, s.Length)
+		}
+	}
+	lines := labgo.BlameLines(buffer, spans)
+	for i, e := range []struct {
+		text string
+		hash []byte
+	}{
+		{"hi", hashes[2]},
+		{"foo", hashes[0]},
+		// Newline was added by the first delta, but the second is more recent
+		{"baz", hashes[1]},
+	} {
+		l := lines[i]
+		if l.Number != i+1 || string(l.Text) != e.text || !bytes.Equal(l.Hash, e.hash) {
+			t.Fatalf("Incorrect line %d; expected '%s', got '%s'", i+1, e.text, l.Text)
+		}
+	}
+	if len(lines) != 3 {
+		t.Fatalf("Incorrect lines; expected '%d', got '%d'", 3, len(lines))
+	}
+}
+
+func TestIsText(t *testing.T) {
+	if !labgo.IsText([]byte("foo\nbar\n")) {
+		t.Fatalf("Expected text")
+	}
+	if labgo.IsText([]byte{0x89, 'P', 'N', 'G', 0x00}) {
+		t.Fatalf("Expected binary")
+	}
+}
@@ -0,0 +1,119 @@
+/*
+ * Copyright 2020 Aletheia Ware LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package labgo
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"github.com/AletheiaWareLLC/bcgo"
+	"github.com/golang/protobuf/proto"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+const (
+	ERROR_CACHE_UNSUPPORTED = "Cannot delete from cache: %T"
+)
+
+// DeleteChannel removes the head, blocks, and pending entries of the given channel from the cache, and returns the number of blocks and bytes freed.
+func DeleteChannel(cache bcgo.Cache, channel string) (uint64, uint64, error) {
+	var deleteBlock func([]byte, *bcgo.Block) error
+	var deleteChannel func() error
+	switch c := cache.(type) {
+	case *bcgo.FileCache:
+		deleteBlock = func(hash []byte, block *bcgo.Block) error {
+			return os.Remove(filepath.Join(c.Directory, "block", base64.RawURLEncoding.EncodeToString(hash)))
+		}
+		deleteChannel = func() error {
+			name := base64.RawURLEncoding.EncodeToString([]byte(channel))
+			for _, d := range []string{"entry", "mapping"} {
+				if err := os.RemoveAll(filepath.Join(c.Directory, d, name)); err != nil {
+					return err
+				}
+			}
+			if err := os.Remove(filepath.Join(c.Directory, "channel", name)); err != nil && !os.IsNotExist(err) {
+				return err
+			}
+			return nil
+		}
+	case *bcgo.MemoryCache:
+		deleteBlock = func(hash []byte, block *bcgo.Block) error {
+			delete(c.Block, base64.RawURLEncoding.EncodeToString(hash))
+			for _, e := range block.Entry {
+				delete(c.Mapping, base64.RawURLEncoding.EncodeToString(e.RecordHash))
+			}
+			return nil
+		}
+		deleteChannel = func() error {
+			delete(c.Entries, channel)
+			delete(c.Head, channel)
+			return nil
+		}
+	default:
+		return 0, 0, errors.New(fmt.Sprintf(ERROR_CACHE_UNSUPPORTED, cache))
+	}
+
+	var blocks, size uint64
+	if reference, err := cache.GetHead(channel); err == nil {
+		hash := reference.BlockHash
+		for len(hash) > 0 {
+			block, err := cache.GetBlock(hash)
+			if err != nil {
+				// Remainder of chain is not cached
+				break
+			}
+			if err := deleteBlock(hash, block); err != nil {
+				return blocks, size, err
+			}
+			blocks++
+			size += uint64(proto.Size(block))
+			hash = block.Previous
+		}
+	}
+	return blocks, size, deleteChannel()
+}
+
+// ListChannels returns the names of the channels whose heads are held in the cache.
+func ListChannels(cache bcgo.Cache) ([]string, error) {
+	var channels []string
+	switch c := cache.(type) {
+	case *bcgo.FileCache:
+		files, err := ioutil.ReadDir(filepath.Join(c.Directory, "channel"))
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil, nil
+			}
+			return nil, err
+		}
+		for _, f := range files {
+			name, err := base64.RawURLEncoding.DecodeString(f.Name())
+			if err != nil {
+				return nil, err
+			}
+			channels = append(channels, string(name))
+		}
+	case *bcgo.MemoryCache:
+		for name := range c.Head {
+			channels = append(channels, name)
+		}
+	default:
+		return nil, errors.New(fmt.Sprintf(ERROR_CACHE_UNSUPPORTED, cache))
+	}
+	return channels, nil
+}
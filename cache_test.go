@@ -0,0 +1,71 @@
+/*
+ * Copyright 2020 Aletheia Ware LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package labgo_test
+
+import (
+	"github.com/AletheiaWareLLC/bcgo"
+	"github.com/AletheiaWareLLC/labgo"
+	"github.com/AletheiaWareLLC/testinggo"
+	"io/ioutil"
+	"os"
+	"testing"
+)
+
+func TestDeleteChannel(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cache")
+	testinggo.AssertNoError(t, err)
+	defer os.RemoveAll(dir)
+	fileCache, err := bcgo.NewFileCache(dir)
+	testinggo.AssertNoError(t, err)
+	for name, cache := range map[string]bcgo.Cache{
+		"FileCache":   fileCache,
+		"MemoryCache": bcgo.NewMemoryCache(10),
+	} {
+		t.Run(name, func(t *testing.T) {
+			node := makeNode(t, "Alice")
+			node.Cache = cache
+			channel := labgo.OpenFileChannel(node, "foobar", labgo.THRESHOLD_DEFAULT)
+			for _, d := range []string{"foo", "bar"} {
+				_, err := labgo.WriteProto(node, nil, channel, nil, nil, &labgo.Delta{
+					Add: []byte(d),
+				})
+				testinggo.AssertNoError(t, err)
+			}
+			head := channel.Head
+			blocks, size, err := labgo.DeleteChannel(cache, channel.Name)
+			testinggo.AssertNoError(t, err)
+			if blocks != 2 {
+				t.Fatalf("Incorrect blocks; expected '%d', got '%d'", 2, blocks)
+			}
+			if size == 0 {
+				t.Fatalf("Incorrect size; expected non-zero")
+			}
+			if _, err := cache.GetHead(channel.Name); err == nil {
+				t.Fatalf("Expected head to be deleted")
+			}
+			if _, err := cache.GetBlock(head); err == nil {
+				t.Fatalf("Expected block to be deleted")
+			}
+			// Deleting again frees nothing
+			blocks, size, err = labgo.DeleteChannel(cache, channel.Name)
+			testinggo.AssertNoError(t, err)
+			if blocks != 0 || size != 0 {
+				t.Fatalf("Incorrect freed; expected '0' and '0', got '%d' and '%d'", blocks, size)
+			}
+		})
+	}
+}
@@ -0,0 +1,93 @@
+/*
+ * Copyright 2020 Aletheia Ware LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package labgo
+
+import (
+	"bytes"
+	"crypto/rsa"
+	"github.com/AletheiaWareLLC/bcgo"
+	"github.com/golang/protobuf/proto"
+	"log"
+)
+
+// PostChat writes a message with the given text to the chat channel.
+func PostChat(node *Node, listener bcgo.MiningListener, channel *bcgo.Channel, acl map[string]*rsa.PublicKey, text string) ([]byte, error) {
+	return WriteProto(node, listener, channel, acl, nil, &Chat{
+		Text: text,
+	})
+}
+
+// IterateChat calls the callback with each message in the chat channel, oldest first.
+func IterateChat(node *Node, channel *bcgo.Channel, callback func([]byte, *bcgo.Record, *Chat) error) error {
+	// Iterate through chain chronologically
+	return bcgo.IterateChronologically(channel.Name, channel.Head, nil, node.Cache, node.Network, func(hash []byte, block *bcgo.Block) error {
+		for _, entry := range block.Entry {
+			if err := chatEntry(node, entry, callback); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// SubscribeChat calls the callback with each message added to the chat channel from now on.
+func SubscribeChat(node *Node, channel *bcgo.Channel, callback func([]byte, *bcgo.Record, *Chat) error) {
+	last := channel.Head
+	channel.AddTrigger(func() {
+		head := channel.Head
+		// Collect entries mined since the last update, newest first
+		var entries []*bcgo.BlockEntry
+		if err := bcgo.Iterate(channel.Name, head, nil, node.Cache, node.Network, func(hash []byte, block *bcgo.Block) error {
+			if bytes.Equal(hash, last) {
+				return bcgo.StopIterationError{}
+			}
+			for i := len(block.Entry) - 1; i >= 0; i-- {
+				entries = append(entries, block.Entry[i])
+			}
+			return nil
+		}); err != nil {
+			switch err.(type) {
+			case bcgo.StopIterationError:
+				// Do nothing
+				break
+			default:
+				log.Println(err)
+				return
+			}
+		}
+		last = head
+		for i := len(entries) - 1; i >= 0; i-- {
+			if err := chatEntry(node, entries[i], callback); err != nil {
+				log.Println(err)
+				return
+			}
+		}
+	})
+}
+
+func chatEntry(node *Node, entry *bcgo.BlockEntry, callback func([]byte, *bcgo.Record, *Chat) error) error {
+	payload, err := readPayload(node, entry)
+	if err != nil {
+		return err
+	}
+	// Unmarshal as Chat
+	c := &Chat{}
+	if err := proto.Unmarshal(payload, c); err != nil {
+		return err
+	}
+	return callback(entry.RecordHash, entry.Record, c)
+}
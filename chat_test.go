@@ -0,0 +1,58 @@
+/*
+ * Copyright 2020 Aletheia Ware LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package labgo_test
+
+import (
+	"github.com/AletheiaWareLLC/bcgo"
+	"github.com/AletheiaWareLLC/labgo"
+	"github.com/AletheiaWareLLC/testinggo"
+	"testing"
+)
+
+func TestChat(t *testing.T) {
+	node := makeNode(t, "Alice")
+	experiment, err := labgo.CreateFromReader(node, nil, nil, labgo.THRESHOLD_LAN, "", nil)
+	testinggo.AssertNoError(t, err)
+	channel := experiment.Chat
+
+	_, err = labgo.PostChat(node, nil, channel, nil, "foo")
+	testinggo.AssertNoError(t, err)
+
+	var subscribed []string
+	labgo.SubscribeChat(node, channel, func(hash []byte, record *bcgo.Record, chat *labgo.Chat) error {
+		subscribed = append(subscribed, chat.Text)
+		return nil
+	})
+
+	_, err = labgo.PostChat(node, nil, channel, nil, "bar")
+	testinggo.AssertNoError(t, err)
+
+	var history []string
+	testinggo.AssertNoError(t, labgo.IterateChat(node, channel, func(hash []byte, record *bcgo.Record, chat *labgo.Chat) error {
+		if record.Creator != "Alice" {
+			t.Fatalf("Incorrect creator; expected '%s', got '%s'", "Alice", record.Creator)
+		}
+		history = append(history, chat.Text)
+		return nil
+	}))
+	if len(history) != 2 || history[0] != "foo" || history[1] != "bar" {
+		t.Fatalf("Incorrect history; expected '%v', got '%v'", []string{"foo", "bar"}, history)
+	}
+	if len(subscribed) != 1 || subscribed[0] != "bar" {
+		t.Fatalf("Incorrect subscription; expected '%v', got '%v'", []string{"bar"}, subscribed)
+	}
+}
@@ -0,0 +1,445 @@
+/*
+ * Copyright 2020 Aletheia Ware LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package labgo
+
+import (
+	"bytes"
+	"crypto/rsa"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"github.com/AletheiaWareLLC/bcgo"
+	"github.com/AletheiaWareLLC/cryptogo"
+	"github.com/golang/protobuf/proto"
+	"io"
+	"math"
+	"os"
+)
+
+const (
+	// Content-defined chunk sizes, chunks smaller than the minimum only occur at the end of a file
+	CHUNK_MIN_SIZE     = 256 * 1024      // 256Kb
+	CHUNK_AVERAGE_SIZE = 1024 * 1024     // 1Mb
+	CHUNK_MAX_SIZE     = 4 * 1024 * 1024 // 4Mb
+	// Maximum number of chunks referenced by a single delta
+	MAX_DELTA_CHUNKS = 1024
+
+	ERROR_CHUNK_INVALID        = "Chunk does not match hash: %s"
+	ERROR_CHUNK_LENGTH_INVALID = "Chunk length does not match reference: %d vs %d"
+	ERROR_CHUNK_NOT_FOUND      = "Chunk not found: %s"
+)
+
+var (
+	// Masks of the gear hash bits which must be zero to end a chunk, before and after the average size is reached
+	chunkMaskSmall = uint64(1<<22-1) << (64 - 22)
+	chunkMaskLarge = uint64(1<<18-1) << (64 - 18)
+	// Random values for each byte, generated from a fixed seed so chunk boundaries never change
+	chunkGear = func() (gear [256]uint64) {
+		// SplitMix64
+		state := uint64(0x4c6162)
+		for i := range gear {
+			state += 0x9e3779b97f4a7c15
+			z := state
+			z = (z ^ (z >> 30)) * 0xbf58476d1ce4e5b9
+			z = (z ^ (z >> 27)) * 0x94d049bb133111eb
+			gear[i] = z ^ (z >> 31)
+		}
+		return
+	}()
+)
+
+// ReaderToChunks splits the content of the reader into chunks whose boundaries are determined by the content, using the FastCDC gear hash.
+// Inserting or removing bytes only changes the chunks around the change, so unchanged content produces the same chunks.
+func ReaderToChunks(reader io.Reader, callback func([]byte) error) error {
+	buffer := make([]byte, 0, CHUNK_MAX_SIZE)
+	eof := false
+	for {
+		// Fill buffer
+		for !eof && len(buffer) < CHUNK_MAX_SIZE {
+			count, err := reader.Read(buffer[len(buffer):CHUNK_MAX_SIZE])
+			buffer = buffer[:len(buffer)+count]
+			if err == io.EOF {
+				eof = true
+			} else if err != nil {
+				return err
+			}
+		}
+		if len(buffer) == 0 {
+			return nil
+		}
+		length := chunkBoundary(buffer)
+		chunk := make([]byte, length)
+		copy(chunk, buffer[:length])
+		if err := callback(chunk); err != nil {
+			return err
+		}
+		buffer = buffer[:copy(buffer, buffer[length:])]
+	}
+}
+
+// chunkBoundary returns the length of the chunk at the start of the data.
+func chunkBoundary(data []byte) int {
+	length := len(data)
+	if length <= CHUNK_MIN_SIZE {
+		return length
+	}
+	if length > CHUNK_MAX_SIZE {
+		length = CHUNK_MAX_SIZE
+	}
+	normal := CHUNK_AVERAGE_SIZE
+	if normal > length {
+		normal = length
+	}
+	var hash uint64
+	i := CHUNK_MIN_SIZE
+	for ; i < normal; i++ {
+		hash = (hash << 1) + chunkGear[data[i]]
+		if hash&chunkMaskSmall == 0 {
+			return i + 1
+		}
+	}
+	for ; i < length; i++ {
+		hash = (hash << 1) + chunkGear[data[i]]
+		if hash&chunkMaskLarge == 0 {
+			return i + 1
+		}
+	}
+	return length
+}
+
+// WriteChunk stores the data in the chunk channel identified by its hash, mined at the given threshold, unless the chunk is already stored locally or by a peer.
+func WriteChunk(node *Node, listener bcgo.MiningListener, threshold uint64, data []byte) (*ChunkReference, error) {
+	return writeChunk(node, listener, threshold, data, true)
+}
+
+// writeChunk stores the data as WriteChunk does, and if batched is false mines it immediately even if the node is batching.
+func writeChunk(node *Node, listener bcgo.MiningListener, threshold uint64, data []byte, batched bool) (*ChunkReference, error) {
+	hash := cryptogo.Hash(data)
+	reference := &ChunkReference{
+		Hash:   hash,
+		Length: uint64(len(data)),
+	}
+	channel := getChunkChannel(node, threshold, hash)
+	weak := channel.Head != nil && bcgo.Ones(channel.Head) < threshold
+	if !batched || weak {
+		if channel.Head != nil && !weak {
+			// Already stored
+			return reference, nil
+		}
+		entry, err := createEntry(node, channel, nil, nil, data)
+		if err != nil {
+			return nil, err
+		}
+		if weak {
+			// Stored for an experiment with a lower threshold, so replaced by a chain which reaches this one
+			node.update.Lock()
+			head, timestamp := channel.Head, channel.Timestamp
+			channel.Head, channel.Timestamp = nil, 0
+			node.update.Unlock()
+			defer func() {
+				node.update.Lock()
+				if channel.Head == nil {
+					channel.Head, channel.Timestamp = head, timestamp
+				}
+				node.update.Unlock()
+			}()
+		}
+		if err := mineEntries(node, listener, channel, []*bcgo.BlockEntry{entry}, nil); err != nil {
+			return nil, err
+		}
+		if _, err := pushChannel(node, channel); err != nil {
+			return nil, err
+		}
+		return reference, nil
+	}
+	if batch := getBatch(node); channel.Head != nil || (batch != nil && batch.pending(channel.Name)) {
+		// Already stored
+		return reference, nil
+	}
+	if _, err := writePayload(node, listener, channel, nil, nil, data); err != nil {
+		return nil, err
+	}
+	return reference, nil
+}
+
+// GetChunk returns the data of the referenced chunk, whose blocks must reach the given threshold.
+// The data must have the length given by the reference, so a delta cannot declare a different length than it applies.
+func GetChunk(node *Node, threshold uint64, reference *ChunkReference) ([]byte, error) {
+	channel := getChunkChannel(node, threshold, reference.Hash)
+	var data []byte
+	if err := bcgo.Iterate(channel.Name, channel.Head, nil, node.Cache, node.Network, func(hash []byte, block *bcgo.Block) error {
+		for _, entry := range block.Entry {
+			if bytes.Equal(cryptogo.Hash(entry.Record.Payload), reference.Hash) {
+				data = entry.Record.Payload
+				return bcgo.StopIterationError{}
+			}
+		}
+		return nil
+	}); err != nil {
+		switch err.(type) {
+		case bcgo.StopIterationError:
+			// Do nothing
+			break
+		default:
+			return nil, err
+		}
+	}
+	if data == nil {
+		return nil, errors.New(fmt.Sprintf(ERROR_CHUNK_NOT_FOUND, base64.RawURLEncoding.EncodeToString(reference.Hash)))
+	}
+	if l := uint64(len(data)); l != reference.Length {
+		return nil, errors.New(fmt.Sprintf(ERROR_CHUNK_LENGTH_INVALID, l, reference.Length))
+	}
+	return data, nil
+}
+
+// PathToChannel writes the content of the file at the given path to the file channel.
+func PathToChannel(node *Node, listener bcgo.MiningListener, channel *bcgo.Channel, acl map[string]*rsa.PublicKey, path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	return ReaderToChannel(node, listener, channel, acl, file)
+}
+
+// ReaderToChannel writes the content of the reader to the file channel.
+// Content smaller than CHUNK_MIN_SIZE is written in a single delta, otherwise it is split into chunks which are stored once and referenced by the deltas.
+// Chunks are public, so if the access list is not empty each chunk is instead written encrypted in its own delta.
+func ReaderToChannel(node *Node, listener bcgo.MiningListener, channel *bcgo.Channel, acl map[string]*rsa.PublicKey, reader io.Reader) error {
+	return writeChunked(node, listener, channel, acl, reader, nil)
+}
+
+// WriteDelta writes the delta to the file channel, replacing bytes removed or added which are at least CHUNK_MIN_SIZE with references to chunks, and compressing the rest if it makes them smaller.
+// Chunks are public, so if the access list is not empty the bytes are kept in the delta and encrypted with it.
+func WriteDelta(node *Node, listener bcgo.MiningListener, channel *bcgo.Channel, acl map[string]*rsa.PublicKey, delta *Delta) ([]byte, error) {
+	threshold, err := ChannelThreshold(node.Cache, node.Network, channel)
+	if err != nil {
+		return nil, err
+	}
+	if err := encodeDelta(node, listener, threshold, acl, delta, true); err != nil {
+		return nil, err
+	}
+	return WriteProto(node, listener, channel, acl, nil, delta)
+}
+
+// encodeDelta prepares the delta to be written, replacing large byte ranges with chunks mined at the given threshold and compressing the rest, as WriteDelta does.
+// If batched is false the chunks are mined immediately, even if the node is batching.
+func encodeDelta(node *Node, listener bcgo.MiningListener, threshold uint64, acl map[string]*rsa.PublicKey, delta *Delta, batched bool) error {
+	if len(acl) == 0 && len(delta.Remove) >= CHUNK_MIN_SIZE {
+		references, err := bufferToChunks(node, listener, threshold, delta.Remove, batched)
+		if err != nil {
+			return err
+		}
+		delta.Remove = nil
+		delta.RemoveChunk = append(references, delta.RemoveChunk...)
+	}
+	if len(acl) == 0 && len(delta.Add) >= CHUNK_MIN_SIZE {
+		references, err := bufferToChunks(node, listener, threshold, delta.Add, batched)
+		if err != nil {
+			return err
+		}
+		delta.Add = nil
+		delta.AddChunk = append(references, delta.AddChunk...)
+	}
+	return CompressDelta(delta)
+}
+
+// ChunkValidator ensures every record in a chunk channel contains the data identified by the channel name.
+type ChunkValidator struct {
+}
+
+func (v *ChunkValidator) Validate(channel *bcgo.Channel, cache bcgo.Cache, network bcgo.Network, hash []byte, block *bcgo.Block) error {
+	expected := channel.Name[len(LAB_PREFIX_CHUNK):]
+	return iterateEntries(channel, cache, network, hash, block, func(entry *bcgo.BlockEntry) error {
+		if base64.RawURLEncoding.EncodeToString(cryptogo.Hash(entry.Record.Payload)) != expected {
+			return errors.New(fmt.Sprintf(ERROR_CHUNK_INVALID, expected))
+		}
+		return nil
+	})
+}
+
+// resolveChunks replaces the chunks referenced by the delta with their data, read from chunk channels whose blocks must reach the given threshold.
+func resolveChunks(node *Node, threshold uint64, delta *Delta) error {
+	for _, r := range delta.RemoveChunk {
+		data, err := GetChunk(node, threshold, r)
+		if err != nil {
+			return err
+		}
+		delta.Remove = append(delta.Remove, data...)
+	}
+	delta.RemoveChunk = nil
+	for _, r := range delta.AddChunk {
+		data, err := GetChunk(node, threshold, r)
+		if err != nil {
+			return err
+		}
+		delta.Add = append(delta.Add, data...)
+	}
+	delta.AddChunk = nil
+	return nil
+}
+
+// chunkReferences calls the callback with the ID of each chunk referenced by the deltas in the cached file channel.
+func chunkReferences(cache bcgo.Cache, channel string, callback func(string)) error {
+	reference, err := cache.GetHead(channel)
+	if err != nil {
+		// Channel not cached
+		return nil
+	}
+	return iterateChunkReferences(channel, reference.BlockHash, cache, nil, func(r *ChunkReference) {
+		callback(base64.RawURLEncoding.EncodeToString(r.Hash))
+	})
+}
+
+// iterateChunkReferences calls the callback with each chunk referenced by the deltas in the file channel ending with the given block.
+// Only public deltas are read, as the deltas of private experiments are never chunked, so no record is decrypted.
+func iterateChunkReferences(channel string, head []byte, cache bcgo.Cache, network bcgo.Network, callback func(*ChunkReference)) error {
+	return bcgo.Iterate(channel, head, nil, cache, network, func(hash []byte, block *bcgo.Block) error {
+		for _, entry := range block.Entry {
+			if len(entry.Record.Access) > 0 {
+				continue
+			}
+			d := &Delta{}
+			if err := proto.Unmarshal(entry.Record.Payload, d); err != nil {
+				return err
+			}
+			for _, references := range [][]*ChunkReference{d.RemoveChunk, d.AddChunk} {
+				for _, r := range references {
+					callback(r)
+				}
+			}
+		}
+		return nil
+	})
+}
+
+// removeLength returns the number of bytes removed by the delta, including those in chunks, or math.MaxUint64 if the chunks declare more.
+func removeLength(delta *Delta) uint64 {
+	length := uint64(len(delta.Remove))
+	for _, r := range delta.RemoveChunk {
+		length = addSaturated(length, r.Length)
+	}
+	return length
+}
+
+// addLength returns the number of bytes added by the delta, including those in chunks, or math.MaxUint64 if the chunks declare more.
+func addLength(delta *Delta) uint64 {
+	length := uint64(len(delta.Add))
+	for _, r := range delta.AddChunk {
+		length = addSaturated(length, r.Length)
+	}
+	return length
+}
+
+// addSaturated returns the sum of a and b, or math.MaxUint64 if the sum overflows.
+func addSaturated(a, b uint64) uint64 {
+	if b > math.MaxUint64-a {
+		return math.MaxUint64
+	}
+	return a + b
+}
+
+// getChunkChannel returns the node's channel for the chunk with the given hash.
+// Chunks are shared by the files of every experiment, so the channel is mined and validated at the highest threshold requested of it.
+func getChunkChannel(node *Node, threshold uint64, hash []byte) *bcgo.Channel {
+	id := base64.RawURLEncoding.EncodeToString(hash)
+	if channel, err := node.GetChannel(LAB_PREFIX_CHUNK + id); err == nil {
+		for _, v := range channel.Validators {
+			if v, ok := v.(*bcgo.PoWValidator); ok && v.Threshold < threshold {
+				v.Threshold = threshold
+			}
+		}
+		return channel
+	}
+	channel := OpenChunkChannel(id, threshold)
+	loadChannel(node, channel)
+	return channel
+}
+
+func bufferToChunks(node *Node, listener bcgo.MiningListener, threshold uint64, buffer []byte, batched bool) ([]*ChunkReference, error) {
+	var references []*ChunkReference
+	if err := ReaderToChunks(bytes.NewReader(buffer), func(chunk []byte) error {
+		reference, err := writeChunk(node, listener, threshold, chunk, batched)
+		if err != nil {
+			return err
+		}
+		references = append(references, reference)
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+	return references, nil
+}
+
+// writeChunked writes the content of the reader to the file channel as a sequence of deltas referencing chunks, or as a single delta if the content is smaller than CHUNK_MIN_SIZE.
+// If the access list is not empty each chunk is written in its own encrypted delta instead.
+// If a snapshot is given the deltas are the parts of the snapshot.
+func writeChunked(node *Node, listener bcgo.MiningListener, channel *bcgo.Channel, acl map[string]*rsa.PublicKey, reader io.Reader, snapshot *Snapshot) error {
+	// Chunks are mined at the threshold of the file
+	threshold, err := ChannelThreshold(node.Cache, node.Network, channel)
+	if err != nil {
+		return err
+	}
+	var offset, length uint64
+	var references []*ChunkReference
+	flush := func() error {
+		if len(references) == 0 {
+			return nil
+		}
+		if _, err := WriteProto(node, listener, channel, acl, nil, &Delta{
+			Offset:   offset,
+			AddChunk: references,
+			Snapshot: snapshot,
+		}); err != nil {
+			return err
+		}
+		offset += length
+		length = 0
+		references = nil
+		return nil
+	}
+	if err := ReaderToChunks(reader, func(chunk []byte) error {
+		if len(acl) > 0 || (offset == 0 && len(references) == 0 && len(chunk) < CHUNK_MIN_SIZE) {
+			// Content is private, or too small to share
+			d := &Delta{
+				Offset:   offset,
+				Add:      chunk,
+				Snapshot: snapshot,
+			}
+			if err := CompressDelta(d); err != nil {
+				return err
+			}
+			_, err := WriteProto(node, listener, channel, acl, nil, d)
+			offset += uint64(len(chunk))
+			return err
+		}
+		reference, err := WriteChunk(node, listener, threshold, chunk)
+		if err != nil {
+			return err
+		}
+		references = append(references, reference)
+		length += reference.Length
+		if len(references) >= MAX_DELTA_CHUNKS {
+			return flush()
+		}
+		return nil
+	}); err != nil {
+		return err
+	}
+	return flush()
+}
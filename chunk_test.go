@@ -0,0 +1,207 @@
+/*
+ * Copyright 2020 Aletheia Ware LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package labgo_test
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/AletheiaWareLLC/bcgo"
+	"github.com/AletheiaWareLLC/labgo"
+	"github.com/AletheiaWareLLC/testinggo"
+	"io/ioutil"
+	"math/rand"
+	"strings"
+	"testing"
+)
+
+func randomBytes(t *testing.T, seed int64, length int) []byte {
+	t.Helper()
+	data := make([]byte, length)
+	_, err := rand.New(rand.NewSource(seed)).Read(data)
+	testinggo.AssertNoError(t, err)
+	return data
+}
+
+func chunks(t *testing.T, data []byte) [][]byte {
+	t.Helper()
+	var result [][]byte
+	testinggo.AssertNoError(t, labgo.ReaderToChunks(bytes.NewReader(data), func(chunk []byte) error {
+		result = append(result, chunk)
+		return nil
+	}))
+	return result
+}
+
+func TestReaderToChunks(t *testing.T) {
+	data := randomBytes(t, 1, 16*1024*1024)
+	original := chunks(t, data)
+	if got := bytes.Join(original, nil); !bytes.Equal(got, data) {
+		t.Fatalf("Incorrect content")
+	}
+	for i, c := range original {
+		if len(c) > labgo.CHUNK_MAX_SIZE || (len(c) < labgo.CHUNK_MIN_SIZE && i != len(original)-1) {
+			t.Fatalf("Incorrect chunk size %d; got '%d'", i, len(c))
+		}
+	}
+
+	// Inserting a byte only changes the first chunk
+	updated := chunks(t, append([]byte{0}, data...))
+	shared := make(map[string]bool)
+	for _, c := range original {
+		shared[string(c)] = true
+	}
+	var count int
+	for _, c := range updated {
+		if shared[string(c)] {
+			count++
+		}
+	}
+	if count != len(original)-1 {
+		t.Fatalf("Incorrect shared chunks; expected '%d', got '%d'", len(original)-1, count)
+	}
+}
+
+func TestReaderToChannel(t *testing.T) {
+	node := makeNode(t, "Alice")
+	cache := node.Cache.(*bcgo.MemoryCache)
+	data := randomBytes(t, 2, 3*1024*1024)
+
+	foo := labgo.OpenFileChannel(node, "foo", labgo.THRESHOLD_DEFAULT)
+	node.AddChannel(foo)
+	testinggo.AssertNoError(t, labgo.ReaderToChannel(node, nil, foo, nil, bytes.NewReader(data)))
+	buffer, err := labgo.ChannelToBuffer(node, foo)
+	testinggo.AssertNoError(t, err)
+	if !bytes.Equal(buffer, data) {
+		t.Fatalf("Incorrect content")
+	}
+
+	// Chunks are only stored once
+	blocks := len(cache.Block)
+	bar := labgo.OpenFileChannel(node, "bar", labgo.THRESHOLD_DEFAULT)
+	node.AddChannel(bar)
+	testinggo.AssertNoError(t, labgo.ReaderToChannel(node, nil, bar, nil, bytes.NewReader(data)))
+	if got := len(cache.Block) - blocks; got != 1 {
+		t.Fatalf("Incorrect blocks; expected '%d', got '%d'", 1, got)
+	}
+	buffer, err = labgo.ChannelToBuffer(node, bar)
+	testinggo.AssertNoError(t, err)
+	if !bytes.Equal(buffer, data) {
+		t.Fatalf("Incorrect content")
+	}
+}
+
+func TestReaderToChannelThreshold(t *testing.T) {
+	node := makeNode(t, "Alice")
+	data := randomBytes(t, 3, 2*labgo.CHUNK_MIN_SIZE)
+	_, err := labgo.CreateFromReader(node, nil, nil, labgo.THRESHOLD_LAN, "foo.txt", ioutil.NopCloser(bytes.NewReader(data)))
+	testinggo.AssertNoError(t, err)
+	count := 0
+	for _, channel := range node.GetChannels() {
+		if !strings.HasPrefix(channel.Name, labgo.LAB_PREFIX_CHUNK) {
+			continue
+		}
+		count++
+		// Chunks are mined at the threshold of the experiment
+		threshold, err := labgo.ChannelThreshold(node.Cache, nil, channel)
+		testinggo.AssertNoError(t, err)
+		if threshold != labgo.THRESHOLD_LAN {
+			t.Fatalf("Incorrect threshold; expected '%d', got '%d'", labgo.THRESHOLD_LAN, threshold)
+		}
+	}
+	if count == 0 {
+		t.Fatalf("Expected chunks")
+	}
+
+	// Chunks shared with an experiment with a higher threshold are mined again at its threshold
+	_, err = labgo.CreateFromReader(node, nil, nil, labgo.THRESHOLD_DEFAULT, "foo.txt", ioutil.NopCloser(bytes.NewReader(data)))
+	testinggo.AssertNoError(t, err)
+	for _, channel := range node.GetChannels() {
+		if !strings.HasPrefix(channel.Name, labgo.LAB_PREFIX_CHUNK) {
+			continue
+		}
+		threshold, err := labgo.ChannelThreshold(node.Cache, nil, channel)
+		testinggo.AssertNoError(t, err)
+		if threshold != labgo.THRESHOLD_DEFAULT {
+			t.Fatalf("Incorrect threshold; expected '%d', got '%d'", labgo.THRESHOLD_DEFAULT, threshold)
+		}
+		if ones := bcgo.Ones(channel.Head); ones < labgo.THRESHOLD_DEFAULT {
+			t.Fatalf("Chunk not mined at threshold; expected '%d', got '%d'", labgo.THRESHOLD_DEFAULT, ones)
+		}
+	}
+}
+
+func TestWriteDelta(t *testing.T) {
+	node := makeNode(t, "Alice")
+	channel := labgo.OpenFileChannel(node, "foobar", labgo.THRESHOLD_DEFAULT)
+	node.AddChannel(channel)
+	data := randomBytes(t, 3, 2*labgo.CHUNK_MIN_SIZE)
+	_, err := labgo.WriteDelta(node, nil, channel, nil, &labgo.Delta{
+		Add: []byte("foobar"),
+	})
+	testinggo.AssertNoError(t, err)
+	_, err = labgo.WriteDelta(node, nil, channel, nil, &labgo.Delta{
+		Offset: 3,
+		Add:    data,
+	})
+	testinggo.AssertNoError(t, err)
+	_, err = labgo.WriteDelta(node, nil, channel, nil, &labgo.Delta{
+		Offset: 3,
+		Remove: data,
+	})
+	testinggo.AssertNoError(t, err)
+	count := 0
+	testinggo.AssertNoError(t, labgo.IterateDeltas(node, channel, func(hash []byte, record *bcgo.Record, delta *labgo.Delta) error {
+		if len(delta.AddChunk) > 0 || len(delta.RemoveChunk) > 0 {
+			t.Fatalf("Expected chunks to be resolved")
+		}
+		count++
+		return nil
+	}))
+	if count != 3 {
+		t.Fatalf("Incorrect deltas; expected '%d', got '%d'", 3, count)
+	}
+	buffer, err := labgo.ChannelToBuffer(node, channel)
+	testinggo.AssertNoError(t, err)
+	if got := string(buffer); got != "foobar" {
+		t.Fatalf("Incorrect content; expected '%s', got '%s'", "foobar", got)
+	}
+}
+
+func TestChunkValidator(t *testing.T) {
+	node := makeNode(t, "Alice")
+	channel := labgo.OpenChunkChannel("foobar", labgo.THRESHOLD_DEFAULT)
+	_, err := labgo.WriteProto(node, nil, channel, nil, nil, &labgo.Chat{
+		Text: "foobar",
+	})
+	testinggo.AssertError(t, fmt.Sprintf(bcgo.ERROR_CHAIN_INVALID, fmt.Sprintf(labgo.ERROR_CHUNK_INVALID, "foobar")), err)
+}
+
+func TestGetChunk_LengthInvalid(t *testing.T) {
+	node := makeNode(t, "Alice")
+	reference, err := labgo.WriteChunk(node, nil, labgo.THRESHOLD_DEFAULT, []byte("foobar"))
+	testinggo.AssertNoError(t, err)
+	data, err := labgo.GetChunk(node, labgo.THRESHOLD_DEFAULT, reference)
+	testinggo.AssertNoError(t, err)
+	if got := string(data); got != "foobar" {
+		t.Fatalf("Incorrect chunk; expected '%s', got '%s'", "foobar", got)
+	}
+	_, err = labgo.GetChunk(node, labgo.THRESHOLD_DEFAULT, &labgo.ChunkReference{
+		Hash:   reference.Hash,
+		Length: 1024,
+	})
+	testinggo.AssertError(t, fmt.Sprintf(labgo.ERROR_CHUNK_LENGTH_INVALID, 6, 1024), err)
+}
@@ -17,7 +17,10 @@
 package main
 
 import (
+	"bufio"
+	"context"
 	"encoding/base64"
+	"encoding/json"
 	"flag"
 	"fmt"
 	"github.com/AletheiaWareLLC/bcgo"
@@ -25,7 +28,10 @@ import (
 	"github.com/AletheiaWareLLC/labgo"
 	"io"
 	"log"
+	"math"
 	"os"
+	"path/filepath"
+	"strings"
 )
 
 var peer = flag.String("peer", "", "Lab peer")
@@ -35,9 +41,23 @@ func PrintUsage(output io.Writer) {
 	fmt.Fprintf(output, "\t%s - display usage\n", os.Args[0])
 	fmt.Fprintf(output, "\t%s init - initializes environment, generates key pair, and registers alias\n", os.Args[0])
 	fmt.Fprintln(output)
-	fmt.Fprintf(output, "\t%s create <path> - creates a new experiment from the given path\n", os.Args[0])
+	fmt.Fprintf(output, "\t%s create [--recipients <alias,alias>] [--lan] <path> - creates a new experiment from the given path, optionally encrypted so only the given aliases can read it, and optionally with a low-cost proof-of-work threshold for sharing on a local network\n", os.Args[0])
 	fmt.Fprintf(output, "\t%s open <experiment> - opens an existing experiment\n", os.Args[0])
-	fmt.Fprintf(output, "\t%s save <experiment> <path> - saves an existing experiment to the given path\n", os.Args[0])
+	fmt.Fprintf(output, "\t%s save [--at <timestamp|blockhash>] [--verify] <experiment> <path> - saves an existing experiment, optionally as it was at the given time, to the given path, optionally refusing records which fail verification\n", os.Args[0])
+	fmt.Fprintf(output, "\t%s sync <experiment> <path> - records the changes made to the given path in an existing experiment\n", os.Args[0])
+	fmt.Fprintf(output, "\t%s log [--alias <alias>] [--path <path>] [--since <timestamp|blockhash>] [--until <timestamp|blockhash>] [--json] <experiment> - displays the changes made to an existing experiment\n", os.Args[0])
+	fmt.Fprintf(output, "\t%s diff <experiment> [from] [to] - displays the differences between two versions, given as timestamps, block hashes, or directories, of an existing experiment\n", os.Args[0])
+	fmt.Fprintf(output, "\t%s blame <experiment> <path> - displays who last changed each line, or byte range, of a file in an existing experiment\n", os.Args[0])
+	fmt.Fprintf(output, "\t%s mv <experiment> <from> <to> - moves a file within an existing experiment\n", os.Args[0])
+	fmt.Fprintf(output, "\t%s rm <experiment> <path> - removes a file from an existing experiment\n", os.Args[0])
+	fmt.Fprintf(output, "\t%s invite [--role role] [--history] <experiment> <alias> - invites the given alias to an existing private experiment, or changes its role, as an owner, editor (default), commenter (draw and chat), or viewer (chat only), and optionally shares its history\n", os.Args[0])
+	fmt.Fprintf(output, "\t%s revoke <experiment> <alias> - stops sharing future records of an existing private experiment with the given alias\n", os.Args[0])
+	fmt.Fprintf(output, "\t%s verify <experiment> - verifies the signature of every record in an existing experiment against the public key registered for its creator, and displays any which fail\n", os.Args[0])
+	fmt.Fprintf(output, "\t%s push [--retry] - pushes the channels mined while peers were unreachable, optionally retrying with increasing delays until every channel has been pushed\n", os.Args[0])
+	fmt.Fprintf(output, "\t%s clean <experiment> - removes an existing experiment from the cache\n", os.Args[0])
+	fmt.Fprintf(output, "\t%s migrate <experiment> <path> - rewrites absolute, or working directory relative, file paths of an existing experiment to be relative to the given path\n", os.Args[0])
+	fmt.Fprintln(output)
+	fmt.Fprintf(output, "\t%s chat <experiment> - displays the chat of an existing experiment and posts each line read from standard input\n", os.Args[0])
 }
 
 func PrintLegalese(output io.Writer) {
@@ -47,7 +67,7 @@ func PrintLegalese(output io.Writer) {
 	fmt.Fprintln(output, "By continuing to use this software you agree to the Terms of Service, Privacy Policy, and Beta Test Agreement.")
 }
 
-func PrintNode(output io.Writer, node *bcgo.Node) error {
+func PrintNode(output io.Writer, node *labgo.Node) error {
 	fmt.Fprintln(output, node.Alias)
 	publicKeyBytes, err := cryptogo.RSAPublicKeyToPKIXBytes(&node.Key.PublicKey)
 	if err != nil {
@@ -57,6 +77,66 @@ func PrintNode(output io.Writer, node *bcgo.Node) error {
 	return nil
 }
 
+func PrintChat(output io.Writer, record *bcgo.Record, chat *labgo.Chat) {
+	fmt.Fprintf(output, "%s %s: %s\n", bcgo.TimestampToString(record.Timestamp), record.Creator, chat.Text)
+}
+
+// GetNode loads the node from the root directory, recording the channels it cannot push to peers in an outbox there, so they are pushed by a later push command.
+func GetNode(rootDir string, cache bcgo.Cache, network bcgo.Network) (*labgo.Node, error) {
+	n, err := bcgo.GetNode(rootDir, cache, network)
+	if err != nil {
+		return nil, err
+	}
+	node := labgo.NewNode(n)
+	outbox, err := labgo.NewFileOutbox(filepath.Join(rootDir, "outbox"))
+	if err != nil {
+		return nil, err
+	}
+	labgo.SetOutbox(node, outbox)
+	return node, nil
+}
+
+// GetTree returns the files of the local directory with the given path, or otherwise of the experiment at the given timestamp or block hash.
+func GetTree(node *labgo.Node, experiment *labgo.Experiment, at string) (labgo.Tree, error) {
+	if info, err := os.Stat(at); err == nil && info.IsDir() {
+		return labgo.DirectoryTree(at)
+	}
+	timestamp, err := labgo.ParseTimestamp(node.Cache, at)
+	if err != nil {
+		return nil, err
+	}
+	return labgo.ExperimentTree(node, experiment, timestamp)
+}
+
+func PrintLogEntry(output io.Writer, entry *labgo.LogEntry) {
+	fmt.Fprintf(output, "%s %s\n", bcgo.TimestampToString(entry.Timestamp), entry)
+}
+
+func PrintLine(output io.Writer, line *labgo.Line) {
+	fmt.Fprintf(output, "%s %s %d: %s\n", bcgo.TimestampToString(line.Record.Timestamp), line.Record.Creator, line.Number, line.Text)
+}
+
+func PrintSpan(output io.Writer, span *labgo.Span) {
+	fmt.Fprintf(output, "%s %s %d-%d (%s)\n", bcgo.TimestampToString(span.Record.Timestamp), span.Record.Creator, span.Offset, span.Offset+span.Length, bcgo.BinarySizeToString(span.Length))
+}
+
+func PrintSyncSummary(output io.Writer, summary *labgo.SyncSummary) {
+	for _, p := range summary.Added {
+		fmt.Fprintln(output, "Added", p)
+	}
+	for _, p := range summary.Modified {
+		fmt.Fprintln(output, "Modified", p)
+	}
+	for _, p := range summary.Deleted {
+		fmt.Fprintln(output, "Deleted", p)
+	}
+}
+
+// SplitPath returns the segments of a path within an experiment, as given on the command line.
+func SplitPath(path string) []string {
+	return strings.Split(strings.Trim(filepath.ToSlash(filepath.Clean(path)), "/"), "/")
+}
+
 func main() {
 	// Parse command line flags
 	flag.Parse()
@@ -111,22 +191,31 @@ func main() {
 				log.Fatal(err)
 			}
 		case "create":
-			if len(args) > 1 {
-				node, err := bcgo.GetNode(rootDir, cache, network)
+			flags := flag.NewFlagSet("create", flag.ExitOnError)
+			recipients := flags.String("recipients", "", "Comma separated aliases to encrypt the experiment for")
+			lan := flags.Bool("lan", false, "Use the low-cost proof-of-work threshold, for experiments shared on a local network")
+			flags.Parse(args[1:])
+			args := flags.Args()
+			if len(args) > 0 {
+				node, err := GetNode(rootDir, cache, network)
 				if err != nil {
 					log.Fatal(err)
 				}
-				experiment, err := labgo.CreateFromPaths(node, &bcgo.PrintingMiningListener{Output: os.Stdout}, args[1])
+				threshold := uint64(labgo.THRESHOLD_PUBLIC)
+				if *lan {
+					threshold = labgo.THRESHOLD_LAN
+				}
+				experiment, err := labgo.CreateFromPaths(node, &bcgo.PrintingMiningListener{Output: os.Stdout}, bcgo.SplitRemoveEmpty(*recipients, ","), threshold, args[0])
 				if err != nil {
 					log.Fatal(err)
 				}
 				log.Println(experiment)
 			} else {
-				log.Fatal("Usage: create [path]")
+				log.Fatal("Usage: create [--recipients alias,alias] [--lan] [path]")
 			}
 		case "open":
 			if len(args) > 1 {
-				node, err := bcgo.GetNode(rootDir, cache, network)
+				node, err := GetNode(rootDir, cache, network)
 				if err != nil {
 					log.Fatal(err)
 				}
@@ -139,8 +228,177 @@ func main() {
 				log.Fatal("Usage: open [experiment]")
 			}
 		case "save":
+			flags := flag.NewFlagSet("save", flag.ExitOnError)
+			at := flags.String("at", "", "Timestamp or block hash to save the experiment as of")
+			verify := flags.Bool("verify", false, "Refuse to save records with unknown aliases or invalid signatures")
+			flags.Parse(args[1:])
+			args := flags.Args()
+			if len(args) > 1 {
+				node, err := GetNode(rootDir, cache, network)
+				if err != nil {
+					log.Fatal(err)
+				}
+				if *verify {
+					labgo.SetVerification(node, labgo.VERIFICATION_REFUSE)
+				}
+				experiment, err := labgo.Open(node, args[0])
+				if err != nil {
+					log.Fatal(err)
+				}
+				if *at == "" {
+					err = labgo.Save(node, experiment, args[1])
+				} else {
+					var timestamp uint64
+					timestamp, err = labgo.ParseTimestamp(cache, *at)
+					if err != nil {
+						log.Fatal(err)
+					}
+					err = labgo.SaveAt(node, experiment, args[1], timestamp)
+				}
+				if err != nil {
+					log.Fatal(err)
+				}
+			} else {
+				log.Fatal("Usage: save [--at timestamp|blockhash] [--verify] [experiment] [path]")
+			}
+		case "sync":
+			if len(args) > 2 {
+				node, err := GetNode(rootDir, cache, network)
+				if err != nil {
+					log.Fatal(err)
+				}
+				experiment, err := labgo.Open(node, args[1])
+				if err != nil {
+					log.Fatal(err)
+				}
+				summary, err := labgo.Sync(node, &bcgo.PrintingMiningListener{Output: os.Stdout}, experiment, args[2])
+				if err != nil {
+					log.Fatal(err)
+				}
+				PrintSyncSummary(os.Stdout, summary)
+			} else {
+				log.Fatal("Usage: sync [experiment] [path]")
+			}
+		case "log":
+			flags := flag.NewFlagSet("log", flag.ExitOnError)
+			alias := flags.String("alias", "", "Only display changes by the given alias")
+			path := flags.String("path", "", "Only display changes to the given file or directory")
+			since := flags.String("since", "", "Only display changes at or after the given timestamp or block hash")
+			until := flags.String("until", "", "Only display changes at or before the given timestamp or block hash")
+			jsonOutput := flags.Bool("json", false, "Display changes as JSON, one per line")
+			flags.Parse(args[1:])
+			args := flags.Args()
+			if len(args) > 0 {
+				node, err := GetNode(rootDir, cache, network)
+				if err != nil {
+					log.Fatal(err)
+				}
+				experiment, err := labgo.Open(node, args[0])
+				if err != nil {
+					log.Fatal(err)
+				}
+				filter := &labgo.LogFilter{
+					Alias: *alias,
+				}
+				if *path != "" {
+					filter.Path = SplitPath(*path)
+				}
+				if *since != "" {
+					filter.Since, err = labgo.ParseTimestamp(cache, *since)
+					if err != nil {
+						log.Fatal(err)
+					}
+				}
+				if *until != "" {
+					filter.Until, err = labgo.ParseTimestamp(cache, *until)
+					if err != nil {
+						log.Fatal(err)
+					}
+				}
+				encoder := json.NewEncoder(os.Stdout)
+				if err := labgo.IterateLog(node, experiment, filter, func(entry *labgo.LogEntry) error {
+					if *jsonOutput {
+						return encoder.Encode(entry)
+					}
+					PrintLogEntry(os.Stdout, entry)
+					return nil
+				}); err != nil {
+					log.Fatal(err)
+				}
+			} else {
+				log.Fatal("Usage: log [--alias alias] [--path path] [--since timestamp|blockhash] [--until timestamp|blockhash] [--json] [experiment]")
+			}
+		case "diff":
+			if len(args) > 1 {
+				node, err := GetNode(rootDir, cache, network)
+				if err != nil {
+					log.Fatal(err)
+				}
+				experiment, err := labgo.Open(node, args[1])
+				if err != nil {
+					log.Fatal(err)
+				}
+				from, err := labgo.ExperimentTree(node, experiment, math.MaxUint64)
+				if err != nil {
+					log.Fatal(err)
+				}
+				var to labgo.Tree
+				switch len(args) {
+				case 2:
+					to, err = labgo.DirectoryTree(".")
+				case 3:
+					from, err = GetTree(node, experiment, args[2])
+					if err == nil {
+						to, err = labgo.ExperimentTree(node, experiment, math.MaxUint64)
+					}
+				default:
+					from, err = GetTree(node, experiment, args[2])
+					if err == nil {
+						to, err = GetTree(node, experiment, args[3])
+					}
+				}
+				if err != nil {
+					log.Fatal(err)
+				}
+				if err := labgo.DiffTrees(from, to, os.Stdout); err != nil {
+					log.Fatal(err)
+				}
+			} else {
+				log.Fatal("Usage: diff [experiment] [from] [to]")
+			}
+		case "blame":
 			if len(args) > 2 {
-				node, err := bcgo.GetNode(rootDir, cache, network)
+				node, err := GetNode(rootDir, cache, network)
+				if err != nil {
+					log.Fatal(err)
+				}
+				experiment, err := labgo.Open(node, args[1])
+				if err != nil {
+					log.Fatal(err)
+				}
+				id, err := labgo.GetFileId(node, experiment.Path, SplitPath(args[2]))
+				if err != nil {
+					log.Fatal(err)
+				}
+				buffer, spans, err := labgo.Blame(node, labgo.GetFileChannel(node, id))
+				if err != nil {
+					log.Fatal(err)
+				}
+				if labgo.IsText(buffer) {
+					for _, l := range labgo.BlameLines(buffer, spans) {
+						PrintLine(os.Stdout, l)
+					}
+				} else {
+					for _, s := range spans {
+						PrintSpan(os.Stdout, s)
+					}
+				}
+			} else {
+				log.Fatal("Usage: blame [experiment] [path]")
+			}
+		case "mv":
+			if len(args) > 3 {
+				node, err := GetNode(rootDir, cache, network)
 				if err != nil {
 					log.Fatal(err)
 				}
@@ -148,11 +406,201 @@ func main() {
 				if err != nil {
 					log.Fatal(err)
 				}
-				if err := labgo.Save(node, experiment, args[2]); err != nil {
+				id, err := labgo.GetFileId(node, experiment.Path, SplitPath(args[2]))
+				if err != nil {
+					log.Fatal(err)
+				}
+				if _, err := labgo.RenamePath(node, &bcgo.PrintingMiningListener{Output: os.Stdout}, experiment.Path, experiment.Access, id, SplitPath(args[3])); err != nil {
+					log.Fatal(err)
+				}
+			} else {
+				log.Fatal("Usage: mv [experiment] [from] [to]")
+			}
+		case "rm":
+			if len(args) > 2 {
+				node, err := GetNode(rootDir, cache, network)
+				if err != nil {
+					log.Fatal(err)
+				}
+				experiment, err := labgo.Open(node, args[1])
+				if err != nil {
+					log.Fatal(err)
+				}
+				id, err := labgo.GetFileId(node, experiment.Path, SplitPath(args[2]))
+				if err != nil {
+					log.Fatal(err)
+				}
+				if _, err := labgo.DeletePath(node, &bcgo.PrintingMiningListener{Output: os.Stdout}, experiment.Path, experiment.Access, id); err != nil {
+					log.Fatal(err)
+				}
+			} else {
+				log.Fatal("Usage: rm [experiment] [path]")
+			}
+		case "invite":
+			flags := flag.NewFlagSet("invite", flag.ExitOnError)
+			history := flags.Bool("history", false, "Share the keys of existing records so the alias can read the experiment's history")
+			roleName := flags.String("role", "editor", "Role of the alias; owner, editor, commenter, or viewer")
+			flags.Parse(args[1:])
+			args := flags.Args()
+			if len(args) > 1 {
+				role, err := labgo.ParseRole(*roleName)
+				if err != nil {
+					log.Fatal(err)
+				}
+				node, err := GetNode(rootDir, cache, network)
+				if err != nil {
+					log.Fatal(err)
+				}
+				experiment, err := labgo.Open(node, args[0])
+				if err != nil {
+					log.Fatal(err)
+				}
+				if err := labgo.Invite(node, &bcgo.PrintingMiningListener{Output: os.Stdout}, experiment, args[1], role, *history); err != nil {
+					log.Fatal(err)
+				}
+				log.Println("Invited", args[1])
+			} else {
+				log.Fatal("Usage: invite [--role role] [--history] [experiment] [alias]")
+			}
+		case "revoke":
+			if len(args) > 2 {
+				node, err := GetNode(rootDir, cache, network)
+				if err != nil {
+					log.Fatal(err)
+				}
+				experiment, err := labgo.Open(node, args[1])
+				if err != nil {
+					log.Fatal(err)
+				}
+				if err := labgo.Revoke(node, &bcgo.PrintingMiningListener{Output: os.Stdout}, experiment, args[2]); err != nil {
+					log.Fatal(err)
+				}
+				log.Println("Revoked", args[2])
+			} else {
+				log.Fatal("Usage: revoke [experiment] [alias]")
+			}
+		case "verify":
+			if len(args) > 1 {
+				node, err := GetNode(rootDir, cache, network)
+				if err != nil {
+					log.Fatal(err)
+				}
+				experiment, err := labgo.Open(node, args[1])
+				if err != nil {
+					log.Fatal(err)
+				}
+				var records, failures int
+				if err := labgo.VerifyExperiment(node, experiment, func(channel string, hash []byte, record *bcgo.Record, err error) error {
+					records++
+					if err != nil {
+						failures++
+						fmt.Println(channel, bcgo.TimestampToString(record.Timestamp), err)
+					}
+					return nil
+				}); err != nil {
+					log.Fatal(err)
+				}
+				fmt.Println("Verified", records-failures, "of", records, "records")
+				if failures > 0 {
+					os.Exit(1)
+				}
+			} else {
+				log.Fatal("Usage: verify [experiment]")
+			}
+		case "push":
+			flags := flag.NewFlagSet("push", flag.ExitOnError)
+			retry := flags.Bool("retry", false, "Retry until every channel has been pushed")
+			flags.Parse(args[1:])
+			node, err := GetNode(rootDir, cache, network)
+			if err != nil {
+				log.Fatal(err)
+			}
+			if *retry {
+				if err := labgo.RetryPush(context.Background(), node, labgo.PUSH_RETRY_MIN, labgo.PUSH_RETRY_MAX); err != nil {
+					log.Fatal(err)
+				}
+				log.Println("Pushed")
+			} else {
+				pending, err := labgo.PushOutbox(node)
+				if pending > 0 {
+					log.Fatal("Channels unpushed: ", pending, " ", err)
+				}
+				if err != nil {
+					log.Fatal(err)
+				}
+				log.Println("Pushed")
+			}
+		case "clean":
+			if len(args) > 1 {
+				node, err := GetNode(rootDir, cache, network)
+				if err != nil {
+					log.Fatal(err)
+				}
+				blocks, size, err := labgo.Clean(node, args[1])
+				if err != nil {
+					log.Fatal(err)
+				}
+				log.Println("Cleaned", blocks, "blocks", bcgo.BinarySizeToString(size))
+			} else {
+				log.Fatal("Usage: clean [experiment]")
+			}
+		case "migrate":
+			if len(args) > 2 {
+				node, err := GetNode(rootDir, cache, network)
+				if err != nil {
+					log.Fatal(err)
+				}
+				experiment, err := labgo.Open(node, args[1])
+				if err != nil {
+					log.Fatal(err)
+				}
+				count, err := labgo.MigratePaths(node, &bcgo.PrintingMiningListener{Output: os.Stdout}, experiment.Path, experiment.Access, args[2])
+				if err != nil {
+					log.Fatal(err)
+				}
+				log.Println("Migrated", count, "paths")
+			} else {
+				log.Fatal("Usage: migrate [experiment] [path]")
+			}
+		case "chat":
+			if len(args) > 1 {
+				node, err := GetNode(rootDir, cache, network)
+				if err != nil {
+					log.Fatal(err)
+				}
+				experiment, err := labgo.Open(node, args[1])
+				if err != nil {
+					log.Fatal(err)
+				}
+				print := func(hash []byte, record *bcgo.Record, chat *labgo.Chat) error {
+					PrintChat(os.Stdout, record, chat)
+					return nil
+				}
+				// Display history
+				if err := labgo.IterateChat(node, experiment.Chat, print); err != nil {
+					log.Fatal(err)
+				}
+				// Display new messages, including those broadcast by peers
+				labgo.SubscribeChat(node, experiment.Chat, print)
+				ctx, cancel := context.WithCancel(context.Background())
+				defer cancel()
+				labgo.Serve(ctx, node, cache, network)
+				// Post each line
+				scanner := bufio.NewScanner(os.Stdin)
+				for scanner.Scan() {
+					text := strings.TrimSpace(scanner.Text())
+					if text == "" {
+						continue
+					}
+					if _, err := labgo.PostChat(node, nil, experiment.Chat, experiment.Access, text); err != nil {
+						log.Println(err)
+					}
+				}
+				if err := scanner.Err(); err != nil {
 					log.Fatal(err)
 				}
 			} else {
-				log.Fatal("Usage: save [experiment] [path]")
+				log.Fatal("Usage: chat [experiment]")
 			}
 		default:
 			log.Fatal("Cannot handle: ", args[0])
@@ -0,0 +1,122 @@
+/*
+ * Copyright 2020 Aletheia Ware LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package labgo
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+)
+
+const (
+	// Deltas removing and adding fewer bytes than this are not worth compressing
+	COMPRESSION_MIN_SIZE = 128
+
+	ERROR_COMPRESSION_UNRECOGNIZED = "Compression unrecognized: %s"
+	ERROR_DECOMPRESSION_TOO_LARGE  = "Decompressed data exceeds %d bytes"
+)
+
+// CompressDelta compresses the bytes removed and added by the delta with gzip, if doing so makes them smaller.
+// Chunks are not compressed so they continue to be shared by hash.
+func CompressDelta(delta *Delta) error {
+	if delta.Compression != Compression_UNCOMPRESSED {
+		return nil
+	}
+	size := len(delta.Remove) + len(delta.Add)
+	if size < COMPRESSION_MIN_SIZE {
+		return nil
+	}
+	remove, err := gzipBytes(delta.Remove)
+	if err != nil {
+		return err
+	}
+	add, err := gzipBytes(delta.Add)
+	if err != nil {
+		return err
+	}
+	if len(remove)+len(add) >= size {
+		return nil
+	}
+	delta.Remove = remove
+	delta.Add = add
+	delta.Compression = Compression_GZIP
+	return nil
+}
+
+// DecompressDelta replaces the compressed bytes removed and added by the delta with the original bytes.
+func DecompressDelta(delta *Delta) error {
+	switch delta.Compression {
+	case Compression_UNCOMPRESSED:
+		return nil
+	case Compression_GZIP:
+		remove, err := gunzipBytes(delta.Remove)
+		if err != nil {
+			return err
+		}
+		add, err := gunzipBytes(delta.Add)
+		if err != nil {
+			return err
+		}
+		delta.Remove = remove
+		delta.Add = add
+		delta.Compression = Compression_UNCOMPRESSED
+		return nil
+	default:
+		return errors.New(fmt.Sprintf(ERROR_COMPRESSION_UNRECOGNIZED, delta.Compression))
+	}
+}
+
+func gzipBytes(data []byte) ([]byte, error) {
+	if len(data) == 0 {
+		return nil, nil
+	}
+	var buffer bytes.Buffer
+	writer, err := gzip.NewWriterLevel(&buffer, gzip.BestCompression)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := writer.Write(data); err != nil {
+		return nil, err
+	}
+	if err := writer.Close(); err != nil {
+		return nil, err
+	}
+	return buffer.Bytes(), nil
+}
+
+func gunzipBytes(data []byte) ([]byte, error) {
+	if len(data) == 0 {
+		return nil, nil
+	}
+	reader, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+	// Limit the decompressed size so a small record cannot expand without bound
+	result, err := ioutil.ReadAll(io.LimitReader(reader, int64(MAX_DELTA_LENGTH)+1))
+	if err != nil {
+		return nil, err
+	}
+	if uint64(len(result)) > MAX_DELTA_LENGTH {
+		return nil, errors.New(fmt.Sprintf(ERROR_DECOMPRESSION_TOO_LARGE, MAX_DELTA_LENGTH))
+	}
+	return result, nil
+}
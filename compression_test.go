@@ -0,0 +1,144 @@
+/*
+ * Copyright 2020 Aletheia Ware LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package labgo_test
+
+import (
+	"bytes"
+	"github.com/AletheiaWareLLC/bcgo"
+	"github.com/AletheiaWareLLC/labgo"
+	"github.com/AletheiaWareLLC/testinggo"
+	"github.com/golang/protobuf/proto"
+	"testing"
+)
+
+func TestCompressDelta(t *testing.T) {
+	t.Run("Text", func(t *testing.T) {
+		remove := bytes.Repeat([]byte("foo\n"), 100)
+		add := bytes.Repeat([]byte("bar\n"), 100)
+		delta := &labgo.Delta{
+			Offset: 5,
+			Remove: remove,
+			Add:    add,
+		}
+		testinggo.AssertNoError(t, labgo.CompressDelta(delta))
+		if delta.Compression != labgo.Compression_GZIP {
+			t.Fatalf("Incorrect compression; expected '%s', got '%s'", labgo.Compression_GZIP, delta.Compression)
+		}
+		if len(delta.Remove)+len(delta.Add) >= len(remove)+len(add) {
+			t.Fatalf("Delta not smaller; got '%d'", len(delta.Remove)+len(delta.Add))
+		}
+		testinggo.AssertNoError(t, labgo.DecompressDelta(delta))
+		if delta.Compression != labgo.Compression_UNCOMPRESSED {
+			t.Fatalf("Incorrect compression; expected '%s', got '%s'", labgo.Compression_UNCOMPRESSED, delta.Compression)
+		}
+		if !bytes.Equal(delta.Remove, remove) {
+			t.Fatalf("Incorrect remove")
+		}
+		if !bytes.Equal(delta.Add, add) {
+			t.Fatalf("Incorrect add")
+		}
+	})
+	t.Run("Small", func(t *testing.T) {
+		delta := &labgo.Delta{
+			Add: []byte("foobar"),
+		}
+		testinggo.AssertNoError(t, labgo.CompressDelta(delta))
+		if delta.Compression != labgo.Compression_UNCOMPRESSED {
+			t.Fatalf("Incorrect compression; expected '%s', got '%s'", labgo.Compression_UNCOMPRESSED, delta.Compression)
+		}
+	})
+	t.Run("Random", func(t *testing.T) {
+		data := randomBytes(t, 1, 1024)
+		delta := &labgo.Delta{
+			Add: data,
+		}
+		testinggo.AssertNoError(t, labgo.CompressDelta(delta))
+		if delta.Compression != labgo.Compression_UNCOMPRESSED {
+			t.Fatalf("Incorrect compression; expected '%s', got '%s'", labgo.Compression_UNCOMPRESSED, delta.Compression)
+		}
+		if !bytes.Equal(delta.Add, data) {
+			t.Fatalf("Incorrect add")
+		}
+	})
+}
+
+func TestDecompressDelta(t *testing.T) {
+	t.Run("Invalid", func(t *testing.T) {
+		testinggo.AssertError(t, "unexpected EOF", labgo.DecompressDelta(&labgo.Delta{
+			Add:         []byte{0x1f, 0x8b},
+			Compression: labgo.Compression_GZIP,
+		}))
+	})
+	t.Run("Unrecognized", func(t *testing.T) {
+		testinggo.AssertError(t, "Compression unrecognized: 9", labgo.DecompressDelta(&labgo.Delta{
+			Compression: labgo.Compression(9),
+		}))
+	})
+}
+
+func TestWriteDelta_Compressed(t *testing.T) {
+	node := makeNode(t, "Alice")
+	channel := labgo.OpenFileChannel(node, "foobar", labgo.THRESHOLD_DEFAULT)
+	node.AddChannel(channel)
+	text := bytes.Repeat([]byte("Hello World\n"), 100)
+	_, err := labgo.WriteDelta(node, nil, channel, nil, &labgo.Delta{
+		Add: text,
+	})
+	testinggo.AssertNoError(t, err)
+	_, err = labgo.WriteDelta(node, nil, channel, nil, &labgo.Delta{
+		Offset: 12,
+		Remove: text[12:],
+	})
+	testinggo.AssertNoError(t, err)
+
+	// Records are stored compressed
+	testinggo.AssertNoError(t, bcgo.Iterate(channel.Name, channel.Head, nil, node.Cache, node.Network, func(hash []byte, block *bcgo.Block) error {
+		for _, entry := range block.Entry {
+			if len(entry.Record.Payload) >= len(text)-12 {
+				t.Fatalf("Payload not compressed; got '%d'", len(entry.Record.Payload))
+			}
+			d := &labgo.Delta{}
+			testinggo.AssertNoError(t, proto.Unmarshal(entry.Record.Payload, d))
+			if d.Compression != labgo.Compression_GZIP {
+				t.Fatalf("Incorrect compression; expected '%s', got '%s'", labgo.Compression_GZIP, d.Compression)
+			}
+		}
+		return nil
+	}))
+
+	// Deltas are read decompressed
+	var deltas []*labgo.Delta
+	testinggo.AssertNoError(t, labgo.IterateDeltas(node, channel, func(hash []byte, record *bcgo.Record, delta *labgo.Delta) error {
+		deltas = append(deltas, delta)
+		return nil
+	}))
+	if len(deltas) != 2 {
+		t.Fatalf("Incorrect deltas; expected 2, got '%d'", len(deltas))
+	}
+	if !bytes.Equal(deltas[0].Add, text) {
+		t.Fatalf("Incorrect add")
+	}
+	if !bytes.Equal(deltas[1].Remove, text[12:]) {
+		t.Fatalf("Incorrect remove")
+	}
+
+	buffer, err := labgo.ChannelToBuffer(node, channel)
+	testinggo.AssertNoError(t, err)
+	if string(buffer) != "Hello World\n" {
+		t.Fatalf("Incorrect content; expected 'Hello World\n', got '%s'", buffer)
+	}
+}
@@ -0,0 +1,184 @@
+/*
+ * Copyright 2020 Aletheia Ware LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package labgo
+
+const (
+	// Upper bound on the work (sequence length * edit distance) spent searching for a shortest edit script, beyond which the remaining region is treated as replaced
+	MAX_DIFF_WORK = 1 << 28
+)
+
+// Match describes a run of Length equal elements starting at index A in the first sequence and index B in the second.
+type Match struct {
+	A, B, Length int
+}
+
+// Compare finds the longest common subsequence of two sequences of length n and m, using the linear space variant of Myers' O(ND) algorithm.
+// The equal function reports whether the element at index i of the first sequence matches that at index j of the second.
+// The returned matches are in ascending order and never adjacent.
+func Compare(n, m int, equal func(i, j int) bool) []Match {
+	d := &differ{
+		equal: equal,
+	}
+	d.compare(0, n, 0, m)
+	return d.matches
+}
+
+type differ struct {
+	equal   func(i, j int) bool
+	matches []Match
+}
+
+func (d *differ) add(a, b, length int) {
+	if length <= 0 {
+		return
+	}
+	if l := len(d.matches); l > 0 {
+		last := &d.matches[l-1]
+		if last.A+last.Length == a && last.B+last.Length == b {
+			last.Length += length
+			return
+		}
+	}
+	d.matches = append(d.matches, Match{
+		A:      a,
+		B:      b,
+		Length: length,
+	})
+}
+
+func (d *differ) compare(a0, a1, b0, b1 int) {
+	// Skip common prefix
+	prefix := 0
+	for a0+prefix < a1 && b0+prefix < b1 && d.equal(a0+prefix, b0+prefix) {
+		prefix++
+	}
+	d.add(a0, b0, prefix)
+	a0 += prefix
+	b0 += prefix
+
+	// Skip common suffix
+	suffix := 0
+	for a0 < a1-suffix && b0 < b1-suffix && d.equal(a1-suffix-1, b1-suffix-1) {
+		suffix++
+	}
+	a1 -= suffix
+	b1 -= suffix
+
+	if a0 < a1 && b0 < b1 {
+		if x, y, ok := d.bisect(a0, a1, b0, b1); ok {
+			d.compare(a0, x, b0, y)
+			d.compare(x, a1, y, b1)
+		}
+	}
+
+	d.add(a1, b1, suffix)
+}
+
+// bisect finds the middle snake of the region and returns the point at which to split it, or false if the region should be treated as replaced.
+func (d *differ) bisect(a0, a1, b0, b1 int) (int, int, bool) {
+	n := a1 - a0
+	m := b1 - b0
+	max := (n + m + 1) / 2
+	if limit := MAX_DIFF_WORK / (n + m); max > limit {
+		max = limit
+	}
+	if max < 1 {
+		return 0, 0, false
+	}
+	offset := max
+	length := 2*max + 2
+	forward := make([]int, length)
+	backward := make([]int, length)
+	for i := range forward {
+		forward[i] = -1
+		backward[i] = -1
+	}
+	forward[offset+1] = 0
+	backward[offset+1] = 0
+	delta := n - m
+	// If the total number of elements is odd, the front path will collide with the reverse path
+	front := delta%2 != 0
+	var k1start, k1end, k2start, k2end int
+	for step := 0; step < max; step++ {
+		// Walk the front path one step
+		for k1 := -step + k1start; k1 <= step-k1end; k1 += 2 {
+			k1offset := offset + k1
+			var x1 int
+			if k1 == -step || (k1 != step && forward[k1offset-1] < forward[k1offset+1]) {
+				x1 = forward[k1offset+1]
+			} else {
+				x1 = forward[k1offset-1] + 1
+			}
+			y1 := x1 - k1
+			for x1 < n && y1 < m && d.equal(a0+x1, b0+y1) {
+				x1++
+				y1++
+			}
+			forward[k1offset] = x1
+			if x1 > n {
+				// Ran off the right of the graph
+				k1end += 2
+			} else if y1 > m {
+				// Ran off the bottom of the graph
+				k1start += 2
+			} else if front {
+				k2offset := offset + delta - k1
+				if k2offset >= 0 && k2offset < length && backward[k2offset] != -1 {
+					// Mirror x2 onto top-left coordinate system
+					if x1 >= n-backward[k2offset] {
+						return a0 + x1, b0 + y1, true
+					}
+				}
+			}
+		}
+		// Walk the reverse path one step
+		for k2 := -step + k2start; k2 <= step-k2end; k2 += 2 {
+			k2offset := offset + k2
+			var x2 int
+			if k2 == -step || (k2 != step && backward[k2offset-1] < backward[k2offset+1]) {
+				x2 = backward[k2offset+1]
+			} else {
+				x2 = backward[k2offset-1] + 1
+			}
+			y2 := x2 - k2
+			for x2 < n && y2 < m && d.equal(a1-x2-1, b1-y2-1) {
+				x2++
+				y2++
+			}
+			backward[k2offset] = x2
+			if x2 > n {
+				// Ran off the left of the graph
+				k2end += 2
+			} else if y2 > m {
+				// Ran off the top of the graph
+				k2start += 2
+			} else if !front {
+				k1offset := offset + delta - k2
+				if k1offset >= 0 && k1offset < length && forward[k1offset] != -1 {
+					x1 := forward[k1offset]
+					y1 := offset + x1 - k1offset
+					// Mirror x2 onto top-left coordinate system
+					if x1 >= n-x2 {
+						return a0 + x1, b0 + y1, true
+					}
+				}
+			}
+		}
+	}
+	// No overlap within the work limit
+	return 0, 0, false
+}
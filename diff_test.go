@@ -0,0 +1,149 @@
+/*
+ * Copyright 2020 Aletheia Ware LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package labgo_test
+
+import (
+	"github.com/AletheiaWareLLC/labgo"
+	"math/rand"
+	"testing"
+)
+
+func lcs(a, b string) int {
+	table := make([][]int, len(a)+1)
+	for i := range table {
+		table[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				table[i][j] = table[i+1][j+1] + 1
+			} else if table[i+1][j] > table[i][j+1] {
+				table[i][j] = table[i+1][j]
+			} else {
+				table[i][j] = table[i][j+1]
+			}
+		}
+	}
+	return table[0][0]
+}
+
+func assertMatches(t *testing.T, a, b string, matches []labgo.Match) {
+	t.Helper()
+	var i, j, total int
+	for _, m := range matches {
+		if m.A < i || m.B < j {
+			t.Fatalf("Matches out of order; expected '%d,%d' or later, got '%d,%d'", i, j, m.A, m.B)
+		}
+		if m.Length <= 0 {
+			t.Fatalf("Incorrect match length; expected positive, got '%d'", m.Length)
+		}
+		if a[m.A:m.A+m.Length] != b[m.B:m.B+m.Length] {
+			t.Fatalf("Incorrect match; expected '%s', got '%s'", a[m.A:m.A+m.Length], b[m.B:m.B+m.Length])
+		}
+		i = m.A + m.Length
+		j = m.B + m.Length
+		total += m.Length
+	}
+	if expected := lcs(a, b); total != expected {
+		t.Fatalf("Incorrect common subsequence length; expected '%d', got '%d'", expected, total)
+	}
+}
+
+func TestCompare(t *testing.T) {
+	tests := []struct {
+		name string
+		a    string
+		b    string
+		want []labgo.Match
+	}{
+		{
+			name: "Empty",
+		},
+		{
+			name: "Equal",
+			a:    "foobar",
+			b:    "foobar",
+			want: []labgo.Match{
+				{0, 0, 6},
+			},
+		},
+		{
+			name: "Different",
+			a:    "foo",
+			b:    "bar",
+		},
+		{
+			name: "Insert",
+			a:    "foobar",
+			b:    "fooblahbar",
+			want: []labgo.Match{
+				{0, 0, 4},
+				{4, 8, 2},
+			},
+		},
+		{
+			name: "Remove",
+			a:    "fooblahbar",
+			b:    "foobar",
+			want: []labgo.Match{
+				{0, 0, 4},
+				{8, 4, 2},
+			},
+		},
+		{
+			name: "Middle",
+			a:    "abcabba",
+			b:    "cbabac",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := labgo.Compare(len(tt.a), len(tt.b), func(i, j int) bool {
+				return tt.a[i] == tt.b[j]
+			})
+			assertMatches(t, tt.a, tt.b, got)
+			if tt.want != nil {
+				if len(got) != len(tt.want) {
+					t.Fatalf("Wrong number of matches; expected '%d', got '%d'", len(tt.want), len(got))
+				}
+				for i, w := range tt.want {
+					if got[i] != w {
+						t.Fatalf("Incorrect match; expected '%v', got '%v'", w, got[i])
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestCompare_Random(t *testing.T) {
+	random := rand.New(rand.NewSource(0))
+	generate := func() string {
+		data := make([]byte, random.Intn(64))
+		for i := range data {
+			data[i] = "abc"[random.Intn(3)]
+		}
+		return string(data)
+	}
+	for i := 0; i < 500; i++ {
+		a := generate()
+		b := generate()
+		assertMatches(t, a, b, labgo.Compare(len(a), len(b), func(i, j int) bool {
+			return a[i] == b[j]
+		}))
+	}
+}
@@ -0,0 +1,259 @@
+/*
+ * Copyright 2020 Aletheia Ware LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package labgo
+
+import (
+	"bytes"
+	"crypto/rsa"
+	"errors"
+	"github.com/AletheiaWareLLC/bcgo"
+	"github.com/golang/protobuf/proto"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"io"
+	"math"
+)
+
+const (
+	// Largest magnitude of a coordinate, bounding the size of the canvas
+	MAX_DRAW_COORDINATE = 2048
+	// Largest number of coordinates in a stroke
+	MAX_DRAW_POINTS = 4096
+	// Largest width of a stroke
+	MAX_DRAW_SIZE = 64
+
+	ERROR_NOTHING_TO_UNDO = "Nothing to undo"
+)
+
+// Stroke is a Draw record visible on the canvas.
+type Stroke struct {
+	Hash   []byte
+	Record *bcgo.Record
+	Draw   *Draw
+}
+
+// AddStroke writes the given stroke to the draw channel.
+// Points are interpreted as a sequence of x, y coordinate pairs.
+func AddStroke(node *Node, listener bcgo.MiningListener, channel *bcgo.Channel, acl map[string]*rsa.PublicKey, stroke *Draw) ([]byte, error) {
+	return WriteProto(node, listener, channel, acl, nil, stroke)
+}
+
+// UndoStroke removes the most recent visible stroke drawn by the node's alias from the canvas.
+// An undo is a Draw record without points which references the stroke being undone.
+func UndoStroke(node *Node, listener bcgo.MiningListener, channel *bcgo.Channel, acl map[string]*rsa.PublicKey) ([]byte, error) {
+	strokes, err := ReplayCanvas(node, channel)
+	if err != nil {
+		return nil, err
+	}
+	for i := len(strokes) - 1; i >= 0; i-- {
+		s := strokes[i]
+		if s.Record.Creator == node.Alias {
+			return WriteProto(node, listener, channel, acl, []*bcgo.Reference{
+				&bcgo.Reference{
+					Timestamp:   s.Record.Timestamp,
+					ChannelName: channel.Name,
+					RecordHash:  s.Hash,
+				},
+			}, &Draw{})
+		}
+	}
+	return nil, errors.New(ERROR_NOTHING_TO_UNDO)
+}
+
+// IterateDraws calls the callback with each record in the draw channel, oldest first.
+func IterateDraws(node *Node, channel *bcgo.Channel, callback func([]byte, *bcgo.Record, *Draw) error) error {
+	// Iterate through chain chronologically
+	return bcgo.IterateChronologically(channel.Name, channel.Head, nil, node.Cache, node.Network, func(hash []byte, block *bcgo.Block) error {
+		for _, entry := range block.Entry {
+			payload, err := readPayload(node, entry)
+			if err != nil {
+				return err
+			}
+			// Unmarshal as Draw
+			d := &Draw{}
+			if err := proto.Unmarshal(payload, d); err != nil {
+				return err
+			}
+			if err := callback(entry.RecordHash, entry.Record, d); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// ReplayCanvas returns the strokes visible on the canvas in the order they were drawn, omitting those which have been undone.
+func ReplayCanvas(node *Node, channel *bcgo.Channel) ([]*Stroke, error) {
+	var strokes []*Stroke
+	if err := IterateDraws(node, channel, func(hash []byte, record *bcgo.Record, d *Draw) error {
+		if len(d.Points) == 0 && len(record.Reference) > 0 {
+			// Undo referenced strokes drawn by the same alias
+			for _, r := range record.Reference {
+				for i, s := range strokes {
+					if bytes.Equal(s.Hash, r.RecordHash) && s.Record.Creator == record.Creator {
+						strokes = append(strokes[:i], strokes[i+1:]...)
+						break
+					}
+				}
+			}
+			return nil
+		}
+		strokes = append(strokes, &Stroke{
+			Hash:   hash,
+			Record: record,
+			Draw:   d,
+		})
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+	return strokes, nil
+}
+
+// RenderCanvas draws the strokes onto a transparent image just large enough to contain them.
+// Coordinates and sizes beyond those permitted by ValidateDraw are clamped.
+func RenderCanvas(strokes []*Stroke) *image.NRGBA {
+	bounds := image.Rectangle{}
+	for _, s := range strokes {
+		bounds = bounds.Union(strokeBounds(s.Draw))
+	}
+	if bounds.Empty() {
+		bounds = image.Rect(0, 0, 1, 1)
+	}
+	canvas := image.NewNRGBA(bounds)
+	mask := image.NewAlpha(bounds)
+	for _, s := range strokes {
+		area := strokeBounds(s.Draw)
+		radius := strokeRadius(s.Draw)
+		points := strokePoints(s.Draw)
+		for i, p := range points {
+			if i+1 < len(points) {
+				stampLine(mask, p, points[i+1], radius)
+			} else if i == 0 {
+				stampLine(mask, p, p, radius)
+			}
+		}
+		draw.DrawMask(canvas, area, image.NewUniform(strokeColor(s.Draw)), image.Point{}, mask, area.Min, draw.Over)
+		// Clear the area of the mask used by this stroke
+		draw.Draw(mask, area, image.Transparent, image.Point{}, draw.Src)
+	}
+	return canvas
+}
+
+// CanvasToPNG renders the strokes and encodes the result as a PNG.
+func CanvasToPNG(strokes []*Stroke, writer io.Writer) error {
+	return png.Encode(writer, RenderCanvas(strokes))
+}
+
+func strokeRadius(d *Draw) int {
+	return min(int(d.Size), MAX_DRAW_SIZE) / 2
+}
+
+func strokePoints(d *Draw) []image.Point {
+	var points []image.Point
+	for i := 0; i+1 < len(d.Points) && i < MAX_DRAW_POINTS; i += 2 {
+		points = append(points, image.Pt(clampCoordinate(d.Points[i]), clampCoordinate(d.Points[i+1])))
+	}
+	return points
+}
+
+func clampCoordinate(c int32) int {
+	return max(min(int(c), MAX_DRAW_COORDINATE), -MAX_DRAW_COORDINATE)
+}
+
+func strokeBounds(d *Draw) image.Rectangle {
+	bounds := image.Rectangle{}
+	radius := strokeRadius(d)
+	for _, p := range strokePoints(d) {
+		bounds = bounds.Union(image.Rect(p.X-radius, p.Y-radius, p.X+radius+1, p.Y+radius+1))
+	}
+	return bounds
+}
+
+func strokeColor(d *Draw) color.Color {
+	c := d.Color
+	if c == nil {
+		// Default to opaque black
+		return color.Black
+	}
+	return color.NRGBA{
+		R: channelValue(c.Red),
+		G: channelValue(c.Green),
+		B: channelValue(c.Blue),
+		A: channelValue(c.Alpha),
+	}
+}
+
+func channelValue(value uint32) uint8 {
+	if value > 0xff {
+		return 0xff
+	}
+	return uint8(value)
+}
+
+// stampLine sets the pixels of the mask within the radius of the line between the given points, a row at a time.
+func stampLine(mask *image.Alpha, p0, p1 image.Point, radius int) {
+	// Pixels within half a pixel of a line without width are connected
+	r := math.Max(float64(radius), 0.5)
+	x0, y0 := float64(p0.X), float64(p0.Y)
+	dx, dy := float64(p1.X-p0.X), float64(p1.Y-p0.Y)
+	length := math.Hypot(dx, dy)
+	area := image.Rect(p0.X, p0.Y, p1.X, p1.Y).Canon()
+	area = image.Rect(area.Min.X-radius, area.Min.Y-radius, area.Max.X+radius+1, area.Max.Y+radius+1).Intersect(mask.Rect)
+	for y := area.Min.Y; y < area.Max.Y; y++ {
+		fy := float64(y)
+		lo, hi := math.Inf(1), math.Inf(-1)
+		// Circles at either end of the line
+		for _, c := range []image.Point{p0, p1} {
+			if h := r*r - (fy-float64(c.Y))*(fy-float64(c.Y)); h >= 0 {
+				w := math.Sqrt(h)
+				lo, hi = math.Min(lo, float64(c.X)-w), math.Max(hi, float64(c.X)+w)
+			}
+		}
+		if length > 0 {
+			// Pixels which project onto the line, and are within the radius of it
+			pl, ph := solveBetween(dx, (fy-y0)*dy-x0*dx, 0, length*length)
+			dl, dh := solveBetween(dy, -(fy-y0)*dx-x0*dy, -r*length, r*length)
+			if l, h := math.Max(pl, dl), math.Min(ph, dh); l <= h {
+				lo, hi = math.Min(lo, l), math.Max(hi, h)
+			}
+		}
+		if lo > hi {
+			continue
+		}
+		for x := max(int(math.Ceil(lo)), area.Min.X); x <= min(int(math.Floor(hi)), area.Max.X-1); x++ {
+			mask.SetAlpha(x, y, color.Alpha{A: 0xff})
+		}
+	}
+}
+
+// solveBetween returns the range of x for which lo <= a*x+b <= hi.
+func solveBetween(a, b, lo, hi float64) (float64, float64) {
+	if a == 0 {
+		if b >= lo && b <= hi {
+			return math.Inf(-1), math.Inf(1)
+		}
+		return math.Inf(1), math.Inf(-1)
+	}
+	l, h := (lo-b)/a, (hi-b)/a
+	if a < 0 {
+		l, h = h, l
+	}
+	return l, h
+}
@@ -0,0 +1,120 @@
+/*
+ * Copyright 2020 Aletheia Ware LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package labgo_test
+
+import (
+	"bytes"
+	"github.com/AletheiaWareLLC/labgo"
+	"github.com/AletheiaWareLLC/testinggo"
+	"image/color"
+	"image/png"
+	"testing"
+)
+
+func TestDraw(t *testing.T) {
+	node := makeNode(t, "Alice")
+	experiment, err := labgo.CreateFromReader(node, nil, nil, labgo.THRESHOLD_LAN, "", nil)
+	testinggo.AssertNoError(t, err)
+	channel := experiment.Draw
+
+	_, err = labgo.UndoStroke(node, nil, channel, nil)
+	testinggo.AssertError(t, labgo.ERROR_NOTHING_TO_UNDO, err)
+
+	red := &labgo.Draw{
+		Color: &labgo.RGBA{
+			Red:   255,
+			Alpha: 255,
+		},
+		Size:   1,
+		Points: []int32{0, 0, 9, 0},
+	}
+	blue := &labgo.Draw{
+		Color: &labgo.RGBA{
+			Blue:  255,
+			Alpha: 255,
+		},
+		Size:   1,
+		Points: []int32{0, 0, 0, 9},
+	}
+	_, err = labgo.AddStroke(node, nil, channel, nil, red)
+	testinggo.AssertNoError(t, err)
+	_, err = labgo.AddStroke(node, nil, channel, nil, blue)
+	testinggo.AssertNoError(t, err)
+
+	strokes, err := labgo.ReplayCanvas(node, channel)
+	testinggo.AssertNoError(t, err)
+	if len(strokes) != 2 {
+		t.Fatalf("Wrong number of strokes; expected '%d', got '%d'", 2, len(strokes))
+	}
+	canvas := labgo.RenderCanvas(strokes)
+	if got := canvas.Bounds().Dx(); got != 10 {
+		t.Fatalf("Incorrect width; expected '%d', got '%d'", 10, got)
+	}
+	if got := canvas.Bounds().Dy(); got != 10 {
+		t.Fatalf("Incorrect height; expected '%d', got '%d'", 10, got)
+	}
+	for _, p := range []struct {
+		x, y int
+		c    color.NRGBA
+	}{
+		{0, 0, color.NRGBA{B: 255, A: 255}},
+		{9, 0, color.NRGBA{R: 255, A: 255}},
+		{0, 9, color.NRGBA{B: 255, A: 255}},
+		{9, 9, color.NRGBA{}},
+	} {
+		if got := canvas.NRGBAAt(p.x, p.y); got != p.c {
+			t.Fatalf("Incorrect color at %d,%d; expected '%v', got '%v'", p.x, p.y, p.c, got)
+		}
+	}
+
+	_, err = labgo.UndoStroke(node, nil, channel, nil)
+	testinggo.AssertNoError(t, err)
+
+	strokes, err = labgo.ReplayCanvas(node, channel)
+	testinggo.AssertNoError(t, err)
+	if len(strokes) != 1 {
+		t.Fatalf("Wrong number of strokes; expected '%d', got '%d'", 1, len(strokes))
+	}
+	testinggo.AssertProtobufEqual(t, red, strokes[0].Draw)
+
+	var buffer bytes.Buffer
+	testinggo.AssertNoError(t, labgo.CanvasToPNG(strokes, &buffer))
+	image, err := png.Decode(&buffer)
+	testinggo.AssertNoError(t, err)
+	if got := image.Bounds().Dx(); got != 10 {
+		t.Fatalf("Incorrect width; expected '%d', got '%d'", 10, got)
+	}
+}
+
+func TestRenderCanvasClamped(t *testing.T) {
+	canvas := labgo.RenderCanvas([]*labgo.Stroke{
+		{
+			Draw: &labgo.Draw{
+				Size:   4294967295,
+				Points: []int32{-2147483648, -2147483648, 2147483647, 2147483647},
+			},
+		},
+	})
+	radius := labgo.MAX_DRAW_SIZE / 2
+	expected := 2*(labgo.MAX_DRAW_COORDINATE+radius) + 1
+	if got := canvas.Bounds().Dx(); got != expected {
+		t.Fatalf("Incorrect width; expected '%d', got '%d'", expected, got)
+	}
+	if got := canvas.NRGBAAt(0, 0); got != (color.NRGBA{A: 255}) {
+		t.Fatalf("Incorrect color; expected '%v', got '%v'", color.NRGBA{A: 255}, got)
+	}
+}
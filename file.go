@@ -22,11 +22,15 @@ import (
 	"github.com/AletheiaWareLLC/bcgo"
 	"github.com/golang/protobuf/proto"
 	"io"
+	"io/ioutil"
+	"math"
 	"os"
 )
 
 const (
 	MAX_DELTA_LENGTH = uint64(8 * 1024 * 1024) // 8Mb
+	// Equalities shorter than this between two changes are folded into a single delta, as another record costs more than the repeated bytes
+	MIN_EQUAL_LENGTH = 32
 )
 
 func PathToDeltas(path string, max uint64, callback func(*Delta) error) error {
@@ -62,15 +66,90 @@ func ReaderToDeltas(reader io.Reader, max uint64, callback func(*Delta) error) e
 	return nil
 }
 
-func IterateDeltas(node *bcgo.Node, delta *bcgo.Channel, callback func([]byte, *bcgo.Record, *Delta) error) error {
+// DiffToDeltas calls the callback with the sequence of deltas that transforms the original buffer into the updated buffer.
+// Each delta's offset is relative to the result of applying all the deltas before it.
+// The bytes removed and added by each delta together do not exceed the given max, unless it is zero.
+func DiffToDeltas(original, updated []byte, max uint64, callback func(*Delta) error) error {
+	matches := Compare(len(original), len(updated), func(i, j int) bool {
+		return original[i] == updated[j]
+	})
+	var a, b int
+	for _, m := range append(matches, Match{
+		A: len(original),
+		B: len(updated),
+	}) {
+		changed := m.A > a || m.B > b
+		last := m.A+m.Length == len(original) && m.B+m.Length == len(updated)
+		if changed && !last && m.Length < MIN_EQUAL_LENGTH {
+			// Fold short equality into next delta
+			continue
+		}
+		if changed {
+			if err := splitDelta(uint64(b), original[a:m.A], updated[b:m.B], max, callback); err != nil {
+				return err
+			}
+		}
+		a = m.A + m.Length
+		b = m.B + m.Length
+	}
+	return nil
+}
+
+// DiffPathToDeltas calls the callback with the sequence of deltas that transforms the original buffer into the content of the file at the given path.
+func DiffPathToDeltas(original []byte, path string, max uint64, callback func(*Delta) error) error {
+	updated, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	return DiffToDeltas(original, updated, max, callback)
+}
+
+// splitDelta ensures the bytes removed and added by a delta together do not exceed the given max, unless it is zero.
+func splitDelta(offset uint64, remove, add []byte, max uint64, callback func(*Delta) error) error {
+	if max == 0 {
+		// No limit
+		max = math.MaxUint64
+	}
+	for len(remove) > 0 || len(add) > 0 {
+		r := remove[:minUint64(uint64(len(remove)), max)]
+		a := add[:minUint64(uint64(len(add)), max-uint64(len(r)))]
+		if err := callback(&Delta{
+			Offset: offset,
+			Remove: r,
+			Add:    a,
+		}); err != nil {
+			return err
+		}
+		remove = remove[len(r):]
+		add = add[len(a):]
+		offset += uint64(len(a))
+	}
+	return nil
+}
+
+// IterateDeltas calls the callback with each delta in the channel, oldest first, decompressed and with any chunks it references replaced by their data.
+func IterateDeltas(node *Node, delta *bcgo.Channel, callback func([]byte, *bcgo.Record, *Delta) error) error {
+	return iterateDeltas(node, delta, true, callback)
+}
+
+func iterateDeltas(node *Node, delta *bcgo.Channel, resolve bool, callback func([]byte, *bcgo.Record, *Delta) error) error {
+	threshold, err := ChannelThreshold(node.Cache, node.Network, delta)
+	if err != nil {
+		return err
+	}
 	// Iterate through chain chronologically
 	return bcgo.IterateChronologically(delta.Name, delta.Head, nil, node.Cache, node.Network, func(hash []byte, block *bcgo.Block) error {
 		for _, entry := range block.Entry {
 			// Unmarshal as Delta
-			d := &Delta{}
-			if err := proto.Unmarshal(entry.Record.Payload, d); err != nil {
+			d, err := unmarshalDelta(node, entry)
+			if err != nil {
 				return err
 			}
+			if resolve {
+				if err := resolveChunks(node, threshold, d); err != nil {
+					return err
+				}
+			}
 			if err := callback(entry.RecordHash, entry.Record, d); err != nil {
 				return err
 			}
@@ -79,7 +158,27 @@ func IterateDeltas(node *bcgo.Node, delta *bcgo.Channel, callback func([]byte, *
 	})
 }
 
+// unmarshalDelta returns the delta in the entry, decrypted and decompressed.
+func unmarshalDelta(node *Node, entry *bcgo.BlockEntry) (*Delta, error) {
+	payload, err := readPayload(node, entry)
+	if err != nil {
+		return nil, err
+	}
+	d := &Delta{}
+	if err := proto.Unmarshal(payload, d); err != nil {
+		return nil, err
+	}
+	if err := DecompressDelta(d); err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
 func DeltaToBuffer(delta *Delta, buffer []byte) (result []byte) {
+	if delta.Snapshot != nil {
+		// Snapshots do not change the file
+		return buffer
+	}
 	length := uint64(len(buffer))
 	if delta.Offset <= length {
 		result = append(result, buffer[:delta.Offset]...)
@@ -92,7 +191,41 @@ func DeltaToBuffer(delta *Delta, buffer []byte) (result []byte) {
 	return
 }
 
+// ChannelToBuffer reconstructs the current content of a file by applying every delta in the given channel.
+func ChannelToBuffer(node *Node, channel *bcgo.Channel) ([]byte, error) {
+	return ChannelToBufferAt(node, channel, math.MaxUint64)
+}
+
+// ChannelToBufferAt reconstructs the content of a file as it was at the given timestamp by applying only the deltas created at or before it.
+func ChannelToBufferAt(node *Node, channel *bcgo.Channel, at uint64) ([]byte, error) {
+	table, err := ChannelToPieceTableAt(node, channel, at)
+	if err != nil {
+		return nil, err
+	}
+	return table.Bytes(), nil
+}
+
+// ChannelToWriter streams the current content of a file to the writer.
+func ChannelToWriter(node *Node, channel *bcgo.Channel, writer io.Writer) error {
+	return ChannelToWriterAt(node, channel, math.MaxUint64, writer)
+}
+
+// ChannelToWriterAt streams the content of a file as it was at the given timestamp to the writer.
+// Deltas are replayed into a PieceTable, so the content is never copied before being written.
+func ChannelToWriterAt(node *Node, channel *bcgo.Channel, at uint64, writer io.Writer) error {
+	table, err := ChannelToPieceTableAt(node, channel, at)
+	if err != nil {
+		return err
+	}
+	_, err = table.WriteTo(writer)
+	return err
+}
+
 func DeltaToPath(delta *Delta, path string) error {
+	if delta.Snapshot != nil {
+		// Snapshots do not change the file
+		return nil
+	}
 	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0666)
 	if err != nil {
 		return err
@@ -122,3 +255,10 @@ func DeltaToPath(delta *Delta, path string) error {
 	}
 	return f.Truncate(int64(delta.Offset) + int64(len(delta.Add)) + int64(remaining))
 }
+
+func minUint64(a, b uint64) uint64 {
+	if a < b {
+		return a
+	}
+	return b
+}
@@ -17,8 +17,11 @@
 package labgo_test
 
 import (
+	"bytes"
+	"github.com/AletheiaWareLLC/bcgo"
 	"github.com/AletheiaWareLLC/labgo"
 	"github.com/AletheiaWareLLC/testinggo"
+	"github.com/golang/protobuf/proto"
 	"io/ioutil"
 	"log"
 	"os"
@@ -277,3 +280,162 @@ func TestDeltaToPath(t *testing.T) {
 		})
 	}
 }
+
+func TestDiffToDeltas(t *testing.T) {
+	tests := []struct {
+		name     string
+		original string
+		updated  string
+		max      uint64
+		want     int
+	}{
+		{
+			name: "Empty",
+			want: 0,
+		},
+		{
+			name:     "Equal",
+			original: "foobar",
+			updated:  "foobar",
+			max:      10,
+			want:     0,
+		},
+		{
+			name:    "Create",
+			updated: "foobar",
+			max:     10,
+			want:    1,
+		},
+		{
+			name:     "Delete",
+			original: "foobar",
+			max:      10,
+			want:     1,
+		},
+		{
+			name:     "Insert",
+			original: "foobar",
+			updated:  "fooblahbar",
+			max:      10,
+			want:     1,
+		},
+		{
+			name:     "Replace",
+			original: "foobar",
+			updated:  "fooblah",
+			max:      10,
+			want:     1,
+		},
+		{
+			name:     "Split",
+			original: "foobar",
+			updated:  "foo0123456789012bar",
+			max:      10,
+			want:     2,
+		},
+		{
+			name:     "Unlimited",
+			original: "foobar",
+			updated:  "foo0123456789012bar",
+			want:     1,
+		},
+		{
+			name:     "SplitReplace",
+			original: "foo0123456789bar",
+			updated:  "fooabcdefghijbar",
+			max:      10,
+			want:     2,
+		},
+		{
+			name:     "Fold",
+			original: "the quick brown fox jumps over the lazy dog",
+			updated:  "the quick red fox jumps over the lazy cat",
+			max:      100,
+			want:     1,
+		},
+		{
+			name:     "Separate",
+			original: "the quick brown fox jumps over the very lazy dog",
+			updated:  "the slow brown fox jumps over the very lazy cat",
+			max:      100,
+			want:     2,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var deltas []*labgo.Delta
+			testinggo.AssertNoError(t, labgo.DiffToDeltas([]byte(tt.original), []byte(tt.updated), tt.max, func(d *labgo.Delta) error {
+				if tt.max > 0 && uint64(len(d.Remove)+len(d.Add)) > tt.max {
+					t.Fatalf("Delta too large; expected at most '%d', got '%d' and '%d'", tt.max, len(d.Remove), len(d.Add))
+				}
+				deltas = append(deltas, d)
+				return nil
+			}))
+			if len(deltas) != tt.want {
+				t.Fatalf("Wrong number of deltas; expected '%d', got '%d'", tt.want, len(deltas))
+			}
+			buffer := []byte(tt.original)
+			for _, d := range deltas {
+				if string(buffer[d.Offset:d.Offset+uint64(len(d.Remove))]) != string(d.Remove) {
+					t.Fatalf("Incorrect remove; expected '%s', got '%s'", string(buffer[d.Offset:d.Offset+uint64(len(d.Remove))]), string(d.Remove))
+				}
+				buffer = labgo.DeltaToBuffer(d, buffer)
+			}
+			if got := string(buffer); got != tt.updated {
+				t.Fatalf("Incorrect buffer; expected '%s', got '%s'", tt.updated, got)
+			}
+		})
+	}
+}
+
+func TestDiffToDeltasLargeReplace(t *testing.T) {
+	original := bytes.Repeat([]byte("a"), int(labgo.MAX_DELTA_LENGTH))
+	updated := bytes.Repeat([]byte("b"), int(labgo.MAX_DELTA_LENGTH))
+	buffer := original
+	count := 0
+	testinggo.AssertNoError(t, labgo.DiffToDeltas(original, updated, labgo.MAX_DELTA_LENGTH, func(d *labgo.Delta) error {
+		data, err := proto.Marshal(d)
+		testinggo.AssertNoError(t, err)
+		if uint64(len(data)) > bcgo.MAX_PAYLOAD_SIZE_BYTES {
+			t.Fatalf("Delta too large; expected at most '%d', got '%d'", bcgo.MAX_PAYLOAD_SIZE_BYTES, len(data))
+		}
+		buffer = labgo.DeltaToBuffer(d, buffer)
+		count++
+		return nil
+	}))
+	if count != 2 {
+		t.Fatalf("Wrong number of deltas; expected '%d', got '%d'", 2, count)
+	}
+	if !bytes.Equal(buffer, updated) {
+		t.Fatalf("Incorrect buffer")
+	}
+}
+
+func TestChannelToBuffer(t *testing.T) {
+	node := makeNode(t, "Alice")
+	channel := labgo.OpenFileChannel(node, "foobar", labgo.THRESHOLD_DEFAULT)
+	node.AddChannel(channel)
+	for _, d := range []*labgo.Delta{
+		&labgo.Delta{
+			Add: []byte("foobar"),
+		},
+		&labgo.Delta{
+			Offset: 3,
+			Remove: []byte("bar"),
+			Add:    []byte("blah"),
+		},
+	} {
+		_, err := labgo.WriteProto(node, nil, channel, nil, nil, d)
+		testinggo.AssertNoError(t, err)
+	}
+	buffer, err := labgo.ChannelToBuffer(node, channel)
+	testinggo.AssertNoError(t, err)
+	if got := string(buffer); got != "fooblah" {
+		t.Fatalf("Incorrect buffer; expected '%s', got '%s'", "fooblah", got)
+	}
+	var writer bytes.Buffer
+	testinggo.AssertNoError(t, labgo.ChannelToWriter(node, channel, &writer))
+	if got := writer.String(); got != "fooblah" {
+		t.Fatalf("Incorrect content; expected '%s', got '%s'", "fooblah", got)
+	}
+}
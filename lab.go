@@ -17,8 +17,11 @@
 package labgo
 
 import (
+	"context"
 	"crypto/rsa"
 	"encoding/base64"
+	"errors"
+	"fmt"
 	"github.com/AletheiaWareLLC/aliasgo"
 	"github.com/AletheiaWareLLC/bcgo"
 	"github.com/AletheiaWareLLC/bcnetgo"
@@ -26,30 +29,44 @@ import (
 	"github.com/golang/protobuf/proto"
 	"io"
 	"log"
+	"math"
+	"net"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
+	"time"
 )
 
 const (
 	EXPERIMENT_HASH_LENGTH = 16
-	CHANNEL_THRESHOLD      = bcgo.THRESHOLD_H
 
-	LAB_PREFIX = "Lab-"
-	//LAB_PREFIX_CHAT = "Lab-Chat-" // labgo.Delta Chain
-	LAB_PREFIX_FILE = "Lab-File-" // labgo.Delta Chain
-	//LAB_PREFIX_DRAW = "Lab-Draw-" // labgo.Delta Chain
-	LAB_PREFIX_PATH = "Lab-Path-" // labgo.Path Chain
+	LAB_PREFIX          = "Lab-"
+	LAB_PREFIX_CHAT     = "Lab-Chat-"     // labgo.Chat Chain
+	LAB_PREFIX_CHUNK    = "Lab-Chunk-"    // Raw Chunk Chain
+	LAB_PREFIX_FILE     = "Lab-File-"     // labgo.Delta Chain
+	LAB_PREFIX_DRAW     = "Lab-Draw-"     // labgo.Draw Chain
+	LAB_PREFIX_MEMBER   = "Lab-Member-"   // labgo.Member Chain
+	LAB_PREFIX_PATH     = "Lab-Path-"     // labgo.Path Chain
+	LAB_PREFIX_SETTINGS = "Lab-Settings-" // labgo.Settings Chain
+
+	ERROR_CHANNEL_UNRECOGNIZED = "Unrecognized Lab channel: %s"
+	ERROR_EXPERIMENT_UNKNOWN   = "Channel not part of an open experiment: %s"
+	ERROR_TIMESTAMP_INVALID    = "Not a timestamp or block hash: %s"
 )
 
 type Experiment struct {
 	ID string
-	//Chat *bcgo.Channel
-	//Draw *bcgo.Channel
-	Path *bcgo.Channel
+	// Public keys of the aliases the experiment's records are encrypted for, nil if the experiment is public
+	Access   map[string]*rsa.PublicKey
+	Chat     *bcgo.Channel
+	Draw     *bcgo.Channel
+	Member   *bcgo.Channel
+	Path     *bcgo.Channel
+	Settings *bcgo.Channel
 }
 
-func Init(rootDir string, cache bcgo.Cache, network bcgo.Network, listener bcgo.MiningListener) (*bcgo.Node, error) {
+func Init(rootDir string, cache bcgo.Cache, network bcgo.Network, listener bcgo.MiningListener) (*Node, error) {
 	// Create Node
 	node, err := bcgo.GetNode(rootDir, cache, network)
 	if err != nil {
@@ -61,139 +78,332 @@ func Init(rootDir string, cache bcgo.Cache, network bcgo.Network, listener bcgo.
 		return nil, err
 	}
 
-	return node, nil
+	return NewNode(node), nil
 }
 
-/*
-func OpenChatChannel(experimentId string) *bcgo.Channel {
-	// TODO(v2) add validator to ensure Delta Payload can be unmarshalled as protobuf
-	return bcgo.OpenPoWChannel(LAB_PREFIX_CHAT+experimentId, CHANNEL_THRESHOLD)
+// openExperimentChannel opens a channel of the experiment whose blocks must reach the threshold declared in the experiment's settings.
+func openExperimentChannel(name, experimentId string) *bcgo.Channel {
+	return &bcgo.Channel{
+		Name: name,
+		Validators: []bcgo.Validator{
+			&ThresholdValidator{
+				ExperimentId: experimentId,
+			},
+		},
+	}
 }
 
-func OpenDrawChannel(experimentId string) *bcgo.Channel {
-	// TODO(v2) add validator to ensure Delta Payload can be unmarshalled as protobuf
-	return bcgo.OpenPoWChannel(LAB_PREFIX_DRAW+experimentId, CHANNEL_THRESHOLD)
+func OpenChatChannel(node *Node, experimentId string) *bcgo.Channel {
+	c := openExperimentChannel(LAB_PREFIX_CHAT+experimentId, experimentId)
+	c.AddValidator(&ChatValidator{
+		Node: node,
+	})
+	c.AddValidator(&RoleValidator{
+		ExperimentId: experimentId,
+	})
+	return c
 }
-*/
 
-func OpenFileChannel(fileId string) *bcgo.Channel {
-	// TODO(v2) add validator to ensure Delta Payload can be unmarshalled as protobuf
-	return bcgo.OpenPoWChannel(LAB_PREFIX_FILE+fileId, CHANNEL_THRESHOLD)
+// OpenChunkChannel opens the channel for the chunk with the given ID, whose blocks must reach the given threshold.
+func OpenChunkChannel(chunkId string, threshold uint64) *bcgo.Channel {
+	c := bcgo.OpenPoWChannel(LAB_PREFIX_CHUNK+chunkId, threshold)
+	c.AddValidator(&ChunkValidator{})
+	return c
 }
 
-func OpenPathChannel(experimentId string) *bcgo.Channel {
-	// TODO(v2) add validator to ensure Path Payload can be unmarshalled as protobuf
-	return bcgo.OpenPoWChannel(LAB_PREFIX_PATH+experimentId, CHANNEL_THRESHOLD)
+func OpenDrawChannel(node *Node, experimentId string) *bcgo.Channel {
+	c := openExperimentChannel(LAB_PREFIX_DRAW+experimentId, experimentId)
+	c.AddValidator(&DrawValidator{
+		Node: node,
+	})
+	c.AddValidator(&RoleValidator{
+		ExperimentId: experimentId,
+	})
+	return c
 }
 
-func Clean(node *bcgo.Node, experimentId string) error {
-	// TODO remove all blocks from cache
-	/*
-		// Open Lab-Chat-<id> Chain
-		c := OpenChatChannel(experimentId)
-		// Open Lab-Draw-<id> Chain
-		d := OpenDrawChannel(experimentId)
-		// Open Lab-Path-<id> Chain
-		p := OpenPathChannel(experimentId)
-		if err := bcgo.Read(p.Name, p.Head, nil, node.Cache, node.Network, "", nil, nil, func(entry *bcgo.BlockEntry, key, data []byte) error {
-			// Get channel from node
-			file, err := node.GetChannel(LAB_PREFIX_FILE + base64.RawURLEncoding.EncodeToString(entry.RecordHash))
-			if err != nil {
-				return err
+// OpenFileChannel opens the channel for the file with the given ID, whose blocks must reach the given threshold, when the experiment it belongs to is unknown.
+// Encrypted records are validated if they can be decrypted by the node.
+func OpenFileChannel(node *Node, fileId string, threshold uint64) *bcgo.Channel {
+	c := bcgo.OpenPoWChannel(LAB_PREFIX_FILE+fileId, threshold)
+	c.AddValidator(&DeltaValidator{
+		Node: node,
+	})
+	return c
+}
+
+// OpenExperimentFileChannel opens the channel for the file with the given ID, validating its records against the threshold of the experiment and the roles of its members.
+func OpenExperimentFileChannel(node *Node, experimentId, fileId string) *bcgo.Channel {
+	c := openExperimentChannel(LAB_PREFIX_FILE+fileId, experimentId)
+	c.AddValidator(&DeltaValidator{
+		Node: node,
+	})
+	c.AddValidator(&RoleValidator{
+		ExperimentId: experimentId,
+	})
+	return c
+}
+
+func OpenMemberChannel(experimentId string) *bcgo.Channel {
+	c := openExperimentChannel(LAB_PREFIX_MEMBER+experimentId, experimentId)
+	c.AddValidator(&MemberValidator{
+		ExperimentId: experimentId,
+	})
+	return c
+}
+
+func OpenPathChannel(node *Node, experimentId string) *bcgo.Channel {
+	c := openExperimentChannel(LAB_PREFIX_PATH+experimentId, experimentId)
+	c.AddValidator(&PathValidator{
+		Node: node,
+	})
+	c.AddValidator(&RoleValidator{
+		ExperimentId: experimentId,
+	})
+	return c
+}
+
+func OpenSettingsChannel(experimentId string) *bcgo.Channel {
+	return &bcgo.Channel{
+		Name: LAB_PREFIX_SETTINGS + experimentId,
+		Validators: []bcgo.Validator{
+			&SettingsValidator{
+				ExperimentId: experimentId,
+			},
+		},
+	}
+}
+
+// OpenChannel opens the Lab channel with the given name, with the validators appropriate to its type.
+// The blocks of chunk and file channels, whose experiment is not known from their name, must reach the given threshold.
+func OpenChannel(node *Node, name string, threshold uint64) (*bcgo.Channel, error) {
+	for prefix, open := range map[string]func(string) *bcgo.Channel{
+		LAB_PREFIX_CHAT: func(experimentId string) *bcgo.Channel {
+			return OpenChatChannel(node, experimentId)
+		},
+		LAB_PREFIX_CHUNK: func(chunkId string) *bcgo.Channel {
+			return OpenChunkChannel(chunkId, threshold)
+		},
+		LAB_PREFIX_DRAW: func(experimentId string) *bcgo.Channel {
+			return OpenDrawChannel(node, experimentId)
+		},
+		LAB_PREFIX_FILE: func(fileId string) *bcgo.Channel {
+			return OpenFileChannel(node, fileId, threshold)
+		},
+		LAB_PREFIX_MEMBER: OpenMemberChannel,
+		LAB_PREFIX_PATH: func(experimentId string) *bcgo.Channel {
+			return OpenPathChannel(node, experimentId)
+		},
+		LAB_PREFIX_SETTINGS: OpenSettingsChannel,
+	} {
+		if strings.HasPrefix(name, prefix) {
+			return open(strings.TrimPrefix(name, prefix)), nil
+		}
+	}
+	return nil, errors.New(fmt.Sprintf(ERROR_CHANNEL_UNRECOGNIZED, name))
+}
+
+// Clean removes all blocks of the given experiment from the node's cache, and returns the number of blocks and bytes freed.
+// The chunks referenced by the experiment's files are removed too, unless also referenced by a file of another experiment in the cache.
+func Clean(node *Node, experimentId string) (uint64, uint64, error) {
+	// Open Lab-Path-<id> Chain
+	p := OpenPathChannel(node, experimentId)
+	if err := p.LoadCachedHead(node.Cache); err != nil {
+		log.Println(err)
+	}
+	channels := []string{
+		LAB_PREFIX_CHAT + experimentId,
+		LAB_PREFIX_DRAW + experimentId,
+		LAB_PREFIX_MEMBER + experimentId,
+		LAB_PREFIX_SETTINGS + experimentId,
+		p.Name,
+	}
+	// Each Path record identifies a Lab-File-<hash> Chain
+	if err := bcgo.Iterate(p.Name, p.Head, nil, node.Cache, nil, func(hash []byte, block *bcgo.Block) error {
+		for _, entry := range block.Entry {
+			channels = append(channels, LAB_PREFIX_FILE+base64.RawURLEncoding.EncodeToString(entry.RecordHash))
+		}
+		return nil
+	}); err != nil {
+		return 0, 0, err
+	}
+	chunks := make(map[string]bool)
+	own := make(map[string]bool)
+	for _, c := range channels {
+		own[c] = true
+		if strings.HasPrefix(c, LAB_PREFIX_FILE) {
+			if err := chunkReferences(node.Cache, c, func(id string) {
+				chunks[id] = true
+			}); err != nil {
+				return 0, 0, err
 			}
-			// TODO remove all blocks from cache
-			return nil
-		})
-	*/
-	return nil
+		}
+	}
+	if len(chunks) > 0 {
+		cached, err := ListChannels(node.Cache)
+		if err != nil {
+			return 0, 0, err
+		}
+		for _, c := range cached {
+			if strings.HasPrefix(c, LAB_PREFIX_FILE) && !own[c] {
+				// Chunk is shared with another experiment
+				if err := chunkReferences(node.Cache, c, func(id string) {
+					delete(chunks, id)
+				}); err != nil {
+					return 0, 0, err
+				}
+			}
+		}
+		for id := range chunks {
+			channels = append(channels, LAB_PREFIX_CHUNK+id)
+		}
+	}
+	var blocks, size uint64
+	for _, c := range channels {
+		b, s, err := DeleteChannel(node.Cache, c)
+		blocks += b
+		size += s
+		if err != nil {
+			return blocks, size, err
+		}
+		// Remove channel from node
+		delete(node.Channels, c)
+	}
+	return blocks, size, nil
 }
 
-func CreateFromReader(node *bcgo.Node, listener bcgo.MiningListener, uri string, reader io.ReadCloser) (*Experiment, error) {
-	// Generate ID
-	id, err := cryptogo.RandomString(EXPERIMENT_HASH_LENGTH)
-	if err != nil {
+// CreateFromReader creates a new experiment containing the content of the reader at the path identified by the URI.
+// If recipients are given every record is encrypted so only they, and the node, can read it.
+func CreateFromReader(node *Node, listener bcgo.MiningListener, recipients []string, threshold uint64, uri string, reader io.ReadCloser) (*Experiment, error) {
+	var experiment *Experiment
+	if err := batchWrites(node, listener, func() error {
+		var err error
+		experiment, err = createExperiment(node, listener, recipients, threshold)
+		if err != nil {
+			return err
+		}
+		if uri != "" && reader != nil {
+			if _, _, err := CreatePathFromReader(node, listener, experiment.Path, experiment.Access, URIToPath(uri), reader); err != nil {
+				return err
+			}
+		}
+		return nil
+	}); err != nil {
 		return nil, err
 	}
-	// Create Lab-Path-<id> Chain
-	p := OpenPathChannel(id)
-	node.AddChannel(p)
-	if uri != "" && reader != nil {
-		// TODO truncate uri to remove file:///Users/foobar/...
-		log.Println(uri)
-		path := strings.Split(uri, string(os.PathSeparator))
-		log.Println(path)
-		if _, _, err := CreatePathFromReader(node, listener, p, path, reader); err != nil {
-			return nil, err
+	return experiment, nil
+}
+
+// CreateFromPaths creates a new experiment containing every file under the given paths.
+// Each file is recorded relative to the path it was found under, so the experiment does not depend on where it was created.
+// If recipients are given every record is encrypted so only they, and the node, can read it.
+// Records are batched, so each channel is mined into as few blocks as possible, at the given proof-of-work threshold.
+func CreateFromPaths(node *Node, listener bcgo.MiningListener, recipients []string, threshold uint64, paths ...string) (*Experiment, error) {
+	var experiment *Experiment
+	if err := batchWrites(node, listener, func() error {
+		var err error
+		experiment, err = createExperiment(node, listener, recipients, threshold)
+		if err != nil {
+			return err
 		}
+		// Read paths into Lab-File-<id> Chain
+		for _, root := range paths {
+			if err := filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+				if err != nil {
+					return err
+				}
+				if info.IsDir() {
+					// Skip directories
+					return nil
+				}
+				if info.Mode()&os.ModeSymlink == os.ModeSymlink {
+					// Skip symbolic links
+					return nil
+				}
+				segments, err := RelativePath(root, path)
+				if err != nil {
+					return err
+				}
+				_, file, err := CreatePath(node, listener, experiment.Path, experiment.Access, segments)
+				if err != nil {
+					return err
+				}
+				return PathToChannel(node, listener, file, experiment.Access, path)
+			}); err != nil {
+				return err
+			}
+		}
+		return nil
+	}); err != nil {
+		return nil, err
 	}
-	return &Experiment{
-		ID: id,
-		//Chat: c,
-		//Draw: d,
-		Path: p,
-	}, nil
+	return experiment, nil
 }
 
-func CreateFromPaths(node *bcgo.Node, listener bcgo.MiningListener, paths ...string) (*Experiment, error) {
-	// Generate ID
-	id, err := cryptogo.RandomString(EXPERIMENT_HASH_LENGTH)
+// createExperiment creates the channels of a new experiment.
+// The proof-of-work threshold of every channel is recorded in the experiment's settings.
+// The node is recorded as the experiment's owner, and if recipients are given each is recorded as an editor.
+func createExperiment(node *Node, listener bcgo.MiningListener, recipients []string, threshold uint64) (*Experiment, error) {
+	acl, err := GetAccess(node, recipients)
 	if err != nil {
 		return nil, err
 	}
+	// Create Lab-Settings-<id> Chain
+	// Settings are written first, as other channels are validated against them, and the ID is derived from their hash
+	s, err := writeSettings(node, listener, &Settings{
+		Threshold: threshold,
+	})
+	if err != nil {
+		return nil, err
+	}
+	node.AddChannel(s)
+	id := strings.TrimPrefix(s.Name, LAB_PREFIX_SETTINGS)
 	// Create Lab-Chat-<id> Chain
-	//c := OpenChatChannel(id)
-	//node.AddChannel(c)
+	c := OpenChatChannel(node, id)
+	node.AddChannel(c)
 	// Create Lab-Draw-<id> Chain
-	//d := OpenDrawChannel(id)
-	//node.AddChannel(d)
+	d := OpenDrawChannel(node, id)
+	node.AddChannel(d)
+	// Create Lab-Member-<id> Chain
+	m := OpenMemberChannel(id)
+	node.AddChannel(m)
 	// Create Lab-Path-<id> Chain
-	p := OpenPathChannel(id)
+	p := OpenPathChannel(node, id)
 	node.AddChannel(p)
-	// Read paths into Lab-File-<id> Chain
-	for _, path := range paths {
-		if err := filepath.Walk(path, func(path string, info os.FileInfo, err error) error {
-			if err != nil {
-				return err
-			}
-			if info.IsDir() {
-				// Skip directories
-				return nil
-			}
-			if info.Mode()&os.ModeSymlink == os.ModeSymlink {
-				// Skip symbolic links
-				return nil
-			}
-			// TODO truncate path to remove /Users/foobar/...
-			log.Println(path, info.Name(), info.Size())
-			// Create fileId from path
-			fileHash, err := WriteProto(node, listener, p, &Path{
-				Path: strings.Split(path, string(os.PathSeparator)),
-			})
-			if err != nil {
-				return nil
-			}
-			// Create Lab-File-<id> Chain
-			file := OpenFileChannel(base64.RawURLEncoding.EncodeToString(fileHash))
-			node.AddChannel(file)
-			return PathToDeltas(path, MAX_DELTA_LENGTH, func(d *Delta) error {
-				_, err := WriteProto(node, listener, file, d)
-				return err
-			})
+	// The creator is the first member, and owns the experiment
+	if _, err := WriteProto(node, listener, m, nil, nil, &Member{
+		Alias:  node.Alias,
+		Role:   Role_OWNER,
+		Public: len(acl) == 0,
+	}); err != nil {
+		return nil, err
+	}
+	// Recipients can edit the experiment
+	for _, alias := range Recipients(acl) {
+		if alias == node.Alias {
+			continue
+		}
+		if _, err := WriteProto(node, listener, m, nil, nil, &Member{
+			Alias: alias,
+			Role:  Role_EDITOR,
 		}); err != nil {
 			return nil, err
 		}
 	}
 	return &Experiment{
-		ID: id,
-		//Chat: c,
-		//Draw: d,
-		Path: p,
+		ID:       id,
+		Access:   acl,
+		Chat:     c,
+		Draw:     d,
+		Member:   m,
+		Path:     p,
+		Settings: s,
 	}, nil
 }
 
-func CreatePath(node *bcgo.Node, listener bcgo.MiningListener, channel *bcgo.Channel, path []string) (string, *bcgo.Channel, error) {
+func CreatePath(node *Node, listener bcgo.MiningListener, channel *bcgo.Channel, acl map[string]*rsa.PublicKey, path []string) (string, *bcgo.Channel, error) {
 	// Create fileId from path
-	fileHash, err := WriteProto(node, listener, channel, &Path{
+	fileHash, err := WriteProto(node, listener, channel, acl, nil, &Path{
 		Path: path,
 	})
 	if err != nil {
@@ -201,81 +411,216 @@ func CreatePath(node *bcgo.Node, listener bcgo.MiningListener, channel *bcgo.Cha
 	}
 	// Create Lab-File-<id> Chain
 	id := base64.RawURLEncoding.EncodeToString(fileHash)
-	file := OpenFileChannel(id)
+	file := OpenExperimentFileChannel(node, strings.TrimPrefix(channel.Name, LAB_PREFIX_PATH), id)
 	node.AddChannel(file)
 	return id, file, nil
 }
 
-func CreatePathFromReader(node *bcgo.Node, listener bcgo.MiningListener, channel *bcgo.Channel, path []string, reader io.ReadCloser) (string, *bcgo.Channel, error) {
-	id, file, err := CreatePath(node, listener, channel, path)
+func CreatePathFromReader(node *Node, listener bcgo.MiningListener, channel *bcgo.Channel, acl map[string]*rsa.PublicKey, path []string, reader io.ReadCloser) (string, *bcgo.Channel, error) {
+	id, file, err := CreatePath(node, listener, channel, acl, path)
 	if err != nil {
 		return "", nil, err
 	}
-	if err := ReaderToDeltas(reader, MAX_DELTA_LENGTH, func(d *Delta) error {
-		_, err := WriteProto(node, listener, file, d)
-		return err
-	}); err != nil {
+	if err := ReaderToChannel(node, listener, file, acl, reader); err != nil {
 		return "", nil, err
 	}
 	return id, file, nil
 }
 
-func Open(node *bcgo.Node, experimentId string) (*Experiment, error) {
+func Open(node *Node, experimentId string) (*Experiment, error) {
 	// Open Lab-Chat-<id> Chain
-	//c := OpenChatChannel(experimentId)
+	c := OpenChatChannel(node, experimentId)
 	// Open Lab-Draw-<id> Chain
-	//d := OpenDrawChannel(experimentId)
+	d := OpenDrawChannel(node, experimentId)
+	// Open Lab-Member-<id> Chain
+	m := OpenMemberChannel(experimentId)
 	// Open Lab-Path-<id> Chain
-	p := OpenPathChannel(experimentId)
+	p := OpenPathChannel(node, experimentId)
+	// Open Lab-Settings-<id> Chain
+	s := OpenSettingsChannel(experimentId)
 
-	for _, c := range []*bcgo.Channel{
-		//c,
-		//d,
+	// Load settings, members, and paths first, as other channels are validated against them
+	for _, channel := range []*bcgo.Channel{
+		s,
+		m,
 		p,
+		c,
+		d,
 	} {
-		// Load channel
-		if err := c.LoadCachedHead(node.Cache); err != nil {
-			log.Println(err)
+		loadChannel(node, channel)
+	}
+
+	membership, err := GetMembership(node.Cache, node.Network, experimentId)
+	if err != nil {
+		return nil, err
+	}
+	var acl map[string]*rsa.PublicKey
+	if membership.IsPublic() {
+		// Share new records with the recipients of existing records
+		acl, err = getExperimentAccess(node, p)
+	} else {
+		// Share new records with the current members
+		node.addSharedKeys(membership.Keys(node.Alias))
+		acl, err = getAccess(node, membership.Members())
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &Experiment{
+		ID:       experimentId,
+		Access:   acl,
+		Chat:     c,
+		Draw:     d,
+		Member:   m,
+		Path:     p,
+		Settings: s,
+	}, nil
+}
+
+// GetFileChannel returns the node's channel for the file with the given ID, opening, loading, and pulling it if necessary.
+func GetFileChannel(node *Node, fileId string) *bcgo.Channel {
+	if channel, err := node.GetChannel(LAB_PREFIX_FILE + fileId); err == nil {
+		return channel
+	}
+	var channel *bcgo.Channel
+	if experimentId, err := GetExperimentId(node, fileId); err == nil {
+		channel = OpenExperimentFileChannel(node, experimentId, fileId)
+	} else {
+		// Experiment, and so its threshold, is unknown
+		channel = OpenFileChannel(node, fileId, THRESHOLD_DEFAULT)
+	}
+	loadChannel(node, channel)
+	return channel
+}
+
+// GetExperimentId returns the ID of the experiment open on the node whose path channel created the file with the given ID.
+func GetExperimentId(node *Node, fileId string) (string, error) {
+	hash, err := base64.RawURLEncoding.DecodeString(fileId)
+	if err != nil {
+		return "", err
+	}
+	for _, channel := range node.GetChannels() {
+		if !strings.HasPrefix(channel.Name, LAB_PREFIX_PATH) {
+			continue
 		}
-		if node.Network != nil {
-			// Pull channel from network
-			if err := c.Pull(node.Cache, node.Network); err != nil {
-				log.Println(err)
+		block, err := node.Cache.GetBlockContainingRecord(channel.Name, hash)
+		// Some caches look up records across all channels
+		if err == nil && block.ChannelName == channel.Name {
+			return strings.TrimPrefix(channel.Name, LAB_PREFIX_PATH), nil
+		}
+	}
+	return "", errors.New(fmt.Sprintf(ERROR_EXPERIMENT_UNKNOWN, LAB_PREFIX_FILE+fileId))
+}
+
+// OpenServedChannel opens the Lab channel with the given name when a peer broadcasts an update to it.
+// The other channels of an experiment are added to the node when it is opened, so are only opened here for experiments which are not open, whose settings are supplied by peers, and so must reach at least THRESHOLD_DEFAULT.
+// File channels are only opened for experiments open on the node, so their records are validated against the threshold of the experiment and the roles of its members.
+// Chunk channels may be referenced by any experiment open on the node, so are validated against the lowest of their thresholds.
+func OpenServedChannel(node *Node, name string) (*bcgo.Channel, error) {
+	for _, prefix := range []string{
+		LAB_PREFIX_CHAT,
+		LAB_PREFIX_DRAW,
+		LAB_PREFIX_MEMBER,
+		LAB_PREFIX_PATH,
+		LAB_PREFIX_SETTINGS,
+	} {
+		if strings.HasPrefix(name, prefix) {
+			channel, err := OpenChannel(node, name, THRESHOLD_DEFAULT)
+			if err != nil {
+				return nil, err
+			}
+			for _, v := range channel.Validators {
+				switch v := v.(type) {
+				case *SettingsValidator:
+					v.Minimum = THRESHOLD_DEFAULT
+				case *ThresholdValidator:
+					v.Minimum = THRESHOLD_DEFAULT
+				}
 			}
+			return channel, nil
 		}
-		// Add channel to node
-		node.AddChannel(c)
 	}
+	if strings.HasPrefix(name, LAB_PREFIX_FILE) {
+		fileId := strings.TrimPrefix(name, LAB_PREFIX_FILE)
+		experimentId, err := GetExperimentId(node, fileId)
+		if err != nil {
+			return nil, err
+		}
+		return OpenExperimentFileChannel(node, experimentId, fileId), nil
+	}
+	return OpenChannel(node, name, lowestThreshold(node))
+}
 
-	return &Experiment{
-		ID: experimentId,
-		//Chat: c,
-		//Draw: d,
-		Path: p,
-	}, nil
+// lowestThreshold returns the lowest threshold of the experiments open on the node, or THRESHOLD_DEFAULT if none are open.
+func lowestThreshold(node *Node) uint64 {
+	threshold, found := uint64(THRESHOLD_DEFAULT), false
+	for _, channel := range node.GetChannels() {
+		if !strings.HasPrefix(channel.Name, LAB_PREFIX_SETTINGS) {
+			continue
+		}
+		t, err := GetThreshold(node.Cache, node.Network, strings.TrimPrefix(channel.Name, LAB_PREFIX_SETTINGS))
+		if err != nil {
+			log.Println(err)
+			continue
+		}
+		if !found || t < threshold {
+			threshold, found = t, true
+		}
+	}
+	return threshold
+}
+
+// Save writes the current content of every file in the experiment under the given path, replacing any existing files.
+func Save(node *Node, experiment *Experiment, path string) error {
+	return SaveAt(node, experiment, path, math.MaxUint64)
 }
 
-func Save(node *bcgo.Node, experiment *Experiment, path string) error {
-	p := experiment.Path
-	return bcgo.Read(p.Name, p.Head, nil, node.Cache, node.Network, "", nil, nil, func(entry *bcgo.BlockEntry, key, data []byte) error {
-		// Unmarshal as Path
-		p := &Path{}
-		if err := proto.Unmarshal(data, p); err != nil {
+// SaveAt writes the content of every file in the experiment as it was at the given timestamp under the given path, replacing any existing files.
+func SaveAt(node *Node, experiment *Experiment, path string, at uint64) error {
+	files, err := ListFilesAt(node, experiment.Path, at)
+	if err != nil {
+		return err
+	}
+	for id, p := range files {
+		// Refuse to write outside the given path
+		if err := ValidatePath(p); err != nil {
 			return err
 		}
-		filePath := filepath.Join(append([]string{path}, p.Path...)...)
-		// Get channel from node
-		file, err := node.GetChannel(LAB_PREFIX_FILE + base64.RawURLEncoding.EncodeToString(entry.RecordHash))
+		filePath := filepath.Join(append([]string{path}, p...)...)
+		if err := os.MkdirAll(filepath.Dir(filePath), os.ModePerm); err != nil {
+			return err
+		}
+		file, err := os.Create(filePath)
 		if err != nil {
 			return err
 		}
-		return IterateDeltas(node, file, func(h []byte, r *bcgo.Record, d *Delta) error {
-			return DeltaToPath(d, filePath)
-		})
-	})
+		if err := ChannelToWriterAt(node, GetFileChannel(node, id), at, file); err != nil {
+			file.Close()
+			return err
+		}
+		if err := file.Close(); err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
-func Serve(node *bcgo.Node, cache bcgo.Cache, network *bcgo.TCPNetwork) {
+// ParseTimestamp returns the timestamp given either as a number of nanoseconds since the epoch, or as the hash of a cached block, in which case the block's timestamp is used.
+func ParseTimestamp(cache bcgo.Cache, at string) (uint64, error) {
+	if timestamp, err := strconv.ParseUint(at, 10, 64); err == nil {
+		return timestamp, nil
+	}
+	if hash, err := base64.RawURLEncoding.DecodeString(at); err == nil {
+		if block, err := cache.GetBlock(hash); err == nil {
+			return block.Timestamp, nil
+		}
+	}
+	return 0, errors.New(fmt.Sprintf(ERROR_TIMESTAMP_INVALID, at))
+}
+
+// Serve handles the requests of peers, and pushes the channels in the node's outbox until the context is cancelled.
+func Serve(ctx context.Context, node *Node, cache bcgo.Cache, network *bcgo.TCPNetwork) {
 	// Serve Connect Requests
 	go bcnetgo.BindTCP(bcgo.PORT_CONNECT, bcnetgo.ConnectPortTCPHandler(network))
 	// Serve Block Requests
@@ -283,11 +628,14 @@ func Serve(node *bcgo.Node, cache bcgo.Cache, network *bcgo.TCPNetwork) {
 	// Serve Head Requests
 	go bcnetgo.BindTCP(bcgo.PORT_GET_HEAD, bcnetgo.HeadPortTCPHandler(cache, network))
 	// Serve Block Updates
-	go bcnetgo.BindTCP(bcgo.PORT_BROADCAST, bcnetgo.BroadcastPortTCPHandler(cache, network, func(name string) (*bcgo.Channel, error) {
+	broadcast := bcnetgo.BroadcastPortTCPHandler(cache, network, func(name string) (*bcgo.Channel, error) {
 		channel, err := node.GetChannel(name)
 		if err != nil {
 			if strings.HasPrefix(name, LAB_PREFIX) {
-				channel = bcgo.OpenPoWChannel(name, CHANNEL_THRESHOLD)
+				channel, err = OpenServedChannel(node, name)
+				if err != nil {
+					return nil, err
+				}
 				// Load channel
 				if err := channel.LoadCachedHead(cache); err != nil {
 					log.Println(err)
@@ -303,39 +651,106 @@ func Serve(node *bcgo.Node, cache bcgo.Cache, network *bcgo.TCPNetwork) {
 			}
 		}
 		return channel, nil
-	}))
+	})
+	go bcnetgo.BindTCP(bcgo.PORT_BROADCAST, func(conn net.Conn) {
+		// Apply one broadcast at a time, and not while the node updates a channel
+		node.update.Lock()
+		defer node.update.Unlock()
+		broadcast(conn)
+	})
+	// Push Channels Mined While Peers Were Unreachable, until the context is cancelled
+	go func() {
+		for {
+			if err := RetryPush(ctx, node, PUSH_RETRY_MIN, PUSH_RETRY_MAX); err != nil {
+				log.Println(err)
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(PUSH_RETRY_MIN):
+			}
+		}
+	}()
 }
 
-func WriteProto(node *bcgo.Node, listener bcgo.MiningListener, channel *bcgo.Channel, protobuf proto.Message) ([]byte, error) {
-	// Create protobuf record
-	hash, record, err := ProtoToRecord(node.Alias, node.Key, bcgo.Timestamp(), protobuf)
+func loadChannel(node *Node, channel *bcgo.Channel) {
+	// Load channel
+	if err := channel.LoadCachedHead(node.Cache); err != nil {
+		log.Println(err)
+	}
+	if node.Network != nil {
+		// Pull channel from network, local records not yet pushed stay in the outbox and are rebased onto the pulled chain when it is pushed
+		if err := channel.Pull(node.Cache, node.Network); err != nil {
+			log.Println(err)
+		}
+	}
+	// Add channel to node
+	node.AddChannel(channel)
+}
+
+// WriteProto writes the protobuf to the channel in a new record, encrypted for the aliases in the access list unless it is empty.
+// The record is mined into a new block and pushed to peers, unless the node is batching records.
+// If peers are unreachable the channel is added to the node's outbox, to be pushed later.
+func WriteProto(node *Node, listener bcgo.MiningListener, channel *bcgo.Channel, acl map[string]*rsa.PublicKey, references []*bcgo.Reference, protobuf proto.Message) ([]byte, error) {
+	// Marshal Protobuf
+	data, err := proto.Marshal(protobuf)
 	if err != nil {
 		return nil, err
 	}
+	return writePayload(node, listener, channel, acl, references, data)
+}
 
-	// Write Record to Cache
-	if err := node.Cache.PutBlockEntry(channel.Name, &bcgo.BlockEntry{
-		RecordHash: hash,
-		Record:     record,
-	}); err != nil {
+func writePayload(node *Node, listener bcgo.MiningListener, channel *bcgo.Channel, acl map[string]*rsa.PublicKey, references []*bcgo.Reference, payload []byte) ([]byte, error) {
+	entry, err := createEntry(node, channel, acl, references, payload)
+	if err != nil {
 		return nil, err
 	}
+	hash := entry.RecordHash
+
+	if batch := getBatch(node); batch != nil {
+		// Mined when the batch is flushed
+		if err := batch.add(channel, entry); err != nil {
+			return nil, err
+		}
+		return hash, nil
+	}
 
 	// Mine Channel
-	if _, _, err := node.Mine(channel, CHANNEL_THRESHOLD, listener); err != nil {
+	if err := mineEntries(node, listener, channel, []*bcgo.BlockEntry{entry}, nil); err != nil {
 		return nil, err
 	}
 
-	if node.Network != nil {
-		// Push Update to Peers
-		if err := channel.Push(node.Cache, node.Network); err != nil {
-			return nil, err
-		}
+	if _, err := pushChannel(node, channel); err != nil {
+		return nil, err
 	}
 	return hash, nil
 }
 
-func ProtoToRecord(alias string, key *rsa.PrivateKey, timestamp uint64, protobuf proto.Message) ([]byte, *bcgo.Record, error) {
+// createEntry creates a record of the payload, encrypted for the aliases in the access list unless it is empty.
+// The record is not written to the cache, so it is only mined into the blocks it is explicitly given to.
+func createEntry(node *Node, channel *bcgo.Channel, acl map[string]*rsa.PublicKey, references []*bcgo.Reference, payload []byte) (*bcgo.BlockEntry, error) {
+	if _, ok := acl[node.Alias]; len(acl) > 0 && !ok {
+		// Only members write to private experiments
+		return nil, errors.New(fmt.Sprintf(ERROR_NOT_MEMBER, node.Alias))
+	}
+	// Create Record
+	_, record, err := bcgo.CreateRecord(bcgo.Timestamp(), node.Alias, node.Key, acl, references, payload)
+	if err != nil {
+		return nil, err
+	}
+
+	hash, err := cryptogo.HashProtobuf(record)
+	if err != nil {
+		return nil, err
+	}
+
+	return &bcgo.BlockEntry{
+		RecordHash: hash,
+		Record:     record,
+	}, nil
+}
+
+func ProtoToRecord(alias string, key *rsa.PrivateKey, timestamp uint64, references []*bcgo.Reference, protobuf proto.Message) ([]byte, *bcgo.Record, error) {
 	// Marshal Protobuf
 	data, err := proto.Marshal(protobuf)
 	if err != nil {
@@ -343,7 +758,7 @@ func ProtoToRecord(alias string, key *rsa.PrivateKey, timestamp uint64, protobuf
 	}
 
 	// Create Record
-	_, record, err := bcgo.CreateRecord(timestamp, alias, key, nil, nil, data)
+	_, record, err := bcgo.CreateRecord(timestamp, alias, key, nil, references, data)
 	if err != nil {
 		return nil, nil, err
 	}
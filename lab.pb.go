@@ -20,9 +20,75 @@ var _ = math.Inf
 // proto package needs to be updated.
 const _ = proto.ProtoPackageIsVersion3 // please upgrade the proto package
 
+type Compression int32
+
+const (
+	Compression_UNCOMPRESSED Compression = 0
+	Compression_GZIP         Compression = 1
+)
+
+var Compression_name = map[int32]string{
+	0: "UNCOMPRESSED",
+	1: "GZIP",
+}
+
+var Compression_value = map[string]int32{
+	"UNCOMPRESSED": 0,
+	"GZIP":         1,
+}
+
+func (x Compression) String() string {
+	return proto.EnumName(Compression_name, int32(x))
+}
+
+func (Compression) EnumDescriptor() ([]byte, []int) {
+	return fileDescriptor_a33572512533a9b1, []int{0}
+}
+
+type Role int32
+
+const (
+	// No Role, Cannot Write.
+	Role_UNKNOWN Role = 0
+	// Read and Chat.
+	Role_VIEWER Role = 1
+	// Draw, and Everything a Viewer Can Do.
+	Role_COMMENTER Role = 2
+	// Write Paths and Deltas, and Everything a Commenter Can Do.
+	Role_EDITOR Role = 3
+	// Invite, Revoke, and Change Roles of Members, and Everything an Editor Can Do.
+	Role_OWNER Role = 4
+)
+
+var Role_name = map[int32]string{
+	0: "UNKNOWN",
+	1: "VIEWER",
+	2: "COMMENTER",
+	3: "EDITOR",
+	4: "OWNER",
+}
+
+var Role_value = map[string]int32{
+	"UNKNOWN":   0,
+	"VIEWER":    1,
+	"COMMENTER": 2,
+	"EDITOR":    3,
+	"OWNER":     4,
+}
+
+func (x Role) String() string {
+	return proto.EnumName(Role_name, int32(x))
+}
+
+func (Role) EnumDescriptor() ([]byte, []int) {
+	return fileDescriptor_a33572512533a9b1, []int{1}
+}
+
 type Path struct {
 	// Path Segments and File Name.
-	Path                 []string `protobuf:"bytes,1,rep,name=path,proto3" json:"path,omitempty"`
+	Path []string `protobuf:"bytes,1,rep,name=path,proto3" json:"path,omitempty"`
+	// File Deleted.
+	Deleted              bool     `protobuf:"varint,2,opt,name=deleted,proto3" json:"deleted,omitempty"`
 	XXX_NoUnkeyedLiteral struct{} `json:"-"`
 	XXX_unrecognized     []byte   `json:"-"`
 	XXX_sizecache        int32    `json:"-"`
@@ -60,16 +126,31 @@ func (m *Path) GetPath() []string {
 	return nil
 }
 
+func (m *Path) GetDeleted() bool {
+	if m != nil {
+		return m.Deleted
+	}
+	return false
+}
+
 type Delta struct {
 	// File Offset.
 	Offset uint64 `protobuf:"varint,1,opt,name=offset,proto3" json:"offset,omitempty"`
 	// Bytes Removed.
 	Remove []byte `protobuf:"bytes,2,opt,name=remove,proto3" json:"remove,omitempty"`
 	// Bytes Added.
-	Add                  []byte   `protobuf:"bytes,3,opt,name=add,proto3" json:"add,omitempty"`
-	XXX_NoUnkeyedLiteral struct{} `json:"-"`
-	XXX_unrecognized     []byte   `json:"-"`
-	XXX_sizecache        int32    `json:"-"`
+	Add []byte `protobuf:"bytes,3,opt,name=add,proto3" json:"add,omitempty"`
+	// Snapshot of File Content, if set the bytes added are the part of the snapshot at the file offset and the delta does not change the file.
+	Snapshot *Snapshot `protobuf:"bytes,4,opt,name=snapshot,proto3" json:"snapshot,omitempty"`
+	// Chunks Removed, following any Bytes Removed.
+	RemoveChunk []*ChunkReference `protobuf:"bytes,5,rep,name=remove_chunk,json=removeChunk,proto3" json:"remove_chunk,omitempty"`
+	// Chunks Added, following any Bytes Added.
+	AddChunk []*ChunkReference `protobuf:"bytes,6,rep,name=add_chunk,json=addChunk,proto3" json:"add_chunk,omitempty"`
+	// Compression of Bytes Removed and Bytes Added.
+	Compression          Compression `protobuf:"varint,7,opt,name=compression,proto3,enum=lab.Compression" json:"compression,omitempty"`
+	XXX_NoUnkeyedLiteral struct{}    `json:"-"`
+	XXX_unrecognized     []byte      `json:"-"`
+	XXX_sizecache        int32       `json:"-"`
 }
 
 func (m *Delta) Reset()         { *m = Delta{} }
@@ -118,6 +199,132 @@ func (m *Delta) GetAdd() []byte {
 	return nil
 }
 
+func (m *Delta) GetSnapshot() *Snapshot {
+	if m != nil {
+		return m.Snapshot
+	}
+	return nil
+}
+
+func (m *Delta) GetRemoveChunk() []*ChunkReference {
+	if m != nil {
+		return m.RemoveChunk
+	}
+	return nil
+}
+
+func (m *Delta) GetAddChunk() []*ChunkReference {
+	if m != nil {
+		return m.AddChunk
+	}
+	return nil
+}
+
+func (m *Delta) GetCompression() Compression {
+	if m != nil {
+		return m.Compression
+	}
+	return Compression_UNCOMPRESSED
+}
+
+type ChunkReference struct {
+	// Hash of Chunk Content.
+	Hash []byte `protobuf:"bytes,1,opt,name=hash,proto3" json:"hash,omitempty"`
+	// Length of Chunk Content.
+	Length               uint64   `protobuf:"varint,2,opt,name=length,proto3" json:"length,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *ChunkReference) Reset()         { *m = ChunkReference{} }
+func (m *ChunkReference) String() string { return proto.CompactTextString(m) }
+func (*ChunkReference) ProtoMessage()    {}
+func (*ChunkReference) Descriptor() ([]byte, []int) {
+	return fileDescriptor_a33572512533a9b1, []int{2}
+}
+
+func (m *ChunkReference) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_ChunkReference.Unmarshal(m, b)
+}
+func (m *ChunkReference) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_ChunkReference.Marshal(b, m, deterministic)
+}
+func (m *ChunkReference) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_ChunkReference.Merge(m, src)
+}
+func (m *ChunkReference) XXX_Size() int {
+	return xxx_messageInfo_ChunkReference.Size(m)
+}
+func (m *ChunkReference) XXX_DiscardUnknown() {
+	xxx_messageInfo_ChunkReference.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_ChunkReference proto.InternalMessageInfo
+
+func (m *ChunkReference) GetHash() []byte {
+	if m != nil {
+		return m.Hash
+	}
+	return nil
+}
+
+func (m *ChunkReference) GetLength() uint64 {
+	if m != nil {
+		return m.Length
+	}
+	return 0
+}
+
+type Snapshot struct {
+	// Hash of File Content.
+	Hash []byte `protobuf:"bytes,1,opt,name=hash,proto3" json:"hash,omitempty"`
+	// Length of File Content.
+	Length               uint64   `protobuf:"varint,2,opt,name=length,proto3" json:"length,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *Snapshot) Reset()         { *m = Snapshot{} }
+func (m *Snapshot) String() string { return proto.CompactTextString(m) }
+func (*Snapshot) ProtoMessage()    {}
+func (*Snapshot) Descriptor() ([]byte, []int) {
+	return fileDescriptor_a33572512533a9b1, []int{3}
+}
+
+func (m *Snapshot) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_Snapshot.Unmarshal(m, b)
+}
+func (m *Snapshot) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_Snapshot.Marshal(b, m, deterministic)
+}
+func (m *Snapshot) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_Snapshot.Merge(m, src)
+}
+func (m *Snapshot) XXX_Size() int {
+	return xxx_messageInfo_Snapshot.Size(m)
+}
+func (m *Snapshot) XXX_DiscardUnknown() {
+	xxx_messageInfo_Snapshot.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_Snapshot proto.InternalMessageInfo
+
+func (m *Snapshot) GetHash() []byte {
+	if m != nil {
+		return m.Hash
+	}
+	return nil
+}
+
+func (m *Snapshot) GetLength() uint64 {
+	if m != nil {
+		return m.Length
+	}
+	return 0
+}
+
 type RGBA struct {
 	Red                  uint32   `protobuf:"varint,1,opt,name=red,proto3" json:"red,omitempty"`
 	Green                uint32   `protobuf:"varint,2,opt,name=green,proto3" json:"green,omitempty"`
@@ -132,7 +339,7 @@ func (m *RGBA) Reset()         { *m = RGBA{} }
 func (m *RGBA) String() string { return proto.CompactTextString(m) }
 func (*RGBA) ProtoMessage()    {}
 func (*RGBA) Descriptor() ([]byte, []int) {
-	return fileDescriptor_a33572512533a9b1, []int{2}
+	return fileDescriptor_a33572512533a9b1, []int{4}
 }
 
 func (m *RGBA) XXX_Unmarshal(b []byte) error {
@@ -194,7 +401,7 @@ func (m *Draw) Reset()         { *m = Draw{} }
 func (m *Draw) String() string { return proto.CompactTextString(m) }
 func (*Draw) ProtoMessage()    {}
 func (*Draw) Descriptor() ([]byte, []int) {
-	return fileDescriptor_a33572512533a9b1, []int{3}
+	return fileDescriptor_a33572512533a9b1, []int{5}
 }
 
 func (m *Draw) XXX_Unmarshal(b []byte) error {
@@ -247,7 +454,7 @@ func (m *Chat) Reset()         { *m = Chat{} }
 func (m *Chat) String() string { return proto.CompactTextString(m) }
 func (*Chat) ProtoMessage()    {}
 func (*Chat) Descriptor() ([]byte, []int) {
-	return fileDescriptor_a33572512533a9b1, []int{4}
+	return fileDescriptor_a33572512533a9b1, []int{6}
 }
 
 func (m *Chat) XXX_Unmarshal(b []byte) error {
@@ -275,35 +482,230 @@ func (m *Chat) GetText() string {
 	return ""
 }
 
+type Member struct {
+	// Alias Invited or Revoked.
+	Alias string `protobuf:"bytes,1,opt,name=alias,proto3" json:"alias,omitempty"`
+	// Membership Revoked.
+	Revoked bool `protobuf:"varint,2,opt,name=revoked,proto3" json:"revoked,omitempty"`
+	// Secret Keys of Earlier Records, Encrypted for the Alias.
+	Key []*RecordKey `protobuf:"bytes,3,rep,name=key,proto3" json:"key,omitempty"`
+	// Role of the Alias, Members Recorded Without a Role Cannot Write.
+	Role Role `protobuf:"varint,4,opt,name=role,proto3,enum=lab.Role" json:"role,omitempty"`
+	// Records of the Experiment are Not Encrypted, Set by the First Member.
+	Public               bool     `protobuf:"varint,5,opt,name=public,proto3" json:"public,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *Member) Reset()         { *m = Member{} }
+func (m *Member) String() string { return proto.CompactTextString(m) }
+func (*Member) ProtoMessage()    {}
+func (*Member) Descriptor() ([]byte, []int) {
+	return fileDescriptor_a33572512533a9b1, []int{7}
+}
+
+func (m *Member) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_Member.Unmarshal(m, b)
+}
+func (m *Member) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_Member.Marshal(b, m, deterministic)
+}
+func (m *Member) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_Member.Merge(m, src)
+}
+func (m *Member) XXX_Size() int {
+	return xxx_messageInfo_Member.Size(m)
+}
+func (m *Member) XXX_DiscardUnknown() {
+	xxx_messageInfo_Member.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_Member proto.InternalMessageInfo
+
+func (m *Member) GetAlias() string {
+	if m != nil {
+		return m.Alias
+	}
+	return ""
+}
+
+func (m *Member) GetRevoked() bool {
+	if m != nil {
+		return m.Revoked
+	}
+	return false
+}
+
+func (m *Member) GetKey() []*RecordKey {
+	if m != nil {
+		return m.Key
+	}
+	return nil
+}
+
+func (m *Member) GetRole() Role {
+	if m != nil {
+		return m.Role
+	}
+	return Role_UNKNOWN
+}
+
+func (m *Member) GetPublic() bool {
+	if m != nil {
+		return m.Public
+	}
+	return false
+}
+
+type RecordKey struct {
+	// Hash of Record.
+	RecordHash []byte `protobuf:"bytes,1,opt,name=record_hash,json=recordHash,proto3" json:"record_hash,omitempty"`
+	// Secret Key of Record, Encrypted with the Public Key of the Alias.
+	SecretKey            []byte   `protobuf:"bytes,2,opt,name=secret_key,json=secretKey,proto3" json:"secret_key,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *RecordKey) Reset()         { *m = RecordKey{} }
+func (m *RecordKey) String() string { return proto.CompactTextString(m) }
+func (*RecordKey) ProtoMessage()    {}
+func (*RecordKey) Descriptor() ([]byte, []int) {
+	return fileDescriptor_a33572512533a9b1, []int{8}
+}
+
+func (m *RecordKey) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_RecordKey.Unmarshal(m, b)
+}
+func (m *RecordKey) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_RecordKey.Marshal(b, m, deterministic)
+}
+func (m *RecordKey) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_RecordKey.Merge(m, src)
+}
+func (m *RecordKey) XXX_Size() int {
+	return xxx_messageInfo_RecordKey.Size(m)
+}
+func (m *RecordKey) XXX_DiscardUnknown() {
+	xxx_messageInfo_RecordKey.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_RecordKey proto.InternalMessageInfo
+
+func (m *RecordKey) GetRecordHash() []byte {
+	if m != nil {
+		return m.RecordHash
+	}
+	return nil
+}
+
+func (m *RecordKey) GetSecretKey() []byte {
+	if m != nil {
+		return m.SecretKey
+	}
+	return nil
+}
+
+type Settings struct {
+	// Proof-of-Work Threshold of Every Block in the Experiment's Channels.
+	Threshold            uint64   `protobuf:"varint,1,opt,name=threshold,proto3" json:"threshold,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *Settings) Reset()         { *m = Settings{} }
+func (m *Settings) String() string { return proto.CompactTextString(m) }
+func (*Settings) ProtoMessage()    {}
+func (*Settings) Descriptor() ([]byte, []int) {
+	return fileDescriptor_a33572512533a9b1, []int{9}
+}
+
+func (m *Settings) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_Settings.Unmarshal(m, b)
+}
+func (m *Settings) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_Settings.Marshal(b, m, deterministic)
+}
+func (m *Settings) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_Settings.Merge(m, src)
+}
+func (m *Settings) XXX_Size() int {
+	return xxx_messageInfo_Settings.Size(m)
+}
+func (m *Settings) XXX_DiscardUnknown() {
+	xxx_messageInfo_Settings.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_Settings proto.InternalMessageInfo
+
+func (m *Settings) GetThreshold() uint64 {
+	if m != nil {
+		return m.Threshold
+	}
+	return 0
+}
+
 func init() {
+	proto.RegisterEnum("lab.Compression", Compression_name, Compression_value)
+	proto.RegisterEnum("lab.Role", Role_name, Role_value)
 	proto.RegisterType((*Path)(nil), "lab.Path")
 	proto.RegisterType((*Delta)(nil), "lab.Delta")
+	proto.RegisterType((*ChunkReference)(nil), "lab.ChunkReference")
+	proto.RegisterType((*Snapshot)(nil), "lab.Snapshot")
 	proto.RegisterType((*RGBA)(nil), "lab.RGBA")
 	proto.RegisterType((*Draw)(nil), "lab.Draw")
 	proto.RegisterType((*Chat)(nil), "lab.Chat")
+	proto.RegisterType((*Member)(nil), "lab.Member")
+	proto.RegisterType((*RecordKey)(nil), "lab.RecordKey")
+	proto.RegisterType((*Settings)(nil), "lab.Settings")
 }
 
 func init() { proto.RegisterFile("lab.proto", fileDescriptor_a33572512533a9b1) }
 
 var fileDescriptor_a33572512533a9b1 = []byte{
-	// 297 bytes of a gzipped FileDescriptorProto
-	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x2c, 0x91, 0x41, 0x6b, 0xfa, 0x40,
-	0x10, 0xc5, 0x89, 0x49, 0xe4, 0x9f, 0xf9, 0x57, 0x90, 0x45, 0x4a, 0xf0, 0xd2, 0x90, 0x53, 0x4e,
-	0x11, 0xda, 0x4f, 0x60, 0x14, 0x4a, 0xc1, 0x83, 0xec, 0xc5, 0xd2, 0xdb, 0xac, 0x8e, 0x6e, 0x60,
-	0x75, 0xc3, 0x66, 0xad, 0xa5, 0x9f, 0xbe, 0xcc, 0x26, 0xb7, 0xdf, 0x7b, 0x3b, 0xfb, 0x66, 0x67,
-	0x16, 0x32, 0x83, 0xaa, 0xee, 0x9c, 0xf5, 0x56, 0xc4, 0x06, 0x55, 0xb9, 0x84, 0x64, 0x8f, 0x5e,
-	0x0b, 0x01, 0x49, 0x87, 0x5e, 0xe7, 0x51, 0x11, 0x57, 0x99, 0x0c, 0x5c, 0x7e, 0x40, 0xba, 0x25,
-	0xe3, 0x51, 0x3c, 0xc3, 0xd4, 0x9e, 0xcf, 0x3d, 0xf9, 0x3c, 0x2a, 0xa2, 0x2a, 0x91, 0xa3, 0x62,
-	0xdf, 0xd1, 0xd5, 0x7e, 0x53, 0x3e, 0x29, 0xa2, 0xea, 0x49, 0x8e, 0x4a, 0xcc, 0x21, 0xc6, 0xd3,
-	0x29, 0x8f, 0x83, 0xc9, 0x58, 0x7e, 0x42, 0x22, 0xdf, 0x9b, 0x35, 0x9f, 0x38, 0x3a, 0x85, 0x98,
-	0x99, 0x64, 0x14, 0x0b, 0x48, 0x2f, 0x8e, 0xe8, 0x16, 0x22, 0x66, 0x72, 0x10, 0xfc, 0x1c, 0x65,
-	0xee, 0x14, 0x22, 0x66, 0x32, 0x30, 0x57, 0xa2, 0xe9, 0x34, 0xe6, 0xc9, 0x50, 0x19, 0x44, 0x79,
-	0x80, 0x64, 0xeb, 0xf0, 0x21, 0x5e, 0x20, 0x3d, 0x5a, 0x63, 0x5d, 0xc8, 0xfe, 0xff, 0x9a, 0xd5,
-	0x3c, 0x28, 0xf7, 0x94, 0x83, 0xcf, 0x91, 0x7d, 0xfb, 0x4b, 0x63, 0x9f, 0xc0, 0x62, 0x09, 0xd3,
-	0xce, 0xb6, 0x37, 0xdf, 0xe7, 0x71, 0x11, 0x57, 0x69, 0x33, 0x99, 0x47, 0x72, 0x74, 0x78, 0x33,
-	0x1b, 0x8d, 0x9e, 0xef, 0x79, 0xfa, 0x19, 0x46, 0xcf, 0x64, 0xe0, 0xa6, 0x81, 0xc5, 0xd1, 0x5e,
-	0x6b, 0x34, 0xe4, 0x35, 0xb5, 0xf8, 0x40, 0x47, 0xdc, 0xaf, 0xf9, 0xb7, 0x43, 0xb5, 0xe7, 0xe5,
-	0x7e, 0x15, 0x97, 0xd6, 0xeb, 0xbb, 0xaa, 0x8f, 0xf6, 0xba, 0x5a, 0x8f, 0x65, 0x07, 0x74, 0xb4,
-	0xdb, 0x6d, 0x56, 0x06, 0xd5, 0xc5, 0xaa, 0x69, 0xf8, 0x85, 0xb7, 0xbf, 0x00, 0x00, 0x00, 0xff,
-	0xff, 0xb3, 0x8d, 0x8b, 0x5c, 0x92, 0x01, 0x00, 0x00,
+	// 668 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x94, 0x54, 0xcf, 0x6f, 0xb3, 0x46,
+	0x10, 0xfd, 0x30, 0xd8, 0x31, 0x83, 0x6d, 0xa1, 0xed, 0xa7, 0x0a, 0x7d, 0x6a, 0x14, 0xc4, 0x89,
+	0xe4, 0xe0, 0x54, 0x6e, 0x95, 0x53, 0x2f, 0xb1, 0x8d, 0xd2, 0xc8, 0xf1, 0x0f, 0xad, 0x93, 0xba,
+	0xca, 0x25, 0x5a, 0x60, 0x62, 0x50, 0x30, 0x6b, 0x2d, 0xeb, 0xa4, 0xe9, 0x7f, 0xd1, 0xfe, 0xc5,
+	0xd5, 0x2e, 0xe4, 0x47, 0x0f, 0x3d, 0xf4, 0x36, 0xef, 0xed, 0xbc, 0xd9, 0x99, 0xb7, 0x03, 0x60,
+	0x17, 0x2c, 0x1e, 0xee, 0x05, 0x97, 0x9c, 0x98, 0x05, 0x8b, 0x83, 0x9f, 0xc1, 0x5a, 0x31, 0x99,
+	0x11, 0x02, 0xd6, 0x9e, 0xc9, 0xcc, 0x33, 0x7c, 0x33, 0xb4, 0xa9, 0x8e, 0x89, 0x07, 0x47, 0x29,
+	0x16, 0x28, 0x31, 0xf5, 0x5a, 0xbe, 0x11, 0x76, 0xe9, 0x1b, 0x0c, 0xfe, 0x6e, 0x41, 0x7b, 0x8a,
+	0x85, 0x64, 0xe4, 0x7b, 0xe8, 0xf0, 0xc7, 0xc7, 0x0a, 0xa5, 0x67, 0xf8, 0x46, 0x68, 0xd1, 0x06,
+	0x29, 0x5e, 0xe0, 0x8e, 0x3f, 0xa3, 0x96, 0xf6, 0x68, 0x83, 0x88, 0x0b, 0x26, 0x4b, 0x53, 0xcf,
+	0xd4, 0xa4, 0x0a, 0xc9, 0x29, 0x74, 0xab, 0x92, 0xed, 0xab, 0x8c, 0x4b, 0xcf, 0xf2, 0x8d, 0xd0,
+	0x19, 0xf5, 0x87, 0xaa, 0xc9, 0x75, 0x43, 0xd2, 0xf7, 0x63, 0x72, 0x01, 0xbd, 0xba, 0xcc, 0x43,
+	0x92, 0x1d, 0xca, 0x27, 0xaf, 0xed, 0x9b, 0xa1, 0x33, 0xfa, 0x4e, 0xa7, 0x4f, 0x14, 0x43, 0xf1,
+	0x11, 0x05, 0x96, 0x09, 0x52, 0xa7, 0x4e, 0xd4, 0x2c, 0xf9, 0x11, 0x6c, 0x96, 0xa6, 0x8d, 0xa8,
+	0xf3, 0xdf, 0xa2, 0x2e, 0x4b, 0xd3, 0x5a, 0x31, 0x02, 0x27, 0xe1, 0xbb, 0xbd, 0xc0, 0xaa, 0xca,
+	0x79, 0xe9, 0x1d, 0xf9, 0x46, 0x38, 0x18, 0xb9, 0xb5, 0xe6, 0x83, 0xa7, 0x9f, 0x93, 0x82, 0x5f,
+	0x60, 0xf0, 0xef, 0x7a, 0xca, 0xd4, 0x8c, 0x55, 0x99, 0xb6, 0xa6, 0x47, 0x75, 0xac, 0x8c, 0x29,
+	0xb0, 0xdc, 0xca, 0x4c, 0x1b, 0x63, 0xd1, 0x06, 0x05, 0x17, 0xd0, 0x7d, 0x9b, 0xf8, 0x7f, 0xe9,
+	0x7e, 0x07, 0x8b, 0x5e, 0x8d, 0x2f, 0x95, 0xb1, 0x02, 0x53, 0x2d, 0xe9, 0x53, 0x15, 0x92, 0xaf,
+	0xd0, 0xde, 0x0a, 0xc4, 0x52, 0x0b, 0xfa, 0xb4, 0x06, 0xaa, 0x76, 0x5c, 0x1c, 0x50, 0xbf, 0x40,
+	0x9f, 0xea, 0x58, 0x65, 0xb2, 0x62, 0x9f, 0x31, 0xed, 0x7f, 0x9f, 0xd6, 0x20, 0xd8, 0x80, 0x35,
+	0x15, 0xec, 0x85, 0x9c, 0x40, 0x3b, 0xe1, 0x05, 0x17, 0xba, 0xb6, 0x33, 0xb2, 0xb5, 0x0b, 0xea,
+	0x4e, 0x5a, 0xf3, 0xaa, 0x64, 0x95, 0xff, 0x89, 0xcd, 0x3d, 0x3a, 0x26, 0xdf, 0xa0, 0xb3, 0xe7,
+	0x79, 0x29, 0x2b, 0xcf, 0xf4, 0xcd, 0xb0, 0x3d, 0x6e, 0xb9, 0x06, 0x6d, 0x98, 0xe0, 0x1b, 0x58,
+	0x93, 0x8c, 0xe9, 0x31, 0x25, 0xfe, 0x51, 0x6f, 0x8e, 0x4d, 0x75, 0x1c, 0xfc, 0x65, 0x40, 0x67,
+	0x8e, 0xbb, 0x18, 0x45, 0xdd, 0x55, 0xce, 0xaa, 0xe6, 0xbc, 0x06, 0x6a, 0x29, 0x05, 0x3e, 0xf3,
+	0xa7, 0x8f, 0xa5, 0x6c, 0x20, 0xf1, 0xc1, 0x7c, 0xc2, 0x57, 0x7d, 0x9f, 0x33, 0x1a, 0xd4, 0x5d,
+	0x62, 0xc2, 0x45, 0x3a, 0xc3, 0x57, 0xaa, 0x8e, 0xc8, 0x31, 0x58, 0x82, 0x17, 0xa8, 0xc7, 0x1c,
+	0xbc, 0x0d, 0xc2, 0x0b, 0xa4, 0x9a, 0x56, 0x16, 0xef, 0x0f, 0x71, 0x91, 0x27, 0x5e, 0x5b, 0x57,
+	0x6e, 0x50, 0x30, 0x03, 0xfb, 0xbd, 0x10, 0x39, 0x01, 0x47, 0x68, 0xf0, 0xf0, 0xe9, 0x89, 0xa0,
+	0xa6, 0x7e, 0x55, 0x0f, 0x75, 0x0c, 0x50, 0x61, 0x22, 0x50, 0x3e, 0xa8, 0x6e, 0xea, 0xed, 0xb7,
+	0x6b, 0x66, 0x86, 0xaf, 0x41, 0x08, 0xdd, 0x35, 0x4a, 0x99, 0x97, 0xdb, 0x8a, 0xfc, 0x00, 0xb6,
+	0xcc, 0x04, 0x56, 0x19, 0x2f, 0xd2, 0xe6, 0xfb, 0xf9, 0x20, 0xce, 0x4e, 0xc1, 0xf9, 0xb4, 0x6b,
+	0xc4, 0x85, 0xde, 0xdd, 0x62, 0xb2, 0x9c, 0xaf, 0x68, 0xb4, 0x5e, 0x47, 0x53, 0xf7, 0x0b, 0xe9,
+	0x82, 0x75, 0x75, 0x7f, 0xbd, 0x72, 0x8d, 0xb3, 0x08, 0x2c, 0x35, 0x07, 0x71, 0xe0, 0xe8, 0x6e,
+	0x31, 0x5b, 0x2c, 0x37, 0x0b, 0xf7, 0x0b, 0x01, 0xe8, 0xfc, 0x76, 0x1d, 0x6d, 0x22, 0xea, 0x1a,
+	0xa4, 0x0f, 0xf6, 0x64, 0x39, 0x9f, 0x47, 0x8b, 0xdb, 0x88, 0xba, 0x2d, 0x75, 0x14, 0x4d, 0xaf,
+	0x6f, 0x97, 0xd4, 0x35, 0x89, 0x0d, 0xed, 0xe5, 0x66, 0x11, 0x51, 0xd7, 0x1a, 0x8f, 0xe1, 0x6b,
+	0xc2, 0x77, 0x43, 0x56, 0xa0, 0xcc, 0x30, 0x67, 0x2f, 0x4c, 0xa0, 0xf2, 0x68, 0xdc, 0xbd, 0x61,
+	0xf1, 0x4a, 0xfd, 0x33, 0xee, 0xfd, 0x6d, 0x2e, 0xb3, 0x43, 0x3c, 0x4c, 0xf8, 0xee, 0xfc, 0xb2,
+	0x49, 0xdb, 0x30, 0x81, 0x37, 0x37, 0x93, 0xf3, 0x82, 0xc5, 0x5b, 0x1e, 0x77, 0xf4, 0xcf, 0xe5,
+	0xa7, 0x7f, 0x06, 0x00, 0x0a, 0x6b, 0x03, 0x80, 0x69, 0x04, 0x00, 0x00,
 }
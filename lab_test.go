@@ -0,0 +1,242 @@
+/*
+ * Copyright 2020 Aletheia Ware LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package labgo_test
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"fmt"
+	"github.com/AletheiaWareLLC/bcgo"
+	"github.com/AletheiaWareLLC/labgo"
+	"github.com/AletheiaWareLLC/testinggo"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func makeNode(t *testing.T, alias string) *labgo.Node {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	testinggo.AssertNoError(t, err)
+	return labgo.NewNode(&bcgo.Node{
+		Alias:    alias,
+		Key:      key,
+		Cache:    bcgo.NewMemoryCache(10),
+		Channels: make(map[string]*bcgo.Channel),
+	})
+}
+
+func TestClean(t *testing.T) {
+	node := makeNode(t, "Alice")
+	experiment, err := labgo.CreateFromReader(node, nil, nil, labgo.THRESHOLD_LAN, "foo/bar", ioutil.NopCloser(strings.NewReader("foobar")))
+	testinggo.AssertNoError(t, err)
+	blocks, size, err := labgo.Clean(node, experiment.ID)
+	testinggo.AssertNoError(t, err)
+	// One Settings block, one Member block, one Path block, and one File block
+	if blocks != 4 {
+		t.Fatalf("Incorrect blocks; expected '%d', got '%d'", 4, blocks)
+	}
+	if size == 0 {
+		t.Fatalf("Incorrect size; expected non-zero")
+	}
+	if len(node.Channels) != 0 {
+		t.Fatalf("Incorrect channels; expected none, got '%v'", node.GetChannels())
+	}
+	if _, err := node.Cache.GetHead(experiment.Path.Name); err == nil {
+		t.Fatalf("Expected head to be deleted")
+	}
+}
+
+func TestClean_Chunks(t *testing.T) {
+	node := makeNode(t, "Alice")
+	data := make([]byte, 2*labgo.CHUNK_MIN_SIZE)
+	rand.Read(data)
+	chunks := func() int {
+		channels, err := labgo.ListChannels(node.Cache)
+		testinggo.AssertNoError(t, err)
+		count := 0
+		for _, c := range channels {
+			if strings.HasPrefix(c, labgo.LAB_PREFIX_CHUNK) {
+				count++
+			}
+		}
+		return count
+	}
+	var experiments []*labgo.Experiment
+	for i := 0; i < 2; i++ {
+		experiment, err := labgo.CreateFromReader(node, nil, nil, labgo.THRESHOLD_LAN, "foo/bar", ioutil.NopCloser(bytes.NewReader(data)))
+		testinggo.AssertNoError(t, err)
+		experiments = append(experiments, experiment)
+	}
+	count := chunks()
+	if count == 0 {
+		t.Fatalf("Expected chunks")
+	}
+	// Chunks are shared with the second experiment
+	_, _, err := labgo.Clean(node, experiments[0].ID)
+	testinggo.AssertNoError(t, err)
+	if got := chunks(); got != count {
+		t.Fatalf("Incorrect chunks; expected '%d', got '%d'", count, got)
+	}
+	blocks, _, err := labgo.Clean(node, experiments[1].ID)
+	testinggo.AssertNoError(t, err)
+	if got := chunks(); got != 0 {
+		t.Fatalf("Incorrect chunks; expected '%d', got '%d'", 0, got)
+	}
+	// Settings, Member, Path, and File blocks, and a block for each chunk
+	if expected := uint64(4 + count); blocks != expected {
+		t.Fatalf("Incorrect blocks; expected '%d', got '%d'", expected, blocks)
+	}
+}
+
+func TestCreateFromPaths(t *testing.T) {
+	dir, err := ioutil.TempDir("", "lab")
+	testinggo.AssertNoError(t, err)
+	defer os.RemoveAll(dir)
+	source := filepath.Join(dir, "source")
+	testinggo.AssertNoError(t, os.MkdirAll(filepath.Join(source, "foo"), os.ModePerm))
+	testinggo.AssertNoError(t, ioutil.WriteFile(filepath.Join(source, "foo", "bar.txt"), []byte("foobar"), 0666))
+	node := makeNode(t, "Alice")
+	experiment, err := labgo.CreateFromPaths(node, nil, nil, labgo.THRESHOLD_LAN, source)
+	testinggo.AssertNoError(t, err)
+	files, err := labgo.ListFiles(node, experiment.Path)
+	testinggo.AssertNoError(t, err)
+	if len(files) != 1 {
+		t.Fatalf("Incorrect files; expected '%d', got '%d'", 1, len(files))
+	}
+	for _, p := range files {
+		if got := strings.Join(p, "/"); got != "foo/bar.txt" {
+			t.Fatalf("Incorrect path; expected '%s', got '%s'", "foo/bar.txt", got)
+		}
+	}
+	// Save over an existing, longer file
+	destination := filepath.Join(dir, "destination")
+	testinggo.AssertNoError(t, os.MkdirAll(filepath.Join(destination, "foo"), os.ModePerm))
+	testinggo.AssertNoError(t, ioutil.WriteFile(filepath.Join(destination, "foo", "bar.txt"), []byte("foobarbaz"), 0666))
+	testinggo.AssertNoError(t, labgo.Save(node, experiment, destination))
+	data, err := ioutil.ReadFile(filepath.Join(destination, "foo", "bar.txt"))
+	testinggo.AssertNoError(t, err)
+	if got := string(data); got != "foobar" {
+		t.Fatalf("Incorrect content; expected '%s', got '%s'", "foobar", got)
+	}
+}
+
+func TestOpenServedChannel(t *testing.T) {
+	node := makeNode(t, "Alice")
+	experiment, err := labgo.CreateFromReader(node, nil, nil, labgo.THRESHOLD_LAN, "foo.txt", ioutil.NopCloser(strings.NewReader("foobar")))
+	testinggo.AssertNoError(t, err)
+	id, err := labgo.GetFileId(node, experiment.Path, []string{"foo.txt"})
+	testinggo.AssertNoError(t, err)
+	t.Run("Known", func(t *testing.T) {
+		channel, err := labgo.OpenServedChannel(node, labgo.LAB_PREFIX_FILE+id)
+		testinggo.AssertNoError(t, err)
+		// Delta and Role Validators
+		if len(channel.Validators) != 3 {
+			t.Fatalf("Incorrect validators; expected '%d', got '%d'", 3, len(channel.Validators))
+		}
+	})
+	t.Run("Unknown", func(t *testing.T) {
+		_, err := labgo.OpenServedChannel(node, labgo.LAB_PREFIX_FILE+"foobar")
+		testinggo.AssertError(t, fmt.Sprintf(labgo.ERROR_EXPERIMENT_UNKNOWN, labgo.LAB_PREFIX_FILE+"foobar"), err)
+	})
+	t.Run("Experiment", func(t *testing.T) {
+		// Settings supplied by a peer for an experiment which is not open cannot lower its threshold
+		peer := makeNode(t, "Bob")
+		var remote *labgo.Experiment
+		for remote == nil || bcgo.Ones(remote.Settings.Head) >= labgo.THRESHOLD_DEFAULT {
+			// Mined at the LAN threshold, unless the hash reaches the default by chance
+			remote, err = labgo.CreateFromReader(peer, nil, nil, labgo.THRESHOLD_LAN, "", nil)
+			testinggo.AssertNoError(t, err)
+		}
+		chat, err := labgo.OpenServedChannel(node, remote.Chat.Name)
+		testinggo.AssertNoError(t, err)
+		settings, err := labgo.OpenServedChannel(node, remote.Settings.Name)
+		testinggo.AssertNoError(t, err)
+		block, err := peer.Cache.GetBlock(remote.Settings.Head)
+		testinggo.AssertNoError(t, err)
+		err = settings.Update(node.Cache, nil, remote.Settings.Head, block)
+		testinggo.AssertError(t, fmt.Sprintf(bcgo.ERROR_CHAIN_INVALID, fmt.Sprintf(bcgo.ERROR_HASH_TOO_WEAK, bcgo.Ones(remote.Settings.Head), labgo.THRESHOLD_DEFAULT)), err)
+		threshold, err := labgo.ChannelThreshold(node.Cache, nil, chat)
+		testinggo.AssertNoError(t, err)
+		if threshold != labgo.THRESHOLD_DEFAULT {
+			t.Fatalf("Incorrect threshold; expected '%d', got '%d'", labgo.THRESHOLD_DEFAULT, threshold)
+		}
+	})
+	t.Run("Chunk", func(t *testing.T) {
+		channel, err := labgo.OpenServedChannel(node, labgo.LAB_PREFIX_CHUNK+"foobar")
+		testinggo.AssertNoError(t, err)
+		// Validated at the threshold of the open experiment
+		threshold, err := labgo.ChannelThreshold(node.Cache, nil, channel)
+		testinggo.AssertNoError(t, err)
+		if threshold != labgo.THRESHOLD_LAN {
+			t.Fatalf("Incorrect threshold; expected '%d', got '%d'", labgo.THRESHOLD_LAN, threshold)
+		}
+	})
+}
+
+func TestSaveAt(t *testing.T) {
+	dir, err := ioutil.TempDir("", "lab")
+	testinggo.AssertNoError(t, err)
+	defer os.RemoveAll(dir)
+	node := makeNode(t, "Alice")
+	experiment, err := labgo.CreateFromReader(node, nil, nil, labgo.THRESHOLD_LAN, "foo.txt", ioutil.NopCloser(strings.NewReader("foo")))
+	testinggo.AssertNoError(t, err)
+	at := bcgo.Timestamp()
+	id, err := labgo.GetFileId(node, experiment.Path, []string{"foo.txt"})
+	testinggo.AssertNoError(t, err)
+	_, err = labgo.WriteProto(node, nil, labgo.GetFileChannel(node, id), nil, nil, &labgo.Delta{
+		Offset: 3,
+		Add:    []byte("bar"),
+	})
+	testinggo.AssertNoError(t, err)
+	_, _, err = labgo.CreatePathFromReader(node, nil, experiment.Path, nil, []string{"bar.txt"}, ioutil.NopCloser(strings.NewReader("bar")))
+	testinggo.AssertNoError(t, err)
+
+	testinggo.AssertNoError(t, labgo.SaveAt(node, experiment, dir, at))
+	data, err := ioutil.ReadFile(filepath.Join(dir, "foo.txt"))
+	testinggo.AssertNoError(t, err)
+	if got := string(data); got != "foo" {
+		t.Fatalf("Incorrect content; expected '%s', got '%s'", "foo", got)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "bar.txt")); !os.IsNotExist(err) {
+		t.Fatalf("Expected file to not exist")
+	}
+}
+
+func TestParseTimestamp(t *testing.T) {
+	node := makeNode(t, "Alice")
+	experiment, err := labgo.CreateFromReader(node, nil, nil, labgo.THRESHOLD_LAN, "foo.txt", ioutil.NopCloser(strings.NewReader("foo")))
+	testinggo.AssertNoError(t, err)
+	timestamp, err := labgo.ParseTimestamp(node.Cache, "1234")
+	testinggo.AssertNoError(t, err)
+	if timestamp != 1234 {
+		t.Fatalf("Incorrect timestamp; expected '%d', got '%d'", 1234, timestamp)
+	}
+	block, err := node.Cache.GetBlock(experiment.Path.Head)
+	testinggo.AssertNoError(t, err)
+	timestamp, err = labgo.ParseTimestamp(node.Cache, base64.RawURLEncoding.EncodeToString(experiment.Path.Head))
+	testinggo.AssertNoError(t, err)
+	if timestamp != block.Timestamp {
+		t.Fatalf("Incorrect timestamp; expected '%d', got '%d'", block.Timestamp, timestamp)
+	}
+	_, err = labgo.ParseTimestamp(node.Cache, "foobar")
+	testinggo.AssertError(t, fmt.Sprintf(labgo.ERROR_TIMESTAMP_INVALID, "foobar"), err)
+}
@@ -0,0 +1,218 @@
+/*
+ * Copyright 2020 Aletheia Ware LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package labgo
+
+import (
+	"encoding/base64"
+	"fmt"
+	"github.com/AletheiaWareLLC/bcgo"
+	"sort"
+	"strings"
+)
+
+const (
+	LOG_ACTION_ADD    = "added"
+	LOG_ACTION_EDIT   = "edited"
+	LOG_ACTION_RENAME = "renamed"
+	LOG_ACTION_DELETE = "deleted"
+)
+
+// LogEntry summarizes a change to a file in an experiment.
+type LogEntry struct {
+	Timestamp    uint64   `json:"timestamp"`
+	Creator      string   `json:"creator"`
+	Hash         []byte   `json:"hash"`
+	FileId       string   `json:"file"`
+	Action       string   `json:"action"`
+	Path         []string `json:"path"`
+	PreviousPath []string `json:"previous_path,omitempty"`
+	Offset       uint64   `json:"offset"`
+	Removed      uint64   `json:"removed"`
+	Added        uint64   `json:"added"`
+}
+
+func (e *LogEntry) String() string {
+	path := strings.Join(e.Path, "/")
+	switch e.Action {
+	case LOG_ACTION_ADD:
+		return fmt.Sprintf("%s added %s (+%s)", e.Creator, path, bcgo.BinarySizeToString(e.Added))
+	case LOG_ACTION_EDIT:
+		return fmt.Sprintf("%s edited %s at offset %d (-%d/+%d bytes)", e.Creator, path, e.Offset, e.Removed, e.Added)
+	case LOG_ACTION_RENAME:
+		return fmt.Sprintf("%s renamed %s to %s", e.Creator, strings.Join(e.PreviousPath, "/"), path)
+	case LOG_ACTION_DELETE:
+		return fmt.Sprintf("%s deleted %s", e.Creator, path)
+	}
+	return fmt.Sprintf("%s %s %s", e.Creator, e.Action, path)
+}
+
+// LogFilter restricts the entries of a log; zero values match everything.
+type LogFilter struct {
+	// Alias of the creator.
+	Alias string
+	// Path of a file, or of a directory containing files.
+	Path []string
+	// Earliest and latest timestamps, inclusive.
+	Since uint64
+	Until uint64
+}
+
+// Matches returns true if the entry satisfies every restriction of the filter.
+func (f *LogFilter) Matches(entry *LogEntry) bool {
+	if f == nil {
+		return true
+	}
+	if f.Alias != "" && f.Alias != entry.Creator {
+		return false
+	}
+	if len(f.Path) > 0 && !hasPathPrefix(entry.Path, f.Path) && !hasPathPrefix(entry.PreviousPath, f.Path) {
+		return false
+	}
+	if entry.Timestamp < f.Since {
+		return false
+	}
+	if f.Until != 0 && entry.Timestamp > f.Until {
+		return false
+	}
+	return true
+}
+
+type logEvent struct {
+	id     string
+	hash   []byte
+	record *bcgo.Record
+	path   *Path
+	delta  *Delta
+}
+
+// IterateLog calls the callback with a summary of each change to the files of the experiment which matches the filter, oldest first.
+// Changes from the path chain and every file chain are merged by timestamp, and the initial content of a file is reported together with its creation.
+func IterateLog(node *Node, experiment *Experiment, filter *LogFilter, callback func(*LogEntry) error) error {
+	var events []*logEvent
+	var ids []string
+	if err := IteratePaths(node, experiment.Path, func(id string, hash []byte, record *bcgo.Record, path *Path) error {
+		if id == base64.RawURLEncoding.EncodeToString(hash) {
+			// Record created the file
+			ids = append(ids, id)
+		}
+		events = append(events, &logEvent{
+			id:     id,
+			hash:   hash,
+			record: record,
+			path:   path,
+		})
+		return nil
+	}); err != nil {
+		return err
+	}
+	for _, id := range ids {
+		// Only the lengths of chunks are needed
+		if err := iterateDeltas(node, GetFileChannel(node, id), false, func(hash []byte, record *bcgo.Record, delta *Delta) error {
+			if delta.Snapshot != nil {
+				// Snapshots do not change the file
+				return nil
+			}
+			events = append(events, &logEvent{
+				id:     id,
+				hash:   hash,
+				record: record,
+				delta:  delta,
+			})
+			return nil
+		}); err != nil {
+			return err
+		}
+	}
+	// Merge chronologically, path records precede deltas with the same timestamp
+	sort.SliceStable(events, func(i, j int) bool {
+		return events[i].record.Timestamp < events[j].record.Timestamp
+	})
+
+	paths := make(map[string][]string)
+	var pending *LogEntry
+	flush := func() error {
+		if pending == nil {
+			return nil
+		}
+		entry := pending
+		pending = nil
+		if !filter.Matches(entry) {
+			return nil
+		}
+		return callback(entry)
+	}
+	for _, e := range events {
+		if d := e.delta; d != nil {
+			if pending != nil && pending.Action == LOG_ACTION_ADD && pending.FileId == e.id && pending.Creator == e.record.Creator && removeLength(d) == 0 && d.Offset == pending.Added {
+				// Fold initial content into creation
+				pending.Added += addLength(d)
+				continue
+			}
+			if err := flush(); err != nil {
+				return err
+			}
+			pending = &LogEntry{
+				Timestamp: e.record.Timestamp,
+				Creator:   e.record.Creator,
+				Hash:      e.hash,
+				FileId:    e.id,
+				Action:    LOG_ACTION_EDIT,
+				Path:      paths[e.id],
+				Offset:    d.Offset,
+				Removed:   removeLength(d),
+				Added:     addLength(d),
+			}
+			continue
+		}
+		if err := flush(); err != nil {
+			return err
+		}
+		previous, ok := paths[e.id]
+		pending = &LogEntry{
+			Timestamp: e.record.Timestamp,
+			Creator:   e.record.Creator,
+			Hash:      e.hash,
+			FileId:    e.id,
+			Path:      e.path.Path,
+		}
+		switch {
+		case e.path.Deleted:
+			pending.Action = LOG_ACTION_DELETE
+			pending.Path = previous
+		case ok:
+			pending.Action = LOG_ACTION_RENAME
+			pending.PreviousPath = previous
+			paths[e.id] = e.path.Path
+		default:
+			pending.Action = LOG_ACTION_ADD
+			paths[e.id] = e.path.Path
+		}
+	}
+	return flush()
+}
+
+func hasPathPrefix(path, prefix []string) bool {
+	if len(path) < len(prefix) {
+		return false
+	}
+	for i, s := range prefix {
+		if path[i] != s {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,97 @@
+/*
+ * Copyright 2020 Aletheia Ware LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package labgo_test
+
+import (
+	"github.com/AletheiaWareLLC/bcgo"
+	"github.com/AletheiaWareLLC/labgo"
+	"github.com/AletheiaWareLLC/testinggo"
+	"io/ioutil"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestIterateLog(t *testing.T) {
+	node := makeNode(t, "Alice")
+	experiment, err := labgo.CreateFromReader(node, nil, nil, labgo.THRESHOLD_LAN, "foo.txt", ioutil.NopCloser(strings.NewReader("foobar")))
+	testinggo.AssertNoError(t, err)
+	id, err := labgo.GetFileId(node, experiment.Path, []string{"foo.txt"})
+	testinggo.AssertNoError(t, err)
+	_, err = labgo.WriteProto(node, nil, labgo.GetFileChannel(node, id), nil, nil, &labgo.Delta{
+		Offset: 3,
+		Remove: []byte("bar"),
+		Add:    []byte("blah"),
+	})
+	testinggo.AssertNoError(t, err)
+	middle := bcgo.Timestamp()
+	_, err = labgo.RenamePath(node, nil, experiment.Path, nil, id, []string{"src", "foo.txt"})
+	testinggo.AssertNoError(t, err)
+	_, err = labgo.DeletePath(node, nil, experiment.Path, nil, id)
+	testinggo.AssertNoError(t, err)
+
+	log := func(filter *labgo.LogFilter) (summaries []string) {
+		testinggo.AssertNoError(t, labgo.IterateLog(node, experiment, filter, func(entry *labgo.LogEntry) error {
+			summaries = append(summaries, entry.String())
+			return nil
+		}))
+		return
+	}
+	all := []string{
+		"Alice added foo.txt (+6Bytes)",
+		"Alice edited foo.txt at offset 3 (-3/+4 bytes)",
+		"Alice renamed foo.txt to src/foo.txt",
+		"Alice deleted src/foo.txt",
+	}
+	for name, tt := range map[string]struct {
+		filter *labgo.LogFilter
+		want   []string
+	}{
+		"All": {
+			want: all,
+		},
+		"Alias": {
+			filter: &labgo.LogFilter{
+				Alias: "Bob",
+			},
+		},
+		"Path": {
+			filter: &labgo.LogFilter{
+				Path: []string{"src"},
+			},
+			want: all[2:],
+		},
+		"Since": {
+			filter: &labgo.LogFilter{
+				Since: middle,
+			},
+			want: all[2:],
+		},
+		"Until": {
+			filter: &labgo.LogFilter{
+				Until: middle,
+			},
+			want: all[:2],
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			if got := log(tt.filter); !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("Incorrect log; expected '%v', got '%v'", tt.want, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,516 @@
+/*
+ * Copyright 2020 Aletheia Ware LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package labgo
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha512"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"github.com/AletheiaWareLLC/aliasgo"
+	"github.com/AletheiaWareLLC/bcgo"
+	"github.com/AletheiaWareLLC/cryptogo"
+	"math"
+	"sort"
+	"strings"
+)
+
+const (
+	// Maximum number of record keys shared in a single Member record
+	MAX_MEMBER_KEYS = 1024
+
+	ERROR_MEMBER_BACKDATED     = "Member record predates the latest change of membership: %s"
+	ERROR_MEMBER_FIRST_INVALID = "First member must be the creator of the experiment and an owner: %s"
+	ERROR_NOT_MEMBER           = "Not a member: %s"
+	ERROR_RECORD_BACKDATED     = "Record predates the latest change to the role of %s"
+	ERROR_ROLE_INSUFFICIENT    = "Role of %s does not permit writing to %s: %s"
+	ERROR_ROLE_UNRECOGNIZED    = "Role unrecognized: %s"
+)
+
+// Membership is the history of the aliases invited to, and revoked from, an experiment, and of their roles.
+// The records of a private experiment are encrypted for its current members.
+type Membership struct {
+	creator string
+	public  bool
+	// True if the experiment has no member channel, so aliases which wrote before members were recorded may continue to write
+	legacy bool
+	// Timestamp of the latest change, changes are applied in the order of the member chain and cannot predate it
+	latest  uint64
+	changes map[string][]*membershipChange
+	keys    map[string][]*RecordKey
+}
+
+func newMembership(creator string) *Membership {
+	return &Membership{
+		creator: creator,
+		changes: make(map[string][]*membershipChange),
+		keys:    make(map[string][]*RecordKey),
+	}
+}
+
+// ownerMembership returns the membership of a public experiment without members, which only its creator owns.
+func ownerMembership(creator string) *Membership {
+	membership := newMembership(creator)
+	membership.public = true
+	membership.legacy = true
+	if creator != "" {
+		membership.changes[creator] = []*membershipChange{
+			{
+				role: Role_OWNER,
+			},
+		}
+	}
+	return membership
+}
+
+type membershipChange struct {
+	timestamp uint64
+	revoked   bool
+	role      Role
+}
+
+// ParseRole returns the role with the given name, ignoring case.
+func ParseRole(name string) (Role, error) {
+	role, ok := Role_value[strings.ToUpper(name)]
+	if !ok || Role(role) == Role_UNKNOWN {
+		return 0, errors.New(fmt.Sprintf(ERROR_ROLE_UNRECOGNIZED, name))
+	}
+	return Role(role), nil
+}
+
+// Permits returns true if the role is at least as privileged as the required role.
+// An unknown role permits nothing.
+func (r Role) Permits(required Role) bool {
+	// Roles are declared from least to most privileged
+	return r != Role_UNKNOWN && r >= required
+}
+
+// RequiredRole returns the least privileged role permitted to write to the Lab channel with the given name.
+func RequiredRole(name string) Role {
+	switch {
+	case strings.HasPrefix(name, LAB_PREFIX_CHAT):
+		return Role_VIEWER
+	case strings.HasPrefix(name, LAB_PREFIX_DRAW):
+		return Role_COMMENTER
+	case strings.HasPrefix(name, LAB_PREFIX_FILE), strings.HasPrefix(name, LAB_PREFIX_PATH):
+		return Role_EDITOR
+	default:
+		return Role_OWNER
+	}
+}
+
+// GetMembership reads the membership of the experiment from its member channel, or from peers if the channel is not cached.
+// An experiment without a member channel is public, and owned by its creator, the only alias permitted to write to it.
+func GetMembership(cache bcgo.Cache, network bcgo.Network, experimentId string) (*Membership, error) {
+	creator, err := GetCreator(cache, network, experimentId)
+	if err != nil {
+		return nil, err
+	}
+	name := LAB_PREFIX_MEMBER + experimentId
+	reference, err := bcgo.GetHeadReference(name, cache, network)
+	if err != nil {
+		// No members
+		return ownerMembership(creator), nil
+	}
+	membership := newMembership(creator)
+	if err := bcgo.IterateChronologically(name, reference.BlockHash, nil, cache, network, func(hash []byte, block *bcgo.Block) error {
+		for _, entry := range block.Entry {
+			if len(entry.Record.Access) > 0 {
+				// Member records are public
+				continue
+			}
+			if err := membership.apply(entry); err != nil {
+				return err
+			}
+		}
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+	return membership, nil
+}
+
+// GetCreator returns the alias which created the experiment, that is the creator of its settings record, from whose hash the experiment's ID is derived.
+// Experiments created before settings were recorded were created by the creator of the first record in their path channel, or an empty string is returned if the channel has no records.
+func GetCreator(cache bcgo.Cache, network bcgo.Network, experimentId string) (string, error) {
+	name := LAB_PREFIX_SETTINGS + experimentId
+	if reference, err := bcgo.GetHeadReference(name, cache, network); err == nil {
+		block, err := bcgo.GetBlock(name, cache, network, reference.BlockHash)
+		if err != nil {
+			return "", err
+		}
+		for _, entry := range block.Entry {
+			hash, err := cryptogo.HashProtobuf(entry.Record)
+			if err != nil {
+				return "", err
+			}
+			if settingsExperimentId(hash) == experimentId {
+				return entry.Record.Creator, nil
+			}
+		}
+	}
+	name = LAB_PREFIX_PATH + experimentId
+	reference, err := bcgo.GetHeadReference(name, cache, network)
+	if err != nil {
+		// No paths
+		return "", nil
+	}
+	return firstCreator(name, reference.BlockHash, nil, cache, network)
+}
+
+// firstCreator returns the creator of the first record in the chain ending with the given block.
+func firstCreator(channel string, hash []byte, block *bcgo.Block, cache bcgo.Cache, network bcgo.Network) (string, error) {
+	var creator string
+	if err := bcgo.Iterate(channel, hash, block, cache, network, func(h []byte, b *bcgo.Block) error {
+		if len(b.Entry) > 0 {
+			creator = b.Entry[0].Record.Creator
+		}
+		return nil
+	}); err != nil {
+		return "", err
+	}
+	return creator, nil
+}
+
+// IsPublic returns true if the experiment's records are not encrypted for its members.
+func (m *Membership) IsPublic() bool {
+	return m.public
+}
+
+// IsMember returns true if the alias was a member at the given timestamp, that is it was invited at or before the timestamp and not revoked since.
+func (m *Membership) IsMember(alias string, timestamp uint64) bool {
+	_, ok := m.RoleAt(alias, timestamp)
+	return ok
+}
+
+// RoleAt returns the role of the alias at the given timestamp, and false if it was not a member at the time.
+func (m *Membership) RoleAt(alias string, timestamp uint64) (Role, bool) {
+	var latest *membershipChange
+	for _, c := range m.changes[alias] {
+		if c.timestamp <= timestamp && (latest == nil || c.timestamp >= latest.timestamp) {
+			latest = c
+		}
+	}
+	if latest == nil || latest.revoked {
+		return 0, false
+	}
+	return latest.role, true
+}
+
+// Creator returns the alias which created the experiment.
+func (m *Membership) Creator() string {
+	return m.creator
+}
+
+// Members returns the sorted aliases of the current members.
+func (m *Membership) Members() []string {
+	var members []string
+	for alias := range m.changes {
+		if m.IsMember(alias, math.MaxUint64) {
+			members = append(members, alias)
+		}
+	}
+	sort.Strings(members)
+	return members
+}
+
+// Keys returns the secret keys of earlier records shared with the alias when it was invited.
+func (m *Membership) Keys(alias string) []*RecordKey {
+	return m.keys[alias]
+}
+
+// apply validates the Member record in the entry and adds it to the membership.
+// The first record must be created by the experiment's creator and invite it as an owner, every later record must be created by an owner, and only members can be revoked.
+// Records cannot predate the record before them, so an alias cannot use a role it has since lost.
+func (m *Membership) apply(entry *bcgo.BlockEntry) error {
+	member := &Member{}
+	if err := unmarshalPayload(entry, member); err != nil {
+		return err
+	}
+	record := entry.Record
+	if record.Timestamp < m.latest {
+		return errors.New(fmt.Sprintf(ERROR_MEMBER_BACKDATED, record.Creator))
+	}
+	if len(m.changes) == 0 {
+		if member.Alias != record.Creator || member.Revoked || member.Role != Role_OWNER || (m.creator != "" && m.creator != record.Creator) {
+			return errors.New(fmt.Sprintf(ERROR_MEMBER_FIRST_INVALID, record.Creator))
+		}
+		m.creator = record.Creator
+		m.public = member.Public
+	} else if role, ok := m.RoleAt(record.Creator, record.Timestamp); !ok {
+		return errors.New(fmt.Sprintf(ERROR_NOT_MEMBER, record.Creator))
+	} else if !role.Permits(Role_OWNER) {
+		return errors.New(fmt.Sprintf(ERROR_ROLE_INSUFFICIENT, record.Creator, LAB_PREFIX_MEMBER, role))
+	}
+	if member.Revoked && !m.IsMember(member.Alias, record.Timestamp) {
+		return errors.New(fmt.Sprintf(ERROR_NOT_MEMBER, member.Alias))
+	}
+	m.changes[member.Alias] = append(m.changes[member.Alias], &membershipChange{
+		timestamp: record.Timestamp,
+		revoked:   member.Revoked,
+		role:      member.Role,
+	})
+	m.latest = record.Timestamp
+	if !member.Revoked {
+		m.keys[member.Alias] = append(m.keys[member.Alias], member.Key...)
+	}
+	return nil
+}
+
+// Invite adds the alias as a member of the experiment with the given role, and if the experiment is private future records are encrypted for it.
+// Inviting an existing member changes its role.
+// If history is true the secret keys of the existing records the node can read are encrypted for the alias and shared in the member channel, so it can also read the experiment's history.
+func Invite(node *Node, listener bcgo.MiningListener, experiment *Experiment, alias string, role Role, history bool) error {
+	membership, err := checkOwner(node, experiment)
+	if err != nil {
+		return err
+	}
+	key, err := aliasgo.GetPublicKey(getAliasChannel(node), node.Cache, node.Network, alias)
+	if err != nil {
+		return errors.New(fmt.Sprintf(ERROR_RECIPIENT_NOT_FOUND, alias))
+	}
+	var keys []*RecordKey
+	if history {
+		keys, err = shareRecordKeys(node, experiment, key)
+		if err != nil {
+			return err
+		}
+	}
+	if err := writeCreatorMember(node, listener, experiment, membership); err != nil {
+		return err
+	}
+	for {
+		member := &Member{
+			Alias: alias,
+			Role:  role,
+		}
+		if len(keys) > MAX_MEMBER_KEYS {
+			member.Key, keys = keys[:MAX_MEMBER_KEYS], keys[MAX_MEMBER_KEYS:]
+		} else {
+			member.Key, keys = keys, nil
+		}
+		if _, err := WriteProto(node, listener, experiment.Member, nil, nil, member); err != nil {
+			return err
+		}
+		if len(keys) == 0 {
+			break
+		}
+	}
+	if !membership.IsPublic() {
+		experiment.Access[alias] = key
+	}
+	return nil
+}
+
+// Revoke removes the alias from the members of the experiment, so it can no longer write to it and future records are no longer encrypted for it.
+// Records the alias could already read remain readable to it.
+func Revoke(node *Node, listener bcgo.MiningListener, experiment *Experiment, alias string) error {
+	membership, err := checkOwner(node, experiment)
+	if err != nil {
+		return err
+	}
+	if !membership.IsMember(alias, math.MaxUint64) {
+		return errors.New(fmt.Sprintf(ERROR_NOT_MEMBER, alias))
+	}
+	if err := writeCreatorMember(node, listener, experiment, membership); err != nil {
+		return err
+	}
+	if _, err := WriteProto(node, listener, experiment.Member, nil, nil, &Member{
+		Alias:   alias,
+		Revoked: true,
+	}); err != nil {
+		return err
+	}
+	delete(experiment.Access, alias)
+	return nil
+}
+
+// MemberValidator ensures every record in a member channel is a Member created by an owner of the experiment, the first by the experiment's creator.
+type MemberValidator struct {
+	ExperimentId string
+}
+
+func (v *MemberValidator) Validate(channel *bcgo.Channel, cache bcgo.Cache, network bcgo.Network, hash []byte, block *bcgo.Block) error {
+	creator, err := GetCreator(cache, network, v.ExperimentId)
+	if err != nil {
+		return err
+	}
+	return iterateEntries(channel, cache, network, hash, block, newMembership(creator).apply)
+}
+
+// RoleValidator ensures every record in the blocks of a channel the node has not yet accepted was created by a member whose role at the time permits writing to the channel, and does not predate the latest change to the role of its creator.
+// Blocks already accepted were validated against the membership at the time, and in an experiment without members identify the aliases which wrote to the channel before members were recorded, which may continue to write.
+type RoleValidator struct {
+	ExperimentId string
+}
+
+func (v *RoleValidator) Validate(channel *bcgo.Channel, cache bcgo.Cache, network bcgo.Network, hash []byte, block *bcgo.Block) error {
+	membership, err := GetMembership(cache, network, v.ExperimentId)
+	if err != nil {
+		return err
+	}
+	if membership.Creator() == "" && strings.HasPrefix(channel.Name, LAB_PREFIX_PATH) {
+		// The first path of an experiment without members identifies its creator
+		creator, err := firstCreator(channel.Name, hash, block, cache, network)
+		if err != nil {
+			return err
+		}
+		membership = ownerMembership(creator)
+	}
+	// Blocks already accepted, and the aliases which created their records
+	accepted := make(map[string]bool)
+	writers := make(map[string]bool)
+	head := channel.Head
+	if head == nil {
+		if reference, err := cache.GetHead(channel.Name); err == nil {
+			head = reference.BlockHash
+		}
+	}
+	if err := bcgo.Iterate(channel.Name, head, nil, cache, network, func(h []byte, b *bcgo.Block) error {
+		accepted[base64.RawURLEncoding.EncodeToString(h)] = true
+		for _, entry := range b.Entry {
+			writers[entry.Record.Creator] = true
+		}
+		return nil
+	}); err != nil {
+		return err
+	}
+	required := RequiredRole(channel.Name)
+	if err := bcgo.Iterate(channel.Name, hash, block, cache, network, func(h []byte, b *bcgo.Block) error {
+		if accepted[base64.RawURLEncoding.EncodeToString(h)] {
+			return bcgo.StopIterationError{}
+		}
+		for _, entry := range b.Entry {
+			creator, timestamp := entry.Record.Creator, entry.Record.Timestamp
+			if membership.legacy && writers[creator] {
+				continue
+			}
+			role, ok := membership.RoleAt(creator, timestamp)
+			if !ok {
+				return errors.New(fmt.Sprintf(ERROR_NOT_MEMBER, creator))
+			}
+			if !role.Permits(required) {
+				return errors.New(fmt.Sprintf(ERROR_ROLE_INSUFFICIENT, creator, channel.Name, role))
+			}
+			if changes := membership.changes[creator]; timestamp < changes[len(changes)-1].timestamp {
+				return errors.New(fmt.Sprintf(ERROR_RECORD_BACKDATED, creator))
+			}
+		}
+		return nil
+	}); err != nil {
+		switch err.(type) {
+		case bcgo.StopIterationError:
+			// Do nothing
+			break
+		default:
+			return err
+		}
+	}
+	return nil
+}
+
+// checkOwner ensures the node is one of the experiment's current owners, and returns the experiment's membership.
+func checkOwner(node *Node, experiment *Experiment) (*Membership, error) {
+	membership, err := GetMembership(node.Cache, node.Network, experiment.ID)
+	if err != nil {
+		return nil, err
+	}
+	role, ok := membership.RoleAt(node.Alias, math.MaxUint64)
+	if !ok {
+		return nil, errors.New(fmt.Sprintf(ERROR_NOT_MEMBER, node.Alias))
+	}
+	if !role.Permits(Role_OWNER) {
+		return nil, errors.New(fmt.Sprintf(ERROR_ROLE_INSUFFICIENT, node.Alias, experiment.Member.Name, role))
+	}
+	return membership, nil
+}
+
+// writeCreatorMember writes the Member record of the experiment's creator, as an owner, if the experiment was created without a member channel, so the records written after it are created by a member.
+func writeCreatorMember(node *Node, listener bcgo.MiningListener, experiment *Experiment, membership *Membership) error {
+	channel := experiment.Member
+	if channel.Head != nil {
+		return nil
+	}
+	if batch := getBatch(node); batch != nil && batch.pending(channel.Name) {
+		// Already written
+		return nil
+	}
+	if _, err := bcgo.GetHeadReference(channel.Name, node.Cache, node.Network); err == nil {
+		// Members exist, but are not yet loaded
+		return nil
+	}
+	creator, err := GetCreator(node.Cache, node.Network, experiment.ID)
+	if err != nil {
+		return err
+	}
+	_, err = WriteProto(node, listener, channel, nil, nil, &Member{
+		Alias:  creator,
+		Role:   Role_OWNER,
+		Public: membership.IsPublic(),
+	})
+	return err
+}
+
+// shareRecordKeys returns the secret keys of every encrypted record in the experiment which the node can read, encrypted with the given public key.
+func shareRecordKeys(node *Node, experiment *Experiment, key *rsa.PublicKey) ([]*RecordKey, error) {
+	channels := []*bcgo.Channel{
+		experiment.Path,
+		experiment.Chat,
+		experiment.Draw,
+	}
+	// Include the channels of every file, even those deleted or renamed
+	seen := make(map[string]bool)
+	if err := IteratePaths(node, experiment.Path, func(id string, hash []byte, record *bcgo.Record, path *Path) error {
+		if !seen[id] {
+			seen[id] = true
+			channels = append(channels, GetFileChannel(node, id))
+		}
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+	var keys []*RecordKey
+	for _, channel := range channels {
+		if err := bcgo.IterateChronologically(channel.Name, channel.Head, nil, node.Cache, node.Network, func(hash []byte, block *bcgo.Block) error {
+			for _, entry := range block.Entry {
+				if len(entry.Record.Access) == 0 {
+					// Public record
+					continue
+				}
+				secret, err := recordSecretKey(node, entry)
+				if err != nil {
+					// Not readable by the node
+					continue
+				}
+				encrypted, err := rsa.EncryptOAEP(sha512.New(), rand.Reader, key, secret, nil)
+				if err != nil {
+					return err
+				}
+				keys = append(keys, &RecordKey{
+					RecordHash: entry.RecordHash,
+					SecretKey:  encrypted,
+				})
+			}
+			return nil
+		}); err != nil {
+			return nil, err
+		}
+	}
+	return keys, nil
+}
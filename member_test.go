@@ -0,0 +1,389 @@
+/*
+ * Copyright 2020 Aletheia Ware LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package labgo_test
+
+import (
+	"fmt"
+	"github.com/AletheiaWareLLC/bcgo"
+	"github.com/AletheiaWareLLC/labgo"
+	"github.com/AletheiaWareLLC/testinggo"
+	"io/ioutil"
+	"strings"
+	"testing"
+)
+
+func TestGetMembership(t *testing.T) {
+	cache := bcgo.NewMemoryCache(10)
+	alice := makeRegisteredNode(t, "Alice", cache)
+	makeRegisteredNode(t, "Bob", cache)
+	t.Run("Public", func(t *testing.T) {
+		experiment, err := labgo.CreateFromReader(alice, nil, nil, labgo.THRESHOLD_LAN, "", nil)
+		testinggo.AssertNoError(t, err)
+		membership, err := labgo.GetMembership(cache, nil, experiment.ID)
+		testinggo.AssertNoError(t, err)
+		if !membership.IsPublic() {
+			t.Fatalf("Expected public experiment")
+		}
+	})
+	t.Run("Private", func(t *testing.T) {
+		experiment, err := labgo.CreateFromReader(alice, nil, []string{"Bob"}, labgo.THRESHOLD_LAN, "", nil)
+		testinggo.AssertNoError(t, err)
+		membership, err := labgo.GetMembership(cache, nil, experiment.ID)
+		testinggo.AssertNoError(t, err)
+		if membership.IsPublic() {
+			t.Fatalf("Expected private experiment")
+		}
+		if got := strings.Join(membership.Members(), ","); got != "Alice,Bob" {
+			t.Fatalf("Incorrect members; expected 'Alice,Bob', got '%s'", got)
+		}
+		if membership.IsMember("Bob", 0) {
+			t.Fatalf("Bob was not a member before the experiment was created")
+		}
+	})
+	t.Run("WithoutMembers", func(t *testing.T) {
+		// Experiment created before members were recorded is owned by the creator of its first path
+		_, err := labgo.WriteProto(alice, nil, labgo.OpenPathChannel(alice, "foobar"), nil, nil, &labgo.Path{
+			Path: []string{"foo.txt"},
+		})
+		testinggo.AssertNoError(t, err)
+		membership, err := labgo.GetMembership(cache, nil, "foobar")
+		testinggo.AssertNoError(t, err)
+		if !membership.IsPublic() {
+			t.Fatalf("Expected public experiment")
+		}
+		if got := strings.Join(membership.Members(), ","); got != "Alice" {
+			t.Fatalf("Incorrect members; expected 'Alice', got '%s'", got)
+		}
+	})
+}
+
+func TestInvite(t *testing.T) {
+	cache := bcgo.NewMemoryCache(10)
+	alice := makeRegisteredNode(t, "Alice", cache)
+	bob := makeRegisteredNode(t, "Bob", cache)
+	charlie := makeRegisteredNode(t, "Charlie", cache)
+	experiment, err := labgo.CreateFromReader(alice, nil, []string{"Alice"}, labgo.THRESHOLD_LAN, "foo.txt", ioutil.NopCloser(strings.NewReader("foobar")))
+	testinggo.AssertNoError(t, err)
+	t.Run("Future", func(t *testing.T) {
+		testinggo.AssertNoError(t, labgo.Invite(alice, nil, experiment, "Bob", labgo.Role_EDITOR, false))
+		_, err := labgo.PostChat(alice, nil, experiment.Chat, experiment.Access, "Hello Bob")
+		testinggo.AssertNoError(t, err)
+		opened, err := labgo.Open(bob, experiment.ID)
+		testinggo.AssertNoError(t, err)
+		if got := strings.Join(labgo.Recipients(opened.Access), ","); got != "Alice,Bob" {
+			t.Fatalf("Incorrect recipients; expected 'Alice,Bob', got '%s'", got)
+		}
+		// New records are readable
+		testinggo.AssertNoError(t, labgo.IterateChat(bob, opened.Chat, func(hash []byte, record *bcgo.Record, chat *labgo.Chat) error {
+			return nil
+		}))
+		// Earlier records are not
+		_, err = labgo.ListFiles(bob, opened.Path)
+		testinggo.AssertError(t, fmt.Sprintf(labgo.ERROR_ACCESS_DENIED, "Bob"), err)
+	})
+	t.Run("History", func(t *testing.T) {
+		testinggo.AssertNoError(t, labgo.Invite(alice, nil, experiment, "Charlie", labgo.Role_EDITOR, true))
+		opened, err := labgo.Open(charlie, experiment.ID)
+		testinggo.AssertNoError(t, err)
+		id, err := labgo.GetFileId(charlie, opened.Path, []string{"foo.txt"})
+		testinggo.AssertNoError(t, err)
+		buffer, err := labgo.ChannelToBuffer(charlie, labgo.GetFileChannel(charlie, id))
+		testinggo.AssertNoError(t, err)
+		if string(buffer) != "foobar" {
+			t.Fatalf("Incorrect content; expected 'foobar', got '%s'", buffer)
+		}
+	})
+	t.Run("Unknown", func(t *testing.T) {
+		testinggo.AssertError(t, fmt.Sprintf(labgo.ERROR_RECIPIENT_NOT_FOUND, "Dave"), labgo.Invite(alice, nil, experiment, "Dave", labgo.Role_EDITOR, false))
+	})
+	t.Run("Public", func(t *testing.T) {
+		public, err := labgo.CreateFromReader(alice, nil, nil, labgo.THRESHOLD_LAN, "", nil)
+		testinggo.AssertNoError(t, err)
+		testinggo.AssertNoError(t, labgo.Invite(alice, nil, public, "Bob", labgo.Role_VIEWER, false))
+		if public.Access != nil {
+			t.Fatalf("Expected records of public experiment not to be encrypted")
+		}
+		opened, err := labgo.Open(bob, public.ID)
+		testinggo.AssertNoError(t, err)
+		_, err = labgo.PostChat(bob, nil, opened.Chat, opened.Access, "Hello Alice")
+		testinggo.AssertNoError(t, err)
+	})
+}
+
+func TestInvite_WithoutMembers(t *testing.T) {
+	cache := bcgo.NewMemoryCache(10)
+	alice := makeRegisteredNode(t, "Alice", cache)
+	bob := makeRegisteredNode(t, "Bob", cache)
+	makeRegisteredNode(t, "Charlie", cache)
+	// Experiment created before members were recorded
+	_, err := labgo.WriteProto(alice, nil, labgo.OpenPathChannel(alice, "foobar"), nil, nil, &labgo.Path{
+		Path: []string{"foo.txt"},
+	})
+	testinggo.AssertNoError(t, err)
+	experiment, err := labgo.Open(alice, "foobar")
+	testinggo.AssertNoError(t, err)
+	testinggo.AssertNoError(t, labgo.Invite(alice, nil, experiment, "Bob", labgo.Role_EDITOR, false))
+	membership, err := labgo.GetMembership(cache, nil, "foobar")
+	testinggo.AssertNoError(t, err)
+	if !membership.IsPublic() {
+		t.Fatalf("Expected public experiment")
+	}
+	if got := strings.Join(membership.Members(), ","); got != "Alice,Bob" {
+		t.Fatalf("Incorrect members; expected 'Alice,Bob', got '%s'", got)
+	}
+	opened, err := labgo.Open(bob, "foobar")
+	testinggo.AssertNoError(t, err)
+	_, err = labgo.PostChat(bob, nil, opened.Chat, opened.Access, "Hello Alice")
+	testinggo.AssertNoError(t, err)
+
+	// Revoking from another experiment created before members were recorded
+	_, err = labgo.WriteProto(alice, nil, labgo.OpenPathChannel(alice, "barfoo"), nil, nil, &labgo.Path{
+		Path: []string{"foo.txt"},
+	})
+	testinggo.AssertNoError(t, err)
+	experiment, err = labgo.Open(alice, "barfoo")
+	testinggo.AssertNoError(t, err)
+	testinggo.AssertError(t, fmt.Sprintf(labgo.ERROR_NOT_MEMBER, "Charlie"), labgo.Revoke(alice, nil, experiment, "Charlie"))
+	testinggo.AssertNoError(t, labgo.Revoke(alice, nil, experiment, "Alice"))
+}
+
+func TestRevoke(t *testing.T) {
+	cache := bcgo.NewMemoryCache(10)
+	alice := makeRegisteredNode(t, "Alice", cache)
+	bob := makeRegisteredNode(t, "Bob", cache)
+	experiment, err := labgo.CreateFromReader(alice, nil, []string{"Bob"}, labgo.THRESHOLD_LAN, "", nil)
+	testinggo.AssertNoError(t, err)
+	testinggo.AssertNoError(t, labgo.Revoke(alice, nil, experiment, "Bob"))
+	if got := strings.Join(labgo.Recipients(experiment.Access), ","); got != "Alice" {
+		t.Fatalf("Incorrect recipients; expected 'Alice', got '%s'", got)
+	}
+	testinggo.AssertError(t, fmt.Sprintf(labgo.ERROR_NOT_MEMBER, "Bob"), labgo.Revoke(alice, nil, experiment, "Bob"))
+
+	// Revoked members can no longer write
+	opened, err := labgo.Open(bob, experiment.ID)
+	testinggo.AssertNoError(t, err)
+	_, err = labgo.PostChat(bob, nil, opened.Chat, opened.Access, "Hello Alice")
+	testinggo.AssertError(t, fmt.Sprintf(labgo.ERROR_NOT_MEMBER, "Bob"), err)
+	testinggo.AssertError(t, fmt.Sprintf(labgo.ERROR_NOT_MEMBER, "Bob"), labgo.Invite(bob, nil, opened, "Bob", labgo.Role_EDITOR, false))
+}
+
+func TestMemberValidator(t *testing.T) {
+	alice := makeNode(t, "Alice")
+	bob := makeNode(t, "Bob")
+	bob.Cache = alice.Cache
+	t.Run("FirstNotOwner", func(t *testing.T) {
+		_, err := labgo.WriteProto(alice, nil, labgo.OpenMemberChannel("foobar"), nil, nil, &labgo.Member{
+			Alias: "Bob",
+		})
+		testinggo.AssertError(t, fmt.Sprintf(bcgo.ERROR_CHAIN_INVALID, fmt.Sprintf(labgo.ERROR_MEMBER_FIRST_INVALID, "Alice")), err)
+	})
+	t.Run("FirstNotCreator", func(t *testing.T) {
+		// Experiment created by Alice before members were recorded
+		_, err := labgo.WriteProto(alice, nil, labgo.OpenPathChannel(alice, "barfoo"), nil, nil, &labgo.Path{
+			Path: []string{"foo.txt"},
+		})
+		testinggo.AssertNoError(t, err)
+		_, err = labgo.WriteProto(bob, nil, labgo.OpenMemberChannel("barfoo"), nil, nil, &labgo.Member{
+			Alias: "Bob",
+			Role:  labgo.Role_OWNER,
+		})
+		testinggo.AssertError(t, fmt.Sprintf(bcgo.ERROR_CHAIN_INVALID, fmt.Sprintf(labgo.ERROR_MEMBER_FIRST_INVALID, "Bob")), err)
+	})
+	t.Run("WithoutPaths", func(t *testing.T) {
+		// Experiment created by Alice is owned by her before any path is recorded
+		experiment, err := labgo.CreateFromReader(alice, nil, nil, labgo.THRESHOLD_LAN, "", nil)
+		testinggo.AssertNoError(t, err)
+		creator, err := labgo.GetCreator(alice.Cache, nil, experiment.ID)
+		testinggo.AssertNoError(t, err)
+		if creator != "Alice" {
+			t.Fatalf("Incorrect creator; expected 'Alice', got '%s'", creator)
+		}
+		// Bob cannot replace the member chain with one he owns
+		_, err = labgo.WriteProto(bob, nil, labgo.OpenMemberChannel(experiment.ID), nil, nil, &labgo.Member{
+			Alias: "Bob",
+			Role:  labgo.Role_OWNER,
+		})
+		testinggo.AssertError(t, fmt.Sprintf(bcgo.ERROR_CHAIN_INVALID, fmt.Sprintf(labgo.ERROR_MEMBER_FIRST_INVALID, "Bob")), err)
+	})
+	t.Run("Backdated", func(t *testing.T) {
+		experiment, err := labgo.CreateFromReader(alice, nil, nil, labgo.THRESHOLD_LAN, "", nil)
+		testinggo.AssertNoError(t, err)
+		_, err = labgo.WriteProto(alice, nil, experiment.Member, nil, nil, &labgo.Member{
+			Alias: "Bob",
+			Role:  labgo.Role_OWNER,
+		})
+		testinggo.AssertNoError(t, err)
+		_, err = labgo.WriteProto(alice, nil, experiment.Member, nil, nil, &labgo.Member{
+			Alias:   "Bob",
+			Revoked: true,
+		})
+		testinggo.AssertNoError(t, err)
+		// Bob claims to have invited Charlie before being revoked
+		block, err := alice.Cache.GetBlock(experiment.Member.Head)
+		testinggo.AssertNoError(t, err)
+		hash, record, err := labgo.ProtoToRecord("Bob", bob.Key, block.Entry[0].Record.Timestamp-1, nil, &labgo.Member{
+			Alias: "Charlie",
+			Role:  labgo.Role_OWNER,
+		})
+		testinggo.AssertNoError(t, err)
+		_, _, err = bob.MineEntries(experiment.Member, labgo.THRESHOLD_LAN, nil, []*bcgo.BlockEntry{
+			&bcgo.BlockEntry{
+				RecordHash: hash,
+				Record:     record,
+			},
+		})
+		testinggo.AssertError(t, fmt.Sprintf(bcgo.ERROR_CHAIN_INVALID, fmt.Sprintf(labgo.ERROR_MEMBER_BACKDATED, "Bob")), err)
+	})
+}
+
+func TestParseRole(t *testing.T) {
+	role, err := labgo.ParseRole("viewer")
+	testinggo.AssertNoError(t, err)
+	if role != labgo.Role_VIEWER {
+		t.Fatalf("Incorrect role; expected '%s', got '%s'", labgo.Role_VIEWER, role)
+	}
+	_, err = labgo.ParseRole("admin")
+	testinggo.AssertError(t, fmt.Sprintf(labgo.ERROR_ROLE_UNRECOGNIZED, "admin"), err)
+	_, err = labgo.ParseRole("unknown")
+	testinggo.AssertError(t, fmt.Sprintf(labgo.ERROR_ROLE_UNRECOGNIZED, "unknown"), err)
+}
+
+func TestRolePermits(t *testing.T) {
+	for _, tt := range []struct {
+		role, required labgo.Role
+		expected       bool
+	}{
+		{labgo.Role_OWNER, labgo.Role_EDITOR, true},
+		{labgo.Role_EDITOR, labgo.Role_EDITOR, true},
+		{labgo.Role_COMMENTER, labgo.Role_EDITOR, false},
+		{labgo.Role_VIEWER, labgo.Role_VIEWER, true},
+		{labgo.Role_UNKNOWN, labgo.Role_VIEWER, false},
+		{labgo.Role_UNKNOWN, labgo.Role_UNKNOWN, false},
+	} {
+		if got := tt.role.Permits(tt.required); got != tt.expected {
+			t.Fatalf("Incorrect permission of %s to write as %s; expected '%t', got '%t'", tt.role, tt.required, tt.expected, got)
+		}
+	}
+}
+
+func TestRoleValidator(t *testing.T) {
+	cache := bcgo.NewMemoryCache(10)
+	alice := makeRegisteredNode(t, "Alice", cache)
+	bob := makeRegisteredNode(t, "Bob", cache)
+	charlie := makeRegisteredNode(t, "Charlie", cache)
+	experiment, err := labgo.CreateFromReader(alice, nil, []string{"Alice"}, labgo.THRESHOLD_LAN, "foo.txt", ioutil.NopCloser(strings.NewReader("foobar")))
+	testinggo.AssertNoError(t, err)
+	testinggo.AssertNoError(t, labgo.Invite(alice, nil, experiment, "Bob", labgo.Role_VIEWER, true))
+	opened, err := labgo.Open(bob, experiment.ID)
+	testinggo.AssertNoError(t, err)
+	stroke := &labgo.Draw{
+		Color: &labgo.RGBA{
+			Red:   255,
+			Alpha: 255,
+		},
+		Size:   1,
+		Points: []int32{0, 0, 9, 0},
+	}
+	t.Run("NotMember", func(t *testing.T) {
+		// Bypass the access list to write a public record into the private experiment
+		channel := labgo.OpenPathChannel(charlie, experiment.ID)
+		_, err := labgo.WriteProto(charlie, nil, channel, nil, nil, &labgo.Path{
+			Path: []string{"foo.txt"},
+		})
+		testinggo.AssertError(t, fmt.Sprintf(bcgo.ERROR_CHAIN_INVALID, fmt.Sprintf(labgo.ERROR_NOT_MEMBER, "Charlie")), err)
+	})
+	t.Run("ViewerChat", func(t *testing.T) {
+		_, err := labgo.PostChat(bob, nil, opened.Chat, opened.Access, "Hello Alice")
+		testinggo.AssertNoError(t, err)
+	})
+	t.Run("Promote", func(t *testing.T) {
+		testinggo.AssertNoError(t, labgo.Invite(alice, nil, experiment, "Charlie", labgo.Role_VIEWER, false))
+		testinggo.AssertNoError(t, labgo.Invite(alice, nil, experiment, "Charlie", labgo.Role_COMMENTER, false))
+		opened, err := labgo.Open(charlie, experiment.ID)
+		testinggo.AssertNoError(t, err)
+		_, err = labgo.AddStroke(charlie, nil, opened.Draw, opened.Access, stroke)
+		testinggo.AssertNoError(t, err)
+	})
+	t.Run("ViewerDraw", func(t *testing.T) {
+		_, err := labgo.AddStroke(bob, nil, opened.Draw, opened.Access, stroke)
+		testinggo.AssertError(t, fmt.Sprintf(bcgo.ERROR_CHAIN_INVALID, fmt.Sprintf(labgo.ERROR_ROLE_INSUFFICIENT, "Bob", opened.Draw.Name, labgo.Role_VIEWER)), err)
+	})
+	t.Run("ViewerDelta", func(t *testing.T) {
+		id, err := labgo.GetFileId(bob, opened.Path, []string{"foo.txt"})
+		testinggo.AssertNoError(t, err)
+		file := labgo.GetFileChannel(bob, id)
+		_, err = labgo.WriteDelta(bob, nil, file, opened.Access, &labgo.Delta{
+			Offset: 6,
+			Add:    []byte("blah"),
+		})
+		testinggo.AssertError(t, fmt.Sprintf(bcgo.ERROR_CHAIN_INVALID, fmt.Sprintf(labgo.ERROR_ROLE_INSUFFICIENT, "Bob", file.Name, labgo.Role_VIEWER)), err)
+	})
+	t.Run("ViewerInvite", func(t *testing.T) {
+		testinggo.AssertError(t, fmt.Sprintf(labgo.ERROR_ROLE_INSUFFICIENT, "Bob", opened.Member.Name, labgo.Role_VIEWER), labgo.Invite(bob, nil, opened, "Charlie", labgo.Role_VIEWER, false))
+	})
+	t.Run("PublicNotMember", func(t *testing.T) {
+		public, err := labgo.CreateFromReader(alice, nil, nil, labgo.THRESHOLD_LAN, "", nil)
+		testinggo.AssertNoError(t, err)
+		opened, err := labgo.Open(charlie, public.ID)
+		testinggo.AssertNoError(t, err)
+		_, err = labgo.PostChat(charlie, nil, opened.Chat, opened.Access, "Hello Alice")
+		testinggo.AssertError(t, fmt.Sprintf(bcgo.ERROR_CHAIN_INVALID, fmt.Sprintf(labgo.ERROR_NOT_MEMBER, "Charlie")), err)
+	})
+	t.Run("WithoutMembers", func(t *testing.T) {
+		// Experiment created, and chatted in by Alice and Bob, before members were recorded
+		_, err := labgo.WriteProto(alice, nil, labgo.OpenPathChannel(alice, "foobar"), nil, nil, &labgo.Path{
+			Path: []string{"foo.txt"},
+		})
+		testinggo.AssertNoError(t, err)
+		chat := &bcgo.Channel{
+			Name: labgo.LAB_PREFIX_CHAT + "foobar",
+		}
+		for _, n := range []*labgo.Node{alice, bob} {
+			_, err := labgo.WriteProto(n, nil, chat, nil, nil, &labgo.Chat{
+				Text: "Hello from " + n.Alias,
+			})
+			testinggo.AssertNoError(t, err)
+		}
+		for _, n := range []*labgo.Node{alice, bob} {
+			opened, err := labgo.Open(n, "foobar")
+			testinggo.AssertNoError(t, err)
+			_, err = labgo.PostChat(n, nil, opened.Chat, opened.Access, "Still writing")
+			testinggo.AssertNoError(t, err)
+		}
+		opened, err := labgo.Open(charlie, "foobar")
+		testinggo.AssertNoError(t, err)
+		_, err = labgo.PostChat(charlie, nil, opened.Chat, opened.Access, "Hello Alice")
+		testinggo.AssertError(t, fmt.Sprintf(bcgo.ERROR_CHAIN_INVALID, fmt.Sprintf(labgo.ERROR_NOT_MEMBER, "Charlie")), err)
+	})
+	t.Run("Backdated", func(t *testing.T) {
+		testinggo.AssertNoError(t, labgo.Revoke(alice, nil, experiment, "Bob"))
+		// Bob claims to have chatted before being revoked
+		block, err := cache.GetBlock(experiment.Member.Head)
+		testinggo.AssertNoError(t, err)
+		hash, record, err := labgo.ProtoToRecord("Bob", bob.Key, block.Entry[0].Record.Timestamp-1, nil, &labgo.Chat{
+			Text: "Hello Alice",
+		})
+		testinggo.AssertNoError(t, err)
+		_, _, err = bob.MineEntries(opened.Chat, labgo.THRESHOLD_LAN, nil, []*bcgo.BlockEntry{
+			&bcgo.BlockEntry{
+				RecordHash: hash,
+				Record:     record,
+			},
+		})
+		testinggo.AssertError(t, fmt.Sprintf(bcgo.ERROR_CHAIN_INVALID, fmt.Sprintf(labgo.ERROR_RECORD_BACKDATED, "Bob")), err)
+	})
+}
@@ -0,0 +1,66 @@
+/*
+ * Copyright 2020 Aletheia Ware LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package labgo
+
+import (
+	"crypto/rsa"
+	"encoding/base64"
+	"github.com/AletheiaWareLLC/bcgo"
+	"sync"
+)
+
+// Node is a bcgo.Node along with the state labgo keeps for it between calls.
+type Node struct {
+	*bcgo.Node
+	lock sync.RWMutex
+	// Secret keys of records shared with the node's alias after the records were created, keyed by record hash
+	sharedKeys map[string][]byte
+	verifier   verifier
+	// Batch of records written by the node, if it is batching
+	batch  *Batch
+	outbox Outbox
+	// Held while applying blocks broadcast by peers, or reading and updating the heads of channels with blocks the node mined or reconciled, but not while mining or pushing
+	update sync.Mutex
+}
+
+func NewNode(node *bcgo.Node) *Node {
+	return &Node{
+		Node:       node,
+		sharedKeys: make(map[string][]byte),
+		verifier: verifier{
+			keys: make(map[string]*rsa.PublicKey),
+		},
+		outbox: NewMemoryOutbox(),
+	}
+}
+
+// addSharedKeys remembers the secret keys of records shared with the node's alias.
+func (n *Node) addSharedKeys(keys []*RecordKey) {
+	n.lock.Lock()
+	defer n.lock.Unlock()
+	for _, k := range keys {
+		n.sharedKeys[base64.RawURLEncoding.EncodeToString(k.RecordHash)] = k.SecretKey
+	}
+}
+
+// sharedKey returns the secret key of the record with the given hash, if it was shared with the node's alias.
+func (n *Node) sharedKey(hash []byte) ([]byte, bool) {
+	n.lock.RLock()
+	defer n.lock.RUnlock()
+	key, ok := n.sharedKeys[base64.RawURLEncoding.EncodeToString(hash)]
+	return key, ok
+}
@@ -0,0 +1,307 @@
+/*
+ * Copyright 2020 Aletheia Ware LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package labgo
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"github.com/AletheiaWareLLC/bcgo"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// Initial delay between attempts to push the channels in an outbox, doubled after each attempt which leaves channels unpushed
+	PUSH_RETRY_MIN = 5 * time.Second
+	// Maximum delay between attempts to push the channels in an outbox
+	PUSH_RETRY_MAX = 10 * time.Minute
+
+	ERROR_PUSH_DEFERRED = "Could not push %s, added to outbox: %s"
+)
+
+// Outbox records the heads of channels which were mined locally, but could not be pushed to peers.
+type Outbox interface {
+	// Put records the head of the channel as not yet pushed, replacing any earlier head.
+	Put(channel string, head []byte) error
+	// Remove forgets the channel once its head has been pushed.
+	Remove(channel string) error
+	// Heads returns the unpushed head of each channel.
+	Heads() (map[string][]byte, error)
+}
+
+// MemoryOutbox is an Outbox which is lost when the process exits.
+type MemoryOutbox struct {
+	sync.Mutex
+	heads map[string][]byte
+}
+
+func NewMemoryOutbox() *MemoryOutbox {
+	return &MemoryOutbox{
+		heads: make(map[string][]byte),
+	}
+}
+
+func (o *MemoryOutbox) Put(channel string, head []byte) error {
+	o.Lock()
+	defer o.Unlock()
+	o.heads[channel] = head
+	return nil
+}
+
+func (o *MemoryOutbox) Remove(channel string) error {
+	o.Lock()
+	defer o.Unlock()
+	delete(o.heads, channel)
+	return nil
+}
+
+func (o *MemoryOutbox) Heads() (map[string][]byte, error) {
+	o.Lock()
+	defer o.Unlock()
+	heads := make(map[string][]byte, len(o.heads))
+	for k, v := range o.heads {
+		heads[k] = v
+	}
+	return heads, nil
+}
+
+// FileOutbox is an Outbox which persists across processes, as a file per channel holding the unpushed head.
+// Files are named by the base64url encoding of the channel name, as names received from peers may contain path separators.
+type FileOutbox struct {
+	Directory string
+}
+
+func NewFileOutbox(directory string) (*FileOutbox, error) {
+	if err := os.MkdirAll(directory, 0700); err != nil {
+		return nil, err
+	}
+	return &FileOutbox{
+		Directory: directory,
+	}, nil
+}
+
+func (o *FileOutbox) Put(channel string, head []byte) error {
+	return ioutil.WriteFile(o.path(channel), head, 0600)
+}
+
+func (o *FileOutbox) Remove(channel string) error {
+	if err := os.Remove(o.path(channel)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+func (o *FileOutbox) Heads() (map[string][]byte, error) {
+	files, err := ioutil.ReadDir(o.Directory)
+	if err != nil {
+		return nil, err
+	}
+	heads := make(map[string][]byte, len(files))
+	for _, f := range files {
+		if f.IsDir() {
+			continue
+		}
+		channel, err := base64.RawURLEncoding.DecodeString(f.Name())
+		if err != nil {
+			log.Println("Ignoring outbox file:", f.Name(), err)
+			continue
+		}
+		head, err := ioutil.ReadFile(filepath.Join(o.Directory, f.Name()))
+		if err != nil {
+			return nil, err
+		}
+		heads[string(channel)] = head
+	}
+	return heads, nil
+}
+
+// path returns the path of the file holding the unpushed head of the channel.
+func (o *FileOutbox) path(channel string) string {
+	return filepath.Join(o.Directory, base64.RawURLEncoding.EncodeToString([]byte(channel)))
+}
+
+// SetOutbox sets where the node records the channels it could not push to peers.
+// Nodes without an outbox set use a MemoryOutbox.
+func SetOutbox(node *Node, outbox Outbox) {
+	node.lock.Lock()
+	defer node.lock.Unlock()
+	node.outbox = outbox
+}
+
+// GetOutbox returns the node's outbox.
+func GetOutbox(node *Node) Outbox {
+	node.lock.RLock()
+	defer node.lock.RUnlock()
+	return node.outbox
+}
+
+// pushChannel pushes the channel to peers, and returns true if it was delivered.
+// If peers are unreachable the channel's head is added to the node's outbox instead, so the write still succeeds locally.
+func pushChannel(node *Node, channel *bcgo.Channel) (bool, error) {
+	if node.Network == nil {
+		return false, nil
+	}
+	outbox := GetOutbox(node)
+	// Push Update to Peers
+	if err := push(node, channel); err != nil {
+		log.Printf(ERROR_PUSH_DEFERRED+"\n", channel.Name, err)
+		return false, outbox.Put(channel.Name, channel.Head)
+	}
+	// Pushing the head delivers any earlier blocks still in the outbox
+	return true, outbox.Remove(channel.Name)
+}
+
+// push pushes the channel to peers, and if they reject it as out of date, or keep a competing head, rebases the node's records onto the chain they hold and pushes again.
+func push(node *Node, channel *bcgo.Channel) error {
+	head, err := broadcast(node, channel)
+	if err == nil {
+		// Peers keep their head when offered a competing chain of the same length, without reporting it as out of date
+		reference, e := node.Network.GetHead(channel.Name)
+		if e != nil || bytes.Equal(reference.BlockHash, head) {
+			return nil
+		}
+	} else if err.Error() != bcgo.ERROR_CHANNEL_OUT_OF_DATE {
+		return err
+	}
+	if _, err := reconcile(node, nil, channel, head); err != nil {
+		return err
+	}
+	_, err = broadcast(node, channel)
+	return err
+}
+
+// broadcast pushes a copy of the channel to peers, and returns the head pushed.
+// When a peer holds a longer chain bcgo pulls it into the channel pushed in the background, so the channel itself is only updated by reconciling it.
+func broadcast(node *Node, channel *bcgo.Channel) ([]byte, error) {
+	node.update.Lock()
+	c := &bcgo.Channel{
+		Name:      channel.Name,
+		Head:      channel.Head,
+		Timestamp: channel.Timestamp,
+	}
+	node.update.Unlock()
+	return c.Head, c.Push(node.Cache, node.Network)
+}
+
+// PushOutbox pushes the head of each channel in the node's outbox to peers, after rebasing its records onto any competing chain, and returns the number of channels still unpushed along with the last error encountered.
+// The node's update lock is only held while a channel's head is read and updated, not while it is pushed, as a peer pushing to the node at the same time would wait on it.
+func PushOutbox(node *Node) (int, error) {
+	outbox := GetOutbox(node)
+	heads, err := outbox.Heads()
+	if err != nil {
+		return 0, err
+	}
+	if node.Network == nil {
+		return len(heads), nil
+	}
+	var last error
+	pending := 0
+	for _, name := range pushOrder(node, heads) {
+		channel, err := node.GetChannel(name)
+		if err != nil {
+			channel, err = OpenServedChannel(node, name)
+			if err != nil {
+				channel = &bcgo.Channel{
+					Name: name,
+				}
+			}
+			if err := channel.LoadCachedHead(node.Cache); err != nil {
+				log.Println(err)
+			}
+		}
+		// The channel may have been updated to a chain without the unpushed head, such as one broadcast by a peer
+		if _, err := Reconcile(node, nil, channel, heads[name]); err != nil {
+			last = err
+			pending++
+			continue
+		}
+		if err := push(node, channel); err != nil {
+			last = err
+			pending++
+			continue
+		}
+		if err := outbox.Remove(name); err != nil {
+			return pending, err
+		}
+	}
+	return pending, last
+}
+
+// pushOrder returns the names of the channels with the given heads in the order peers must receive them, as each channel is validated against those it depends on.
+// The settings of experiments come first, then their members, then their paths, then the other channels in the order their heads were mined.
+func pushOrder(node *Node, heads map[string][]byte) []string {
+	rank := func(name string) int {
+		for i, prefix := range []string{
+			LAB_PREFIX_SETTINGS,
+			LAB_PREFIX_MEMBER,
+			LAB_PREFIX_PATH,
+		} {
+			if strings.HasPrefix(name, prefix) {
+				return i
+			}
+		}
+		return 3
+	}
+	var names []string
+	timestamps := make(map[string]uint64, len(heads))
+	for name, head := range heads {
+		names = append(names, name)
+		if block, err := node.Cache.GetBlock(head); err == nil {
+			timestamps[name] = block.Timestamp
+		}
+	}
+	sort.Slice(names, func(i, j int) bool {
+		a, b := names[i], names[j]
+		if ra, rb := rank(a), rank(b); ra != rb {
+			return ra < rb
+		}
+		if ta, tb := timestamps[a], timestamps[b]; ta != tb {
+			return ta < tb
+		}
+		return a < b
+	})
+	return names
+}
+
+// RetryPush pushes the channels in the node's outbox until every channel has been pushed or the context is cancelled.
+// The delay between attempts starts at min and doubles, up to max, after each attempt which leaves channels unpushed.
+func RetryPush(ctx context.Context, node *Node, min, max time.Duration) error {
+	delay := min
+	for {
+		pending, err := PushOutbox(node)
+		if pending == 0 {
+			return err
+		}
+		log.Println("Channels unpushed:", pending, err)
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(delay):
+		}
+		delay *= 2
+		if delay > max {
+			delay = max
+		}
+	}
+}
@@ -0,0 +1,280 @@
+/*
+ * Copyright 2020 Aletheia Ware LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package labgo_test
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"github.com/AletheiaWareLLC/bcgo"
+	"github.com/AletheiaWareLLC/labgo"
+	"github.com/AletheiaWareLLC/testinggo"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// peerNetwork records the heads broadcast to it, and fails while offline.
+type peerNetwork struct {
+	sync.Mutex
+	offline bool
+	heads   map[string][]byte
+}
+
+func (n *peerNetwork) setOffline(offline bool) {
+	n.Lock()
+	defer n.Unlock()
+	n.offline = offline
+}
+
+func (n *peerNetwork) head(channel string) []byte {
+	n.Lock()
+	defer n.Unlock()
+	return n.heads[channel]
+}
+
+func (n *peerNetwork) GetHead(channel string) (*bcgo.Reference, error) {
+	return nil, errors.New("No peers")
+}
+
+func (n *peerNetwork) GetBlock(reference *bcgo.Reference) (*bcgo.Block, error) {
+	return nil, errors.New("No peers")
+}
+
+func (n *peerNetwork) Broadcast(channel *bcgo.Channel, cache bcgo.Cache, hash []byte, block *bcgo.Block) error {
+	n.Lock()
+	defer n.Unlock()
+	if n.offline {
+		return errors.New("Peers unreachable")
+	}
+	n.heads[channel.Name] = hash
+	return nil
+}
+
+// servedNetwork applies broadcasts to a peer node as Serve does, opening the channels the peer does not have, and fails while offline.
+type servedNetwork struct {
+	sync.Mutex
+	peer    *labgo.Node
+	offline bool
+}
+
+func (n *servedNetwork) setOffline(offline bool) {
+	n.Lock()
+	defer n.Unlock()
+	n.offline = offline
+}
+
+func (n *servedNetwork) GetHead(channel string) (*bcgo.Reference, error) {
+	return n.peer.Cache.GetHead(channel)
+}
+
+func (n *servedNetwork) GetBlock(reference *bcgo.Reference) (*bcgo.Block, error) {
+	return n.peer.Cache.GetBlock(reference.BlockHash)
+}
+
+func (n *servedNetwork) Broadcast(channel *bcgo.Channel, cache bcgo.Cache, hash []byte, block *bcgo.Block) error {
+	n.Lock()
+	defer n.Unlock()
+	if n.offline {
+		return errors.New("Peers unreachable")
+	}
+	c, err := n.peer.GetChannel(channel.Name)
+	if err != nil {
+		c, err = labgo.OpenServedChannel(n.peer, channel.Name)
+		if err != nil {
+			return err
+		}
+		n.peer.AddChannel(c)
+	}
+	// Earlier blocks are read from the sender's cache
+	return c.Update(n.peer.Cache, &cacheNetwork{cache}, hash, block)
+}
+
+func TestOutbox(t *testing.T) {
+	dir := testinggo.MakeTempDir(t, "outbox")
+	defer testinggo.UnmakeTempDir(t, dir)
+	file, err := labgo.NewFileOutbox(dir)
+	testinggo.AssertNoError(t, err)
+	for name, outbox := range map[string]labgo.Outbox{
+		"Memory": labgo.NewMemoryOutbox(),
+		"File":   file,
+	} {
+		t.Run(name, func(t *testing.T) {
+			testinggo.AssertNoError(t, outbox.Put("Lab-Chat-foobar", []byte("head1")))
+			testinggo.AssertNoError(t, outbox.Put("Lab-Path-foobar", []byte("head2")))
+			testinggo.AssertNoError(t, outbox.Put("Lab-Chat-foobar", []byte("head3")))
+			testinggo.AssertNoError(t, outbox.Remove("Lab-Path-foobar"))
+			testinggo.AssertNoError(t, outbox.Remove("Lab-Draw-foobar"))
+			heads, err := outbox.Heads()
+			testinggo.AssertNoError(t, err)
+			if len(heads) != 1 {
+				t.Fatalf("Incorrect heads; expected '%d', got '%d'", 1, len(heads))
+			}
+			testinggo.AssertHashEqual(t, []byte("head3"), heads["Lab-Chat-foobar"])
+		})
+	}
+	t.Run("PathSeparator", func(t *testing.T) {
+		dir := filepath.Join(dir, "separator")
+		file, err := labgo.NewFileOutbox(dir)
+		testinggo.AssertNoError(t, err)
+		name := "Lab-Chat-../../foo/bar"
+		testinggo.AssertNoError(t, file.Put(name, []byte("head")))
+		files, err := ioutil.ReadDir(dir)
+		testinggo.AssertNoError(t, err)
+		if len(files) != 1 || files[0].IsDir() {
+			t.Fatalf("Expected a single file in the outbox directory, got '%v'", files)
+		}
+		heads, err := file.Heads()
+		testinggo.AssertNoError(t, err)
+		if len(heads) != 1 {
+			t.Fatalf("Incorrect heads; expected '%d', got '%d'", 1, len(heads))
+		}
+		testinggo.AssertHashEqual(t, []byte("head"), heads[name])
+		testinggo.AssertNoError(t, file.Remove(name))
+		heads, err = file.Heads()
+		testinggo.AssertNoError(t, err)
+		if len(heads) != 0 {
+			t.Fatalf("Incorrect heads; expected '%d', got '%d'", 0, len(heads))
+		}
+	})
+	t.Run("Permissions", func(t *testing.T) {
+		dir := filepath.Join(dir, "private")
+		file, err := labgo.NewFileOutbox(dir)
+		testinggo.AssertNoError(t, err)
+		testinggo.AssertNoError(t, file.Put("Lab-Chat-foobar", []byte("head")))
+		for path, expected := range map[string]os.FileMode{
+			dir: 0700,
+			filepath.Join(dir, base64.RawURLEncoding.EncodeToString([]byte("Lab-Chat-foobar"))): 0600,
+		} {
+			info, err := os.Stat(path)
+			testinggo.AssertNoError(t, err)
+			if got := info.Mode().Perm(); got != expected {
+				t.Fatalf("Incorrect permissions of %s; expected '%v', got '%v'", path, expected, got)
+			}
+		}
+	})
+}
+
+func TestPushOutbox(t *testing.T) {
+	network := &peerNetwork{
+		offline: true,
+		heads:   make(map[string][]byte),
+	}
+	node := makeNode(t, "Alice")
+	node.Network = network
+	experiment, err := labgo.CreateFromReader(node, nil, nil, labgo.THRESHOLD_LAN, "foo/bar", ioutil.NopCloser(strings.NewReader("foobar")))
+	// Written locally despite peers being unreachable
+	testinggo.AssertNoError(t, err)
+	_, err = labgo.PostChat(node, nil, experiment.Chat, experiment.Access, "Hello")
+	testinggo.AssertNoError(t, err)
+	heads, err := labgo.GetOutbox(node).Heads()
+	testinggo.AssertNoError(t, err)
+	// Settings, Member, Path, File, and Chat channels
+	if len(heads) != 5 {
+		t.Fatalf("Incorrect heads; expected '%d', got '%d'", 5, len(heads))
+	}
+	testinggo.AssertHashEqual(t, experiment.Chat.Head, heads[experiment.Chat.Name])
+
+	pending, err := labgo.PushOutbox(node)
+	testinggo.AssertError(t, "Peers unreachable", err)
+	if pending != 5 {
+		t.Fatalf("Incorrect pending; expected '%d', got '%d'", 5, pending)
+	}
+
+	network.setOffline(false)
+	pending, err = labgo.PushOutbox(node)
+	testinggo.AssertNoError(t, err)
+	if pending != 0 {
+		t.Fatalf("Incorrect pending; expected '%d', got '%d'", 0, pending)
+	}
+	testinggo.AssertHashEqual(t, experiment.Chat.Head, network.head(experiment.Chat.Name))
+	testinggo.AssertHashEqual(t, experiment.Path.Head, network.head(experiment.Path.Name))
+	heads, err = labgo.GetOutbox(node).Heads()
+	testinggo.AssertNoError(t, err)
+	if len(heads) != 0 {
+		t.Fatalf("Incorrect heads; expected '%d', got '%d'", 0, len(heads))
+	}
+}
+
+func TestPushOutbox_Order(t *testing.T) {
+	peer := makeNode(t, "Bob")
+	network := &servedNetwork{
+		peer:    peer,
+		offline: true,
+	}
+	node := makeNode(t, "Alice")
+	node.Network = network
+	// Created while peers are unreachable
+	experiment, err := labgo.CreateFromReader(node, nil, nil, labgo.THRESHOLD_DEFAULT, "foo/bar", ioutil.NopCloser(strings.NewReader("foobar")))
+	testinggo.AssertNoError(t, err)
+	_, err = labgo.PostChat(node, nil, experiment.Chat, experiment.Access, "Hello")
+	testinggo.AssertNoError(t, err)
+
+	// Peer validates each channel against the channels pushed before it
+	network.setOffline(false)
+	pending, err := labgo.PushOutbox(node)
+	testinggo.AssertNoError(t, err)
+	if pending != 0 {
+		t.Fatalf("Incorrect pending; expected '%d', got '%d'", 0, pending)
+	}
+	for _, channel := range []*bcgo.Channel{
+		experiment.Settings,
+		experiment.Member,
+		experiment.Path,
+		experiment.Chat,
+	} {
+		reference, err := peer.Cache.GetHead(channel.Name)
+		testinggo.AssertNoError(t, err)
+		testinggo.AssertHashEqual(t, channel.Head, reference.BlockHash)
+	}
+}
+
+func TestRetryPush(t *testing.T) {
+	network := &peerNetwork{
+		offline: true,
+		heads:   make(map[string][]byte),
+	}
+	node := makeNode(t, "Alice")
+	node.Network = network
+	experiment, err := labgo.CreateFromReader(node, nil, nil, labgo.THRESHOLD_LAN, "", nil)
+	testinggo.AssertNoError(t, err)
+	chat := experiment.Chat
+	_, err = labgo.PostChat(node, nil, chat, nil, "Hello")
+	testinggo.AssertNoError(t, err)
+
+	t.Run("Cancelled", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+		err := labgo.RetryPush(ctx, node, time.Millisecond, 10*time.Millisecond)
+		testinggo.AssertError(t, context.DeadlineExceeded.Error(), err)
+		if network.head(chat.Name) != nil {
+			t.Fatalf("Expected channel not to be pushed")
+		}
+	})
+	t.Run("Reachable", func(t *testing.T) {
+		go func() {
+			time.Sleep(20 * time.Millisecond)
+			network.setOffline(false)
+		}()
+		testinggo.AssertNoError(t, labgo.RetryPush(context.Background(), node, time.Millisecond, 10*time.Millisecond))
+		testinggo.AssertHashEqual(t, chat.Head, network.head(chat.Name))
+	})
+}
@@ -0,0 +1,254 @@
+/*
+ * Copyright 2020 Aletheia Ware LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package labgo
+
+import (
+	"crypto/rsa"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"github.com/AletheiaWareLLC/bcgo"
+	"github.com/golang/protobuf/proto"
+	"math"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// RelativePath returns the segments of the given path relative to the given root.
+// If the root is the path itself, the result is the name of the file.
+func RelativePath(root, path string) ([]string, error) {
+	rel, err := filepath.Rel(root, path)
+	if err != nil {
+		return nil, err
+	}
+	if rel == "." {
+		rel = filepath.Base(path)
+	}
+	segments := strings.Split(filepath.ToSlash(rel), "/")
+	if err := ValidatePath(segments); err != nil {
+		return nil, err
+	}
+	return segments, nil
+}
+
+// URIToPath returns the segments of the path identified by the given URI.
+// Relative paths are preserved, while absolute paths are reduced to the name of the file.
+func URIToPath(uri string) []string {
+	path := uri
+	if u, err := url.Parse(uri); err == nil && u.Path != "" {
+		path = u.Path
+	}
+	path = filepath.ToSlash(path)
+	var segments []string
+	for _, s := range strings.Split(path, "/") {
+		if s != "" && s != "." {
+			segments = append(segments, s)
+		}
+	}
+	if err := ValidatePath(segments); err != nil || strings.HasPrefix(path, "/") {
+		return []string{filepath.Base(filepath.FromSlash(path))}
+	}
+	return segments
+}
+
+const (
+	ERROR_FILE_NOT_FOUND = "File not found: %s"
+)
+
+// IteratePaths calls the callback with each Path record in the channel, oldest first, along with the ID of the file it describes.
+// A Path record which references an earlier Path record in the same channel either renames or deletes the referenced file, otherwise the record creates a new file identified by the record hash.
+func IteratePaths(node *Node, channel *bcgo.Channel, callback func(string, []byte, *bcgo.Record, *Path) error) error {
+	// Maps record hash to file ID
+	ids := make(map[string]string)
+	// Iterate through chain chronologically
+	return bcgo.IterateChronologically(channel.Name, channel.Head, nil, node.Cache, node.Network, func(hash []byte, block *bcgo.Block) error {
+		for _, entry := range block.Entry {
+			payload, err := readPayload(node, entry)
+			if err != nil {
+				return err
+			}
+			// Unmarshal as Path
+			p := &Path{}
+			if err := proto.Unmarshal(payload, p); err != nil {
+				return err
+			}
+			if err := callback(fileId(ids, channel.Name, entry), entry.RecordHash, entry.Record, p); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// fileId returns the ID of the file the path record in the entry applies to, and adds it to the IDs of the records seen so far, keyed by record hash.
+// A record referencing an earlier record applies to the same file, otherwise its hash is the ID of a new file, so the ID is known without reading the payload.
+func fileId(ids map[string]string, channel string, entry *bcgo.BlockEntry) string {
+	key := base64.RawURLEncoding.EncodeToString(entry.RecordHash)
+	id := key
+	for _, r := range entry.Record.Reference {
+		if r.ChannelName != "" && r.ChannelName != channel {
+			continue
+		}
+		if i, ok := ids[base64.RawURLEncoding.EncodeToString(r.RecordHash)]; ok {
+			id = i
+			break
+		}
+	}
+	ids[key] = id
+	return id
+}
+
+// ListFiles returns the current path of each file in the channel which has not been deleted, keyed by file ID.
+func ListFiles(node *Node, channel *bcgo.Channel) (map[string][]string, error) {
+	return ListFilesAt(node, channel, math.MaxUint64)
+}
+
+// ListFilesAt returns the path of each file in the channel as it was at the given timestamp, keyed by file ID.
+// Only records created at or before the timestamp are considered.
+func ListFilesAt(node *Node, channel *bcgo.Channel, at uint64) (map[string][]string, error) {
+	files := make(map[string][]string)
+	if err := IteratePaths(node, channel, func(id string, hash []byte, record *bcgo.Record, path *Path) error {
+		if record.Timestamp > at {
+			return nil
+		}
+		if path.Deleted {
+			delete(files, id)
+		} else {
+			files[id] = path.Path
+		}
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+	return files, nil
+}
+
+// GetFileId returns the ID of the file currently at the given path.
+func GetFileId(node *Node, channel *bcgo.Channel, path []string) (string, error) {
+	files, err := ListFiles(node, channel)
+	if err != nil {
+		return "", err
+	}
+	key := strings.Join(path, "/")
+	for id, p := range files {
+		if strings.Join(p, "/") == key {
+			return id, nil
+		}
+	}
+	return "", errors.New(fmt.Sprintf(ERROR_FILE_NOT_FOUND, key))
+}
+
+// RenamePath moves the file with the given ID to the given path.
+// The file keeps its ID, and so its content and history.
+func RenamePath(node *Node, listener bcgo.MiningListener, channel *bcgo.Channel, acl map[string]*rsa.PublicKey, fileId string, path []string) ([]byte, error) {
+	if err := ValidatePath(path); err != nil {
+		return nil, err
+	}
+	return writePathReference(node, listener, channel, acl, fileId, &Path{
+		Path: path,
+	})
+}
+
+// DeletePath removes the file with the given ID from the experiment.
+// The file's channel is kept so its history remains available.
+func DeletePath(node *Node, listener bcgo.MiningListener, channel *bcgo.Channel, acl map[string]*rsa.PublicKey, fileId string) ([]byte, error) {
+	return writePathReference(node, listener, channel, acl, fileId, &Path{
+		Deleted: true,
+	})
+}
+
+func writePathReference(node *Node, listener bcgo.MiningListener, channel *bcgo.Channel, acl map[string]*rsa.PublicKey, fileId string, path *Path) ([]byte, error) {
+	files, err := ListFiles(node, channel)
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := files[fileId]; !ok {
+		return nil, errors.New(fmt.Sprintf(ERROR_FILE_NOT_FOUND, fileId))
+	}
+	hash, err := base64.RawURLEncoding.DecodeString(fileId)
+	if err != nil {
+		return nil, err
+	}
+	return WriteProto(node, listener, channel, acl, []*bcgo.Reference{
+		&bcgo.Reference{
+			ChannelName: channel.Name,
+			RecordHash:  hash,
+		},
+	}, path)
+}
+
+// MigratePaths rewrites the path of each file whose path is absolute, or otherwise escapes the experiment, to be relative to the given root, and returns the number of files migrated.
+// Legacy relative paths were relative to the working directory, so the leading segments of the root relative to the working directory are stripped from them too.
+// Files keep their ID, and so their content and history.
+func MigratePaths(node *Node, listener bcgo.MiningListener, channel *bcgo.Channel, acl map[string]*rsa.PublicKey, root string) (int, error) {
+	files, err := ListFiles(node, channel)
+	if err != nil {
+		return 0, err
+	}
+	root, err = filepath.Abs(root)
+	if err != nil {
+		return 0, err
+	}
+	wd, err := os.Getwd()
+	if err != nil {
+		return 0, err
+	}
+	var prefix []string
+	if rel, err := filepath.Rel(wd, root); err == nil && ValidatePath(strings.Split(filepath.ToSlash(rel), "/")) == nil {
+		prefix = strings.Split(filepath.ToSlash(rel), "/")
+	}
+	var ids []string
+	for id, path := range files {
+		if ValidatePath(path) != nil || hasPrefix(path, prefix) {
+			ids = append(ids, id)
+		}
+	}
+	sort.Strings(ids)
+	count := 0
+	for _, id := range ids {
+		// Legacy paths were split on the platform separator, and relative paths were relative to the working directory
+		p, err := filepath.Abs(strings.Join(files[id], string(os.PathSeparator)))
+		if err != nil {
+			return count, err
+		}
+		segments, err := RelativePath(root, p)
+		if err != nil {
+			return count, err
+		}
+		if _, err := RenamePath(node, listener, channel, acl, id, segments); err != nil {
+			return count, err
+		}
+		count++
+	}
+	return count, nil
+}
+
+// hasPrefix returns true if the path is within the directory with the given prefix.
+func hasPrefix(path, prefix []string) bool {
+	if len(prefix) == 0 || len(path) <= len(prefix) {
+		return false
+	}
+	for i, s := range prefix {
+		if path[i] != s {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,245 @@
+/*
+ * Copyright 2020 Aletheia Ware LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package labgo_test
+
+import (
+	"encoding/base64"
+	"fmt"
+	"github.com/AletheiaWareLLC/bcgo"
+	"github.com/AletheiaWareLLC/labgo"
+	"github.com/AletheiaWareLLC/testinggo"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestRelativePath(t *testing.T) {
+	root := filepath.Join("home", "alice", "foobar")
+	tests := []struct {
+		name string
+		path string
+		want []string
+		err  bool
+	}{
+		{
+			name: "File",
+			path: filepath.Join(root, "foo.txt"),
+			want: []string{"foo.txt"},
+		},
+		{
+			name: "Nested",
+			path: filepath.Join(root, "foo", "bar.txt"),
+			want: []string{"foo", "bar.txt"},
+		},
+		{
+			name: "Root",
+			path: root,
+			want: []string{"foobar"},
+		},
+		{
+			name: "Outside",
+			path: filepath.Join("home", "alice", "foo.txt"),
+			err:  true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := labgo.RelativePath(root, tt.path)
+			if tt.err {
+				if err == nil {
+					t.Fatalf("Expected error")
+				}
+				return
+			}
+			testinggo.AssertNoError(t, err)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("Incorrect path; expected '%v', got '%v'", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestURIToPath(t *testing.T) {
+	tests := []struct {
+		uri  string
+		want []string
+	}{
+		{"foo.txt", []string{"foo.txt"}},
+		{"foo/bar.txt", []string{"foo", "bar.txt"}},
+		{"./foo/bar.txt", []string{"foo", "bar.txt"}},
+		{"../foo/bar.txt", []string{"bar.txt"}},
+		{"/Users/alice/bar.txt", []string{"bar.txt"}},
+		{"file:///Users/alice/bar.txt", []string{"bar.txt"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.uri, func(t *testing.T) {
+			if got := labgo.URIToPath(tt.uri); !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("Incorrect path; expected '%v', got '%v'", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestListFiles(t *testing.T) {
+	node := makeNode(t, "Alice")
+	channel := labgo.OpenPathChannel(node, "foobar")
+	node.AddChannel(channel)
+	foo, _, err := labgo.CreatePath(node, nil, channel, nil, []string{"foo.txt"})
+	testinggo.AssertNoError(t, err)
+	bar, _, err := labgo.CreatePath(node, nil, channel, nil, []string{"bar.txt"})
+	testinggo.AssertNoError(t, err)
+	hash, err := base64.RawURLEncoding.DecodeString(foo)
+	testinggo.AssertNoError(t, err)
+	// Move foo.txt
+	_, err = labgo.WriteProto(node, nil, channel, nil, []*bcgo.Reference{
+		&bcgo.Reference{
+			ChannelName: channel.Name,
+			RecordHash:  hash,
+		},
+	}, &labgo.Path{
+		Path: []string{"baz", "foo.txt"},
+	})
+	testinggo.AssertNoError(t, err)
+	files, err := labgo.ListFiles(node, channel)
+	testinggo.AssertNoError(t, err)
+	want := map[string][]string{
+		foo: []string{"baz", "foo.txt"},
+		bar: []string{"bar.txt"},
+	}
+	if !reflect.DeepEqual(files, want) {
+		t.Fatalf("Incorrect files; expected '%v', got '%v'", want, files)
+	}
+}
+
+func TestMigratePaths(t *testing.T) {
+	dir, err := ioutil.TempDir("", "lab")
+	testinggo.AssertNoError(t, err)
+	defer os.RemoveAll(dir)
+	node := makeNode(t, "Alice")
+	// Write legacy absolute path without validation
+	legacy := bcgo.OpenPoWChannel(labgo.LAB_PREFIX_PATH+"foobar", labgo.THRESHOLD_DEFAULT)
+	_, err = labgo.WriteProto(node, nil, legacy, nil, nil, &labgo.Path{
+		Path: strings.Split(filepath.Join(dir, "foo", "bar.txt"), string(os.PathSeparator)),
+	})
+	testinggo.AssertNoError(t, err)
+	channel := labgo.OpenPathChannel(node, "foobar")
+	testinggo.AssertNoError(t, channel.LoadCachedHead(node.Cache))
+	count, err := labgo.MigratePaths(node, nil, channel, nil, dir)
+	testinggo.AssertNoError(t, err)
+	if count != 1 {
+		t.Fatalf("Incorrect count; expected '%d', got '%d'", 1, count)
+	}
+	files, err := labgo.ListFiles(node, channel)
+	testinggo.AssertNoError(t, err)
+	if len(files) != 1 {
+		t.Fatalf("Incorrect files; expected '%d', got '%d'", 1, len(files))
+	}
+	for _, p := range files {
+		if want := []string{"foo", "bar.txt"}; !reflect.DeepEqual(p, want) {
+			t.Fatalf("Incorrect path; expected '%v', got '%v'", want, p)
+		}
+	}
+	// Migrating again has no effect
+	count, err = labgo.MigratePaths(node, nil, channel, nil, dir)
+	testinggo.AssertNoError(t, err)
+	if count != 0 {
+		t.Fatalf("Incorrect count; expected '%d', got '%d'", 0, count)
+	}
+}
+
+func TestMigratePaths_Relative(t *testing.T) {
+	dir, err := ioutil.TempDir("", "lab")
+	testinggo.AssertNoError(t, err)
+	defer os.RemoveAll(dir)
+	wd, err := os.Getwd()
+	testinggo.AssertNoError(t, err)
+	defer os.Chdir(wd)
+	testinggo.AssertNoError(t, os.Chdir(dir))
+	node := makeNode(t, "Alice")
+	// Experiment created with a relative root recorded paths relative to the working directory
+	channel := labgo.OpenPathChannel(node, "foobar")
+	_, err = labgo.WriteProto(node, nil, channel, nil, nil, &labgo.Path{
+		Path: []string{"foo", "bar", "baz.txt"},
+	})
+	testinggo.AssertNoError(t, err)
+	count, err := labgo.MigratePaths(node, nil, channel, nil, "foo")
+	testinggo.AssertNoError(t, err)
+	if count != 1 {
+		t.Fatalf("Incorrect count; expected '%d', got '%d'", 1, count)
+	}
+	files, err := labgo.ListFiles(node, channel)
+	testinggo.AssertNoError(t, err)
+	for _, p := range files {
+		if want := []string{"bar", "baz.txt"}; !reflect.DeepEqual(p, want) {
+			t.Fatalf("Incorrect path; expected '%v', got '%v'", want, p)
+		}
+	}
+	// Migrating again has no effect
+	count, err = labgo.MigratePaths(node, nil, channel, nil, "foo")
+	testinggo.AssertNoError(t, err)
+	if count != 0 {
+		t.Fatalf("Incorrect count; expected '%d', got '%d'", 0, count)
+	}
+}
+
+func TestRenamePath(t *testing.T) {
+	node := makeNode(t, "Alice")
+	channel := labgo.OpenPathChannel(node, "foobar")
+	node.AddChannel(channel)
+	id, _, err := labgo.CreatePath(node, nil, channel, nil, []string{"foo.txt"})
+	testinggo.AssertNoError(t, err)
+	_, err = labgo.RenamePath(node, nil, channel, nil, id, []string{"bar", "foo.txt"})
+	testinggo.AssertNoError(t, err)
+	got, err := labgo.GetFileId(node, channel, []string{"bar", "foo.txt"})
+	testinggo.AssertNoError(t, err)
+	if got != id {
+		t.Fatalf("Incorrect file; expected '%s', got '%s'", id, got)
+	}
+	_, err = labgo.GetFileId(node, channel, []string{"foo.txt"})
+	testinggo.AssertError(t, fmt.Sprintf(labgo.ERROR_FILE_NOT_FOUND, "foo.txt"), err)
+	_, err = labgo.RenamePath(node, nil, channel, nil, id, []string{"..", "foo.txt"})
+	testinggo.AssertError(t, fmt.Sprintf(labgo.ERROR_PATH_SEGMENT_INVALID, ".."), err)
+}
+
+func TestDeletePath(t *testing.T) {
+	node := makeNode(t, "Alice")
+	channel := labgo.OpenPathChannel(node, "foobar")
+	node.AddChannel(channel)
+	id, _, err := labgo.CreatePath(node, nil, channel, nil, []string{"foo.txt"})
+	testinggo.AssertNoError(t, err)
+	_, err = labgo.DeletePath(node, nil, channel, nil, id)
+	testinggo.AssertNoError(t, err)
+	files, err := labgo.ListFiles(node, channel)
+	testinggo.AssertNoError(t, err)
+	if len(files) != 0 {
+		t.Fatalf("Incorrect files; expected none, got '%v'", files)
+	}
+	// Deleted files cannot be deleted again
+	_, err = labgo.DeletePath(node, nil, channel, nil, id)
+	testinggo.AssertError(t, fmt.Sprintf(labgo.ERROR_FILE_NOT_FOUND, id), err)
+	// Path can be reused by a new file
+	other, _, err := labgo.CreatePath(node, nil, channel, nil, []string{"foo.txt"})
+	testinggo.AssertNoError(t, err)
+	got, err := labgo.GetFileId(node, channel, []string{"foo.txt"})
+	testinggo.AssertNoError(t, err)
+	if got != other {
+		t.Fatalf("Incorrect file; expected '%s', got '%s'", other, got)
+	}
+}
@@ -0,0 +1,157 @@
+/*
+ * Copyright 2020 Aletheia Ware LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package labgo
+
+import (
+	"io"
+	"math/rand"
+)
+
+// PieceTable reconstructs a file from a sequence of deltas without copying the bytes they add.
+// The content is held as an ordered tree of pieces, each a slice of the bytes added by a delta, so applying a delta only splits and joins pieces in time logarithmic in their number.
+type PieceTable struct {
+	root *piece
+}
+
+// piece is a node in a treap of pieces ordered by offset, and balanced by their random priorities.
+type piece struct {
+	bytes       []byte
+	priority    uint32
+	length      uint64 // Length of the pieces in the subtree
+	left, right *piece
+}
+
+func newPiece(bytes []byte) *piece {
+	return &piece{
+		bytes:    bytes,
+		priority: rand.Uint32(),
+		length:   uint64(len(bytes)),
+	}
+}
+
+func (p *piece) len() uint64 {
+	if p == nil {
+		return 0
+	}
+	return p.length
+}
+
+func (p *piece) update() *piece {
+	p.length = p.left.len() + uint64(len(p.bytes)) + p.right.len()
+	return p
+}
+
+// Len returns the length of the content.
+func (t *PieceTable) Len() uint64 {
+	return t.root.len()
+}
+
+// Apply removes the bytes removed by the delta and inserts the bytes it adds.
+// Offsets beyond the end of the content are treated as the end, and snapshots, which do not change the file, are ignored.
+func (t *PieceTable) Apply(delta *Delta) {
+	if delta.Snapshot != nil {
+		return
+	}
+	length := t.Len()
+	start := minUint64(delta.Offset, length)
+	end := minUint64(start+uint64(len(delta.Remove)), length)
+	before, rest := splitPieces(t.root, start)
+	_, after := splitPieces(rest, end-start)
+	if len(delta.Add) > 0 {
+		before = joinPieces(before, newPiece(delta.Add))
+	}
+	t.root = joinPieces(before, after)
+}
+
+// splitPieces divides the pieces into those before the given offset and those after it, splitting the piece containing the offset in two.
+func splitPieces(p *piece, offset uint64) (*piece, *piece) {
+	if p == nil {
+		return nil, nil
+	}
+	left := p.left.len()
+	length := uint64(len(p.bytes))
+	switch {
+	case offset <= left:
+		l, r := splitPieces(p.left, offset)
+		p.left = r
+		return l, p.update()
+	case offset >= left+length:
+		l, r := splitPieces(p.right, offset-left-length)
+		p.right = l
+		return p.update(), r
+	}
+	n := offset - left
+	right := joinPieces(newPiece(p.bytes[n:]), p.right)
+	p.bytes = p.bytes[:n:n]
+	p.right = nil
+	return p.update(), right
+}
+
+// joinPieces returns the pieces of the first tree followed by those of the second.
+func joinPieces(a, b *piece) *piece {
+	switch {
+	case a == nil:
+		return b
+	case b == nil:
+		return a
+	case a.priority > b.priority:
+		a.right = joinPieces(a.right, b)
+		return a.update()
+	default:
+		b.left = joinPieces(a, b.left)
+		return b.update()
+	}
+}
+
+// each calls the callback with the bytes of each piece in order.
+func (t *PieceTable) each(callback func([]byte) error) error {
+	var stack []*piece
+	p := t.root
+	for p != nil || len(stack) > 0 {
+		for ; p != nil; p = p.left {
+			stack = append(stack, p)
+		}
+		p = stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		if err := callback(p.bytes); err != nil {
+			return err
+		}
+		p = p.right
+	}
+	return nil
+}
+
+// WriteTo writes the content to the writer in a single pass.
+func (t *PieceTable) WriteTo(writer io.Writer) (int64, error) {
+	var count int64
+	err := t.each(func(p []byte) error {
+		n, err := writer.Write(p)
+		count += int64(n)
+		return err
+	})
+	return count, err
+}
+
+// Bytes returns a copy of the content.
+func (t *PieceTable) Bytes() []byte {
+	buffer := make([]byte, 0, t.Len())
+	t.each(func(p []byte) error {
+		buffer = append(buffer, p...)
+		return nil
+	})
+	return buffer
+}
@@ -0,0 +1,82 @@
+/*
+ * Copyright 2020 Aletheia Ware LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package labgo_test
+
+import (
+	"bytes"
+	"github.com/AletheiaWareLLC/labgo"
+	"github.com/AletheiaWareLLC/testinggo"
+	"math/rand"
+	"testing"
+)
+
+func TestPieceTable(t *testing.T) {
+	table := &labgo.PieceTable{}
+	for _, d := range []*labgo.Delta{
+		&labgo.Delta{
+			Add: []byte("foobar"),
+		},
+		&labgo.Delta{
+			Offset: 3,
+			Remove: []byte("bar"),
+			Add:    []byte("blah"),
+		},
+		&labgo.Delta{
+			Offset: 1,
+			Remove: []byte("oob"),
+		},
+		&labgo.Delta{
+			Add: []byte(">"),
+		},
+	} {
+		table.Apply(d)
+	}
+	if got := string(table.Bytes()); got != ">flah" {
+		t.Fatalf("Incorrect content; expected '%s', got '%s'", ">flah", got)
+	}
+	if table.Len() != 5 {
+		t.Fatalf("Incorrect length; expected '%d', got '%d'", 5, table.Len())
+	}
+	var buffer bytes.Buffer
+	count, err := table.WriteTo(&buffer)
+	testinggo.AssertNoError(t, err)
+	if count != 5 || buffer.String() != ">flah" {
+		t.Fatalf("Incorrect write; expected '%s', got '%s'", ">flah", buffer.String())
+	}
+}
+
+func TestPieceTable_Random(t *testing.T) {
+	random := rand.New(rand.NewSource(1))
+	table := &labgo.PieceTable{}
+	var expected []byte
+	for i := 0; i < 1000; i++ {
+		offset := random.Intn(len(expected) + 1)
+		remove := random.Intn(len(expected) - offset + 1)
+		add := make([]byte, random.Intn(8))
+		random.Read(add)
+		d := &labgo.Delta{
+			Offset: uint64(offset),
+			Remove: expected[offset : offset+remove],
+			Add:    add,
+		}
+		expected = labgo.DeltaToBuffer(d, expected)
+		table.Apply(d)
+		if got := table.Bytes(); !bytes.Equal(got, expected) {
+			t.Fatalf("Incorrect content after %d deltas; expected '%x', got '%x'", i+1, expected, got)
+		}
+	}
+}
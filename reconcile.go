@@ -0,0 +1,378 @@
+/*
+ * Copyright 2020 Aletheia Ware LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package labgo
+
+import (
+	"bytes"
+	"encoding/base64"
+	"github.com/AletheiaWareLLC/bcgo"
+	"github.com/golang/protobuf/proto"
+	"strings"
+)
+
+// Reconcile ensures the records of the given local head are not lost when the channel, or the chain held by peers, has a competing head.
+// The winning chain, the longest or if equal in length the one whose head has the lowest hash, becomes the channel's chain, and the records created by the node which only the local head holds are rebased onto it and mined into new blocks.
+// Deltas written to file channels have their offsets transformed past the deltas written concurrently, records of other channels are mined again unchanged.
+// Returns the number of records rebased, the channel is not pushed to peers.
+func Reconcile(node *Node, listener bcgo.MiningListener, channel *bcgo.Channel, local []byte) (int, error) {
+	// Pending records were written against the local head
+	if err := flushBatch(node); err != nil {
+		return 0, err
+	}
+	return reconcile(node, listener, channel, local)
+}
+
+func reconcile(node *Node, listener bcgo.MiningListener, channel *bcgo.Channel, local []byte) (int, error) {
+	entries, err := rebase(node, listener, channel, local)
+	if err != nil {
+		return 0, err
+	}
+	if err := mineEntries(node, listener, channel, entries, nil); err != nil {
+		return 0, err
+	}
+	return len(entries), nil
+}
+
+// rebase updates the channel to the winning chain, and returns the records to mine onto it for the records only the local head holds.
+// The node's update lock is held, so the channel is not updated by a broadcast at the same time, but not while the records are mined or pushed.
+func rebase(node *Node, listener bcgo.MiningListener, channel *bcgo.Channel, local []byte) ([]*bcgo.BlockEntry, error) {
+	if local == nil {
+		return nil, nil
+	}
+	node.update.Lock()
+	defer node.update.Unlock()
+	other := channel.Head
+	if node.Network != nil {
+		if reference, err := node.Network.GetHead(channel.Name); err == nil && !bytes.Equal(reference.BlockHash, other) {
+			remote := reference.BlockHash
+			// A peer's chain competes with the local head if it is at least as long, or with another head if it is longer
+			if isLonger(node, channel.Name, remote, other) || (bytes.Equal(other, local) && !isLonger(node, channel.Name, other, remote)) {
+				other = remote
+			}
+		}
+	}
+	if other == nil || bytes.Equal(other, local) {
+		return nil, setHead(node, channel, local)
+	}
+
+	// Find the blocks of each chain since their common ancestor
+	ancestors := make(map[string]bool)
+	if err := bcgo.Iterate(channel.Name, local, nil, node.Cache, node.Network, func(hash []byte, block *bcgo.Block) error {
+		ancestors[base64.RawURLEncoding.EncodeToString(hash)] = true
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+	var ancestor []byte
+	theirs, err := blocksSince(node, channel.Name, other, func(hash []byte) bool {
+		if ancestors[base64.RawURLEncoding.EncodeToString(hash)] {
+			ancestor = hash
+			return true
+		}
+		return false
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(theirs) == 0 {
+		// Local head extends the other chain
+		return nil, setHead(node, channel, local)
+	}
+	ours, err := blocksSince(node, channel.Name, local, func(hash []byte) bool {
+		return bytes.Equal(hash, ancestor)
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(ours) == 0 {
+		// Other chain extends the local head
+		return nil, setHead(node, channel, other)
+	}
+	if l, o := ours[len(ours)-1].Length, theirs[len(theirs)-1].Length; l > o || (l == o && bytes.Compare(local, other) < 0) {
+		// Local head wins, the creators of the other records rebase them
+		return nil, setHead(node, channel, local)
+	}
+
+	// Collect the records which only the local chain holds, including those other writers mined onto it, as the other chain drops them all
+	competing := make(map[string]bool)
+	for _, b := range theirs {
+		for _, e := range b.Entry {
+			competing[base64.RawURLEncoding.EncodeToString(e.RecordHash)] = true
+		}
+	}
+	var entries []*bcgo.BlockEntry
+	for _, b := range ours {
+		for _, e := range b.Entry {
+			if !competing[base64.RawURLEncoding.EncodeToString(e.RecordHash)] {
+				entries = append(entries, e)
+			}
+		}
+	}
+	if strings.HasPrefix(channel.Name, LAB_PREFIX_FILE) {
+		entries, err = rebaseDeltas(node, listener, channel, entries, theirs)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		// Records do not depend on the chain, so are mined again in new blocks
+		for i, e := range entries {
+			entries[i] = &bcgo.BlockEntry{
+				RecordHash: e.RecordHash,
+				Record:     e.Record,
+			}
+		}
+	}
+
+	if err := setHead(node, channel, other); err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+// rebaseDeltas creates new records of the deltas in the entries, with offsets transformed past the deltas in the competing blocks.
+// Records whose delta is unchanged by the rebase are mined again as they are, so they keep their creator, the others are created again by the node.
+func rebaseDeltas(node *Node, listener bcgo.MiningListener, channel *bcgo.Channel, entries []*bcgo.BlockEntry, competing []*bcgo.Block) ([]*bcgo.BlockEntry, error) {
+	threshold, err := ChannelThreshold(node.Cache, node.Network, channel)
+	if err != nil {
+		return nil, err
+	}
+	var concurrent []*Delta
+	for _, b := range competing {
+		for _, e := range b.Entry {
+			d, err := unmarshalDelta(node, e)
+			if err != nil {
+				return nil, err
+			}
+			if err := resolveChunks(node, threshold, d); err != nil {
+				return nil, err
+			}
+			concurrent = append(concurrent, d)
+		}
+	}
+	var local []*Delta
+	for _, e := range entries {
+		d, err := unmarshalDelta(node, e)
+		if err != nil {
+			return nil, err
+		}
+		if err := resolveChunks(node, threshold, d); err != nil {
+			return nil, err
+		}
+		local = append(local, d)
+	}
+	var results []*bcgo.BlockEntry
+	for i, d := range RebaseDeltas(local, concurrent) {
+		if d == nil {
+			continue
+		}
+		if l := local[i]; d.Offset == l.Offset && bytes.Equal(d.Remove, l.Remove) && bytes.Equal(d.Add, l.Add) {
+			results = append(results, &bcgo.BlockEntry{
+				RecordHash: entries[i].RecordHash,
+				Record:     entries[i].Record,
+			})
+			continue
+		}
+		// Encrypt for the same aliases as the original record
+		var aliases []string
+		for _, a := range entries[i].Record.Access {
+			aliases = append(aliases, a.Alias)
+		}
+		acl, err := getAccess(node, aliases)
+		if err != nil {
+			return nil, err
+		}
+		// Deltas merged with overlapping deltas may have grown, so are split and chunked again to stay within the payload limit
+		if err := splitDelta(d.Offset, d.Remove, d.Add, MAX_DELTA_LENGTH, func(delta *Delta) error {
+			// Chunks are mined immediately, as the batch may be the one being mined
+			if err := encodeDelta(node, listener, threshold, acl, delta, false); err != nil {
+				return err
+			}
+			payload, err := proto.Marshal(delta)
+			if err != nil {
+				return err
+			}
+			entry, err := createEntry(node, channel, acl, nil, payload)
+			if err != nil {
+				return err
+			}
+			results = append(results, entry)
+			return nil
+		}); err != nil {
+			return nil, err
+		}
+	}
+	return results, nil
+}
+
+// RebaseDeltas transforms the offsets of the local deltas, each relative to the result of the deltas before it, so they apply after the concurrent deltas written against the same content.
+// The result holds the rebased delta for each local delta, or nil for snapshots and deltas which no longer change the content.
+// Where a local delta overlaps a concurrent one, the bytes removed by either are removed and the bytes added by both are kept.
+func RebaseDeltas(local, concurrent []*Delta) []*Delta {
+	var remote []*Delta
+	for _, d := range concurrent {
+		// Snapshots do not change the content
+		if d.Snapshot == nil {
+			remote = append(remote, d)
+		}
+	}
+	results := make([]*Delta, len(local))
+	for i, l := range local {
+		if l.Snapshot != nil {
+			// Snapshots describe content which no longer exists
+			continue
+		}
+		for j, r := range remote {
+			l, remote[j] = transformDelta(l, r)
+		}
+		if len(l.Remove) > 0 || len(l.Add) > 0 {
+			results[i] = l
+		}
+	}
+	return results
+}
+
+// transformDelta takes two deltas applied to the same content, and returns the first transformed to apply after the second, and the second transformed to apply after the first.
+// Concurrent insertions at the same offset place the second delta's bytes first.
+func transformDelta(l, r *Delta) (*Delta, *Delta) {
+	lo, lr := l.Offset, uint64(len(l.Remove))
+	ro, rr := r.Offset, uint64(len(r.Remove))
+	switch {
+	case lo >= ro+rr:
+		// First follows second
+		return shiftDelta(l, uint64(len(r.Add)), rr), r
+	case lo+lr <= ro:
+		// First precedes second
+		return l, shiftDelta(r, uint64(len(l.Add)), lr)
+	}
+	// Removals overlap, replace the union of the bytes removed with the bytes added by both
+	start := lo
+	if ro < start {
+		start = ro
+	}
+	var lpre, lpost, rpre, rpost []byte
+	if lo < ro {
+		lpre = l.Remove[:ro-lo]
+	} else {
+		rpre = r.Remove[:lo-ro]
+	}
+	if lo+lr > ro+rr {
+		lpost = l.Remove[ro+rr-lo:]
+	} else {
+		rpost = r.Remove[lo+lr-ro:]
+	}
+	var add []byte
+	if lo < ro {
+		add = concat(l.Add, r.Add)
+	} else {
+		add = concat(r.Add, l.Add)
+	}
+	return replaceDelta(start, concat(lpre, r.Add, lpost), add), replaceDelta(start, concat(rpre, l.Add, rpost), add)
+}
+
+// shiftDelta returns a copy of the delta moved to follow a change which added and removed the given number of bytes before it.
+func shiftDelta(delta *Delta, added, removed uint64) *Delta {
+	return &Delta{
+		Offset: delta.Offset + added - removed,
+		Remove: delta.Remove,
+		Add:    delta.Add,
+	}
+}
+
+// replaceDelta returns a delta replacing the bytes removed at the offset with those added, omitting any prefix or suffix they share.
+func replaceDelta(offset uint64, remove, add []byte) *Delta {
+	prefix := 0
+	for prefix < len(remove) && prefix < len(add) && remove[prefix] == add[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(remove)-prefix && suffix < len(add)-prefix && remove[len(remove)-1-suffix] == add[len(add)-1-suffix] {
+		suffix++
+	}
+	return &Delta{
+		Offset: offset + uint64(prefix),
+		Remove: remove[prefix : len(remove)-suffix],
+		Add:    add[prefix : len(add)-suffix],
+	}
+}
+
+func concat(buffers ...[]byte) []byte {
+	var result []byte
+	for _, b := range buffers {
+		result = append(result, b...)
+	}
+	return result
+}
+
+// blocksSince returns the blocks of the chain with the given head, oldest first, which follow the first block satisfying the predicate.
+func blocksSince(node *Node, channel string, head []byte, predicate func([]byte) bool) ([]*bcgo.Block, error) {
+	var blocks []*bcgo.Block
+	if err := bcgo.Iterate(channel, head, nil, node.Cache, node.Network, func(hash []byte, block *bcgo.Block) error {
+		if predicate(hash) {
+			return bcgo.StopIterationError{}
+		}
+		blocks = append([]*bcgo.Block{block}, blocks...)
+		return nil
+	}); err != nil {
+		switch err.(type) {
+		case bcgo.StopIterationError:
+			// Do nothing
+			break
+		default:
+			return nil, err
+		}
+	}
+	return blocks, nil
+}
+
+// isLonger returns true if the chain with the given head is longer than the chain with the other head.
+func isLonger(node *Node, channel string, head, other []byte) bool {
+	if head == nil {
+		return false
+	}
+	block, err := bcgo.GetBlock(channel, node.Cache, node.Network, head)
+	if err != nil {
+		return false
+	}
+	if other == nil {
+		return true
+	}
+	o, err := bcgo.GetBlock(channel, node.Cache, node.Network, other)
+	if err != nil {
+		return true
+	}
+	return block.Length > o.Length
+}
+
+// setHead updates the channel to the chain with the given head, even if it is no longer than the channel's current chain.
+// The caller holds the node's update lock.
+func setHead(node *Node, channel *bcgo.Channel, hash []byte) error {
+	if bytes.Equal(channel.Head, hash) {
+		return nil
+	}
+	block, err := bcgo.GetBlock(channel.Name, node.Cache, node.Network, hash)
+	if err != nil {
+		return err
+	}
+	// Channels only replace their head with a longer chain
+	head, timestamp := channel.Head, channel.Timestamp
+	channel.Head, channel.Timestamp = nil, 0
+	if err := channel.Update(node.Cache, node.Network, hash, block); err != nil {
+		channel.Head, channel.Timestamp = head, timestamp
+		return err
+	}
+	return nil
+}
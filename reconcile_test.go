@@ -0,0 +1,373 @@
+/*
+ * Copyright 2020 Aletheia Ware LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package labgo_test
+
+import (
+	"errors"
+	"github.com/AletheiaWareLLC/bcgo"
+	"github.com/AletheiaWareLLC/labgo"
+	"github.com/AletheiaWareLLC/testinggo"
+	"github.com/golang/protobuf/proto"
+	"testing"
+)
+
+// cacheNetwork serves the heads and blocks in a peer's cache.
+type cacheNetwork struct {
+	cache bcgo.Cache
+}
+
+func (n *cacheNetwork) GetHead(channel string) (*bcgo.Reference, error) {
+	return n.cache.GetHead(channel)
+}
+
+func (n *cacheNetwork) GetBlock(reference *bcgo.Reference) (*bcgo.Block, error) {
+	return n.cache.GetBlock(reference.BlockHash)
+}
+
+func (n *cacheNetwork) Broadcast(channel *bcgo.Channel, cache bcgo.Cache, hash []byte, block *bcgo.Block) error {
+	return nil
+}
+
+// forkNetwork serves the heads and blocks in a peer's cache, and applies broadcasts to it as a bcgo.TCPNetwork peer does.
+// A longer chain replaces the peer's head, a shorter one is rejected as out of date, and one of the same length is ignored without error.
+type forkNetwork struct {
+	cacheNetwork
+}
+
+func (n *forkNetwork) Broadcast(channel *bcgo.Channel, cache bcgo.Cache, hash []byte, block *bcgo.Block) error {
+	if reference, err := n.cache.GetHead(channel.Name); err == nil {
+		remote, err := n.cache.GetBlock(reference.BlockHash)
+		if err != nil {
+			return err
+		}
+		if remote.Length > block.Length {
+			return errors.New(bcgo.ERROR_CHANNEL_OUT_OF_DATE)
+		}
+		if remote.Length == block.Length {
+			// Next chain to get a block mined on top wins
+			return nil
+		}
+	}
+	// Copy the blocks the peer is missing
+	h, b := hash, block
+	for h != nil {
+		if _, err := n.cache.GetBlock(h); err == nil {
+			break
+		}
+		if b == nil {
+			var err error
+			if b, err = cache.GetBlock(h); err != nil {
+				return err
+			}
+		}
+		if err := n.cache.PutBlock(h, b); err != nil {
+			return err
+		}
+		h, b = b.Previous, nil
+	}
+	return n.cache.PutHead(channel.Name, &bcgo.Reference{
+		Timestamp:   block.Timestamp,
+		ChannelName: channel.Name,
+		BlockHash:   hash,
+	})
+}
+
+func TestRebaseDeltas(t *testing.T) {
+	for name, tt := range map[string]struct {
+		original   string
+		local      []*labgo.Delta
+		concurrent []*labgo.Delta
+		expected   string
+	}{
+		"Disjoint": {
+			original: "Hello World",
+			local: []*labgo.Delta{
+				{Offset: 6, Add: []byte("Big ")},
+			},
+			concurrent: []*labgo.Delta{
+				{Offset: 0, Remove: []byte("Hello"), Add: []byte("Howdy")},
+				{Offset: 11, Add: []byte("!")},
+			},
+			expected: "Howdy Big World!",
+		},
+		"Adjacent": {
+			original: "Hello World",
+			local: []*labgo.Delta{
+				{Offset: 6, Add: []byte("Big ")},
+			},
+			concurrent: []*labgo.Delta{
+				{Offset: 6, Remove: []byte("World"), Add: []byte("Earth")},
+			},
+			expected: "Hello Big Earth",
+		},
+		"SameOffset": {
+			original: "Hello World",
+			local: []*labgo.Delta{
+				{Offset: 5, Add: []byte("A")},
+			},
+			concurrent: []*labgo.Delta{
+				{Offset: 5, Add: []byte("B")},
+			},
+			expected: "HelloBA World",
+		},
+		"Overlap": {
+			original: "Hello World",
+			local: []*labgo.Delta{
+				{Offset: 5, Remove: []byte(" World")},
+			},
+			concurrent: []*labgo.Delta{
+				{Offset: 6, Remove: []byte("World"), Add: []byte("Earth")},
+			},
+			expected: "HelloEarth",
+		},
+		"Contained": {
+			original: "abcdef",
+			local: []*labgo.Delta{
+				{Offset: 1, Remove: []byte("bcde"), Add: []byte("X")},
+			},
+			concurrent: []*labgo.Delta{
+				{Offset: 3, Add: []byte("Y")},
+			},
+			expected: "aXYf",
+		},
+		"Sequence": {
+			original: "abc",
+			local: []*labgo.Delta{
+				{Offset: 0, Add: []byte("1")},
+				{Offset: 4, Add: []byte("2")},
+			},
+			concurrent: []*labgo.Delta{
+				{Offset: 1, Remove: []byte("b")},
+			},
+			expected: "1ac2",
+		},
+		"Snapshot": {
+			original: "abc",
+			local: []*labgo.Delta{
+				{Offset: 0, Add: []byte("abc"), Snapshot: &labgo.Snapshot{Length: 3}},
+				{Offset: 3, Add: []byte("d")},
+			},
+			concurrent: []*labgo.Delta{
+				{Offset: 0, Add: []byte("abc"), Snapshot: &labgo.Snapshot{Length: 3}},
+				{Offset: 0, Remove: []byte("a")},
+			},
+			expected: "bcd",
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			buffer := []byte(tt.original)
+			for _, d := range tt.concurrent {
+				buffer = labgo.DeltaToBuffer(d, buffer)
+			}
+			for _, d := range labgo.RebaseDeltas(tt.local, tt.concurrent) {
+				if d != nil {
+					buffer = labgo.DeltaToBuffer(d, buffer)
+				}
+			}
+			if string(buffer) != tt.expected {
+				t.Fatalf("Incorrect content; expected '%s', got '%s'", tt.expected, string(buffer))
+			}
+		})
+	}
+}
+
+func TestReconcile(t *testing.T) {
+	write := func(t *testing.T, node *labgo.Node, channel *bcgo.Channel, deltas ...*labgo.Delta) {
+		t.Helper()
+		for _, d := range deltas {
+			_, err := labgo.WriteDelta(node, nil, channel, nil, d)
+			testinggo.AssertNoError(t, err)
+		}
+	}
+	fork := func(t *testing.T) (*labgo.Node, *bcgo.Channel, *labgo.Node, *bcgo.Channel) {
+		t.Helper()
+		alice := makeNode(t, "Alice")
+		bob := makeNode(t, "Bob")
+		a := labgo.OpenFileChannel(alice, "foobar", labgo.THRESHOLD_DEFAULT)
+		b := labgo.OpenFileChannel(bob, "foobar", labgo.THRESHOLD_DEFAULT)
+		write(t, alice, a, &labgo.Delta{Add: []byte("Hello World")})
+		// Bob receives the original content
+		block, err := alice.Cache.GetBlock(a.Head)
+		testinggo.AssertNoError(t, err)
+		testinggo.AssertNoError(t, b.Update(bob.Cache, nil, a.Head, block))
+		return alice, a, bob, b
+	}
+	t.Run("Rebased", func(t *testing.T) {
+		alice, a, bob, b := fork(t)
+		write(t, alice, a, &labgo.Delta{Offset: 6, Add: []byte("Big ")})
+		write(t, bob, b,
+			&labgo.Delta{Offset: 6, Remove: []byte("World"), Add: []byte("Earth")},
+			&labgo.Delta{Offset: 11, Add: []byte("!")},
+		)
+		alice.Network = &cacheNetwork{bob.Cache}
+		count, err := labgo.Reconcile(alice, nil, a, a.Head)
+		testinggo.AssertNoError(t, err)
+		if count != 1 {
+			t.Fatalf("Incorrect count; expected '%d', got '%d'", 1, count)
+		}
+		block, err := alice.Cache.GetBlock(a.Head)
+		testinggo.AssertNoError(t, err)
+		testinggo.AssertHashEqual(t, b.Head, block.Previous)
+		buffer, err := labgo.ChannelToBuffer(alice, a)
+		testinggo.AssertNoError(t, err)
+		if string(buffer) != "Hello Big Earth!" {
+			t.Fatalf("Incorrect content; expected '%s', got '%s'", "Hello Big Earth!", string(buffer))
+		}
+	})
+	t.Run("ThreeWriters", func(t *testing.T) {
+		alice, a, bob, b := fork(t)
+		// Carol writes onto Alice's chain
+		carol := makeNode(t, "Carol")
+		c := labgo.OpenFileChannel(carol, "foobar", labgo.THRESHOLD_DEFAULT)
+		block, err := alice.Cache.GetBlock(a.Head)
+		testinggo.AssertNoError(t, err)
+		testinggo.AssertNoError(t, c.Update(carol.Cache, nil, a.Head, block))
+		write(t, carol, c, &labgo.Delta{Offset: 0, Add: []byte("Oh, ")})
+		block, err = carol.Cache.GetBlock(c.Head)
+		testinggo.AssertNoError(t, err)
+		testinggo.AssertNoError(t, a.Update(alice.Cache, nil, c.Head, block))
+		write(t, alice, a, &labgo.Delta{Offset: 10, Add: []byte("Big ")})
+		// Bob's longer chain drops both Carol's and Alice's records
+		write(t, bob, b,
+			&labgo.Delta{Offset: 0, Remove: []byte("Hello"), Add: []byte("Hey")},
+			&labgo.Delta{Offset: 9, Add: []byte("!")},
+			&labgo.Delta{Offset: 10, Add: []byte("?")},
+		)
+		alice.Network = &cacheNetwork{bob.Cache}
+		count, err := labgo.Reconcile(alice, nil, a, a.Head)
+		testinggo.AssertNoError(t, err)
+		if count != 2 {
+			t.Fatalf("Incorrect count; expected '%d', got '%d'", 2, count)
+		}
+		// Carol's record is unchanged by the rebase, so keeps its creator
+		block, err = alice.Cache.GetBlock(a.Head)
+		testinggo.AssertNoError(t, err)
+		testinggo.AssertHashEqual(t, b.Head, block.Previous)
+		var creators []string
+		for _, entry := range block.Entry {
+			creators = append(creators, entry.Record.Creator)
+		}
+		if len(creators) != 2 || creators[0] != "Carol" || creators[1] != "Alice" {
+			t.Fatalf("Incorrect creators; expected '%v', got '%v'", []string{"Carol", "Alice"}, creators)
+		}
+		buffer, err := labgo.ChannelToBuffer(alice, a)
+		testinggo.AssertNoError(t, err)
+		if string(buffer) != "Oh, Hey Big World!?" {
+			t.Fatalf("Incorrect content; expected '%s', got '%s'", "Oh, Hey Big World!?", string(buffer))
+		}
+	})
+	t.Run("Chunked", func(t *testing.T) {
+		alice, a, bob, b := fork(t)
+		data := randomBytes(t, 4, 2*labgo.CHUNK_MIN_SIZE)
+		write(t, alice, a, &labgo.Delta{Offset: 6, Add: data})
+		write(t, bob, b,
+			&labgo.Delta{Offset: 0, Remove: []byte("Hello"), Add: []byte("Howdy")},
+			&labgo.Delta{Offset: 11, Add: []byte("!")},
+		)
+		alice.Network = &cacheNetwork{bob.Cache}
+		count, err := labgo.Reconcile(alice, nil, a, a.Head)
+		testinggo.AssertNoError(t, err)
+		if count != 1 {
+			t.Fatalf("Incorrect count; expected '%d', got '%d'", 1, count)
+		}
+		// Rebased bytes are written to chunks rather than kept in the record
+		block, err := alice.Cache.GetBlock(a.Head)
+		testinggo.AssertNoError(t, err)
+		for _, entry := range block.Entry {
+			d := &labgo.Delta{}
+			testinggo.AssertNoError(t, proto.Unmarshal(entry.Record.Payload, d))
+			if len(d.Add) > 0 || len(d.AddChunk) == 0 {
+				t.Fatalf("Expected rebased bytes to be chunked")
+			}
+		}
+		buffer, err := labgo.ChannelToBuffer(alice, a)
+		testinggo.AssertNoError(t, err)
+		expected := "Howdy " + string(data) + "World!"
+		if string(buffer) != expected {
+			t.Fatalf("Incorrect content; expected '%d' bytes, got '%d'", len(expected), len(buffer))
+		}
+	})
+	t.Run("LocalWins", func(t *testing.T) {
+		alice, a, bob, b := fork(t)
+		write(t, alice, a,
+			&labgo.Delta{Offset: 6, Add: []byte("Big ")},
+			&labgo.Delta{Offset: 15, Add: []byte("!")},
+		)
+		write(t, bob, b, &labgo.Delta{Offset: 0, Remove: []byte("Hello"), Add: []byte("Howdy")})
+		head := a.Head
+		alice.Network = &cacheNetwork{bob.Cache}
+		count, err := labgo.Reconcile(alice, nil, a, a.Head)
+		testinggo.AssertNoError(t, err)
+		if count != 0 {
+			t.Fatalf("Incorrect count; expected '%d', got '%d'", 0, count)
+		}
+		testinggo.AssertHashEqual(t, head, a.Head)
+
+		// Bob rebases onto Alice's chain
+		bob.Network = &cacheNetwork{alice.Cache}
+		count, err = labgo.Reconcile(bob, nil, b, b.Head)
+		testinggo.AssertNoError(t, err)
+		if count != 1 {
+			t.Fatalf("Incorrect count; expected '%d', got '%d'", 1, count)
+		}
+		buffer, err := labgo.ChannelToBuffer(bob, b)
+		testinggo.AssertNoError(t, err)
+		if string(buffer) != "Howdy Big World!" {
+			t.Fatalf("Incorrect content; expected '%s', got '%s'", "Howdy Big World!", string(buffer))
+		}
+	})
+	t.Run("PushedFork", func(t *testing.T) {
+		// Peers ignore a competing chain of the same length, so the fork is only found after pushing
+		for i := 0; ; i++ {
+			if i == 16 {
+				t.Fatalf("Expected a fork in which the pushed chain loses")
+			}
+			alice, a, bob, b := fork(t)
+			write(t, bob, b, &labgo.Delta{Offset: 0, Remove: []byte("Hello"), Add: []byte("Howdy")})
+			competing := b.Head
+			alice.Network = &forkNetwork{cacheNetwork{bob.Cache}}
+			write(t, alice, a, &labgo.Delta{Offset: 6, Add: []byte("Big ")})
+			block, err := alice.Cache.GetBlock(a.Head)
+			testinggo.AssertNoError(t, err)
+			if block.Length == 2 {
+				// Alice's chain won, so Bob rebases when he next pushes
+				continue
+			}
+			testinggo.AssertHashEqual(t, competing, block.Previous)
+			reference, err := bob.Cache.GetHead(b.Name)
+			testinggo.AssertNoError(t, err)
+			testinggo.AssertHashEqual(t, a.Head, reference.BlockHash)
+			buffer, err := labgo.ChannelToBuffer(alice, a)
+			testinggo.AssertNoError(t, err)
+			if string(buffer) != "Howdy Big World" {
+				t.Fatalf("Incorrect content; expected '%s', got '%s'", "Howdy Big World", string(buffer))
+			}
+			break
+		}
+	})
+	t.Run("FastForward", func(t *testing.T) {
+		alice, a, bob, b := fork(t)
+		write(t, bob, b, &labgo.Delta{Offset: 11, Add: []byte("!")})
+		alice.Network = &cacheNetwork{bob.Cache}
+		count, err := labgo.Reconcile(alice, nil, a, a.Head)
+		testinggo.AssertNoError(t, err)
+		if count != 0 {
+			t.Fatalf("Incorrect count; expected '%d', got '%d'", 0, count)
+		}
+		testinggo.AssertHashEqual(t, b.Head, a.Head)
+	})
+}
@@ -0,0 +1,226 @@
+/*
+ * Copyright 2020 Aletheia Ware LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package labgo
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"github.com/AletheiaWareLLC/bcgo"
+	"github.com/AletheiaWareLLC/cryptogo"
+	"github.com/golang/protobuf/proto"
+	"sync"
+)
+
+const (
+	// Proof-of-work threshold of experiments shared on a local network, where blocks need little protection from spam
+	THRESHOLD_LAN = bcgo.THRESHOLD_Z
+	// Proof-of-work threshold of experiments shared publicly
+	THRESHOLD_PUBLIC = bcgo.THRESHOLD_G
+	// Proof-of-work threshold of experiments created before their threshold was recorded, and of files whose experiment is unknown
+	THRESHOLD_DEFAULT = bcgo.THRESHOLD_H
+
+	ERROR_SETTINGS_CHANGED         = "Experiment settings cannot change: %s"
+	ERROR_SETTINGS_CREATOR_INVALID = "Settings must be created by the experiment's creator: %s"
+	ERROR_SETTINGS_CREATOR_UNKNOWN = "Settings must be the record from whose hash the experiment's ID is derived: %s"
+	ERROR_SETTINGS_LATE            = "Settings must be recorded before the experiment's other channels: %s"
+)
+
+// GetSettings reads the settings recorded when the experiment was created, or nil if none were recorded.
+func GetSettings(cache bcgo.Cache, network bcgo.Network, experimentId string) (*Settings, error) {
+	name := LAB_PREFIX_SETTINGS + experimentId
+	reference, err := cache.GetHead(name)
+	if err != nil {
+		// No settings
+		return nil, nil
+	}
+	block, err := bcgo.GetBlock(name, cache, network, reference.BlockHash)
+	if err != nil {
+		return nil, err
+	}
+	if len(block.Entry) == 0 {
+		return nil, nil
+	}
+	settings := &Settings{}
+	if err := unmarshalPayload(block.Entry[0], settings); err != nil {
+		return nil, err
+	}
+	return settings, nil
+}
+
+// GetThreshold returns the proof-of-work threshold of the experiment's channels, as recorded in its settings.
+func GetThreshold(cache bcgo.Cache, network bcgo.Network, experimentId string) (uint64, error) {
+	settings, err := GetSettings(cache, network, experimentId)
+	if err != nil {
+		return 0, err
+	}
+	if settings == nil {
+		return THRESHOLD_DEFAULT, nil
+	}
+	return settings.Threshold, nil
+}
+
+// ChannelThreshold returns the proof-of-work threshold the blocks of the channel must reach to pass its validators.
+func ChannelThreshold(cache bcgo.Cache, network bcgo.Network, channel *bcgo.Channel) (uint64, error) {
+	for _, v := range channel.Validators {
+		switch v := v.(type) {
+		case *ThresholdValidator:
+			return v.Threshold(cache, network)
+		case *bcgo.PoWValidator:
+			return v.Threshold, nil
+		}
+	}
+	return THRESHOLD_DEFAULT, nil
+}
+
+// writeSettings records the settings of a new experiment, mining them at the threshold they declare, and returns the experiment's settings channel.
+// The experiment's ID is derived from the hash of the settings record, so the record identifies the experiment's creator.
+func writeSettings(node *Node, listener bcgo.MiningListener, settings *Settings) (*bcgo.Channel, error) {
+	// Marshal Protobuf
+	payload, err := proto.Marshal(settings)
+	if err != nil {
+		return nil, err
+	}
+	// Create Record
+	_, record, err := bcgo.CreateRecord(bcgo.Timestamp(), node.Alias, node.Key, nil, nil, payload)
+	if err != nil {
+		return nil, err
+	}
+	hash, err := cryptogo.HashProtobuf(record)
+	if err != nil {
+		return nil, err
+	}
+	entry := &bcgo.BlockEntry{
+		RecordHash: hash,
+		Record:     record,
+	}
+	// The channel is not yet known to the node, so is mined without holding its update lock
+	channel := OpenSettingsChannel(settingsExperimentId(hash))
+	if _, _, err := node.MineEntries(channel, settings.Threshold, listener, []*bcgo.BlockEntry{entry}); err != nil {
+		return nil, err
+	}
+	if _, err := pushChannel(node, channel); err != nil {
+		return nil, err
+	}
+	return channel, nil
+}
+
+// settingsExperimentId returns the ID of the experiment whose settings record has the given hash.
+func settingsExperimentId(hash []byte) string {
+	if len(hash) > EXPERIMENT_HASH_LENGTH {
+		hash = hash[:EXPERIMENT_HASH_LENGTH]
+	}
+	return base64.RawURLEncoding.EncodeToString(hash)
+}
+
+// SettingsValidator ensures a settings channel holds a single Settings record, in a block which reaches the threshold it declares.
+// The settings must be created by the experiment's creator before any of the experiment's other channels, so they cannot be added to an experiment created before settings were recorded.
+// The experiment's ID must be derived from the hash of the settings record, so the settings are the experiment's first record and their creator is the experiment's creator, even before its first path is recorded.
+type SettingsValidator struct {
+	ExperimentId string
+	// Threshold the block must reach even if the settings declare a lower one, for experiments whose settings are supplied by peers
+	Minimum uint64
+}
+
+func (v *SettingsValidator) Validate(channel *bcgo.Channel, cache bcgo.Cache, network bcgo.Network, hash []byte, block *bcgo.Block) error {
+	if block.Previous != nil || len(block.Entry) != 1 {
+		return errors.New(fmt.Sprintf(ERROR_SETTINGS_CHANGED, channel.Name))
+	}
+	entry := block.Entry[0]
+	creator, err := GetCreator(cache, network, v.ExperimentId)
+	if err != nil {
+		return err
+	}
+	if c := entry.Record.Creator; creator != "" && c != creator {
+		return errors.New(fmt.Sprintf(ERROR_SETTINGS_CREATOR_INVALID, c))
+	}
+	for _, prefix := range []string{
+		LAB_PREFIX_CHAT,
+		LAB_PREFIX_DRAW,
+		LAB_PREFIX_MEMBER,
+		LAB_PREFIX_PATH,
+	} {
+		if _, err := cache.GetHead(prefix + v.ExperimentId); err == nil {
+			return errors.New(fmt.Sprintf(ERROR_SETTINGS_LATE, channel.Name))
+		}
+	}
+	if settingsExperimentId(entry.RecordHash) != v.ExperimentId {
+		return errors.New(fmt.Sprintf(ERROR_SETTINGS_CREATOR_UNKNOWN, channel.Name))
+	}
+	settings := &Settings{}
+	if err := unmarshalPayload(entry, settings); err != nil {
+		return err
+	}
+	threshold := settings.Threshold
+	if threshold < v.Minimum {
+		threshold = v.Minimum
+	}
+	if ones := bcgo.Ones(hash); ones < threshold {
+		return errors.New(fmt.Sprintf(bcgo.ERROR_HASH_TOO_WEAK, ones, threshold))
+	}
+	return nil
+}
+
+// ThresholdValidator ensures every block in a channel of an experiment reaches the proof-of-work threshold declared in the experiment's settings.
+// Only the blocks since the last block validated are checked.
+type ThresholdValidator struct {
+	ExperimentId string
+	// Threshold every block must reach even if the settings declare a lower one, for experiments whose settings are supplied by peers
+	Minimum uint64
+	lock    sync.Mutex
+	// Hash of the last block validated
+	head []byte
+	// Threshold the last block validated was checked against
+	threshold uint64
+}
+
+func (v *ThresholdValidator) Validate(channel *bcgo.Channel, cache bcgo.Cache, network bcgo.Network, hash []byte, block *bcgo.Block) error {
+	v.lock.Lock()
+	defer v.lock.Unlock()
+	threshold, err := v.Threshold(cache, network)
+	if err != nil {
+		return err
+	}
+	head := v.head
+	if threshold > v.threshold {
+		// Blocks accepted at a lower threshold must be checked again
+		head = nil
+	}
+	if _, err := iterateSince(channel, cache, network, head, hash, block, func(h []byte, b *bcgo.Block) error {
+		if ones := bcgo.Ones(h); ones < threshold {
+			return errors.New(fmt.Sprintf(bcgo.ERROR_HASH_TOO_WEAK, ones, threshold))
+		}
+		return nil
+	}); err != nil {
+		return err
+	}
+	v.head = hash
+	v.threshold = threshold
+	return nil
+}
+
+// Threshold returns the threshold every block must reach, that declared in the experiment's settings unless lower than the minimum.
+func (v *ThresholdValidator) Threshold(cache bcgo.Cache, network bcgo.Network) (uint64, error) {
+	threshold, err := GetThreshold(cache, network, v.ExperimentId)
+	if err != nil {
+		return 0, err
+	}
+	if threshold < v.Minimum {
+		threshold = v.Minimum
+	}
+	return threshold, nil
+}
@@ -0,0 +1,136 @@
+/*
+ * Copyright 2020 Aletheia Ware LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package labgo_test
+
+import (
+	"fmt"
+	"github.com/AletheiaWareLLC/bcgo"
+	"github.com/AletheiaWareLLC/cryptogo"
+	"github.com/AletheiaWareLLC/labgo"
+	"github.com/AletheiaWareLLC/testinggo"
+	"testing"
+)
+
+func TestGetThreshold(t *testing.T) {
+	node := makeNode(t, "Alice")
+	t.Run("Legacy", func(t *testing.T) {
+		threshold, err := labgo.GetThreshold(node.Cache, nil, "foobar")
+		testinggo.AssertNoError(t, err)
+		if threshold != labgo.THRESHOLD_DEFAULT {
+			t.Fatalf("Incorrect threshold; expected '%d', got '%d'", labgo.THRESHOLD_DEFAULT, threshold)
+		}
+	})
+	for name, threshold := range map[string]uint64{
+		"LAN":    labgo.THRESHOLD_LAN,
+		"Public": labgo.THRESHOLD_PUBLIC,
+	} {
+		t.Run(name, func(t *testing.T) {
+			experiment, err := labgo.CreateFromReader(node, nil, nil, threshold, "", nil)
+			testinggo.AssertNoError(t, err)
+			got, err := labgo.GetThreshold(node.Cache, nil, experiment.ID)
+			testinggo.AssertNoError(t, err)
+			if got != threshold {
+				t.Fatalf("Incorrect threshold; expected '%d', got '%d'", threshold, got)
+			}
+			got, err = labgo.ChannelThreshold(node.Cache, nil, experiment.Path)
+			testinggo.AssertNoError(t, err)
+			if got != threshold {
+				t.Fatalf("Incorrect channel threshold; expected '%d', got '%d'", threshold, got)
+			}
+		})
+	}
+}
+
+func TestThresholdValidator(t *testing.T) {
+	node := makeNode(t, "Alice")
+	experiment, err := labgo.CreateFromReader(node, nil, nil, labgo.THRESHOLD_PUBLIC, "", nil)
+	testinggo.AssertNoError(t, err)
+	hash, record, err := labgo.ProtoToRecord(node.Alias, node.Key, bcgo.Timestamp(), nil, &labgo.Chat{
+		Text: "Hello",
+	})
+	testinggo.AssertNoError(t, err)
+	block := &bcgo.Block{
+		Timestamp:   bcgo.Timestamp(),
+		ChannelName: experiment.Chat.Name,
+		Length:      1,
+		Miner:       node.Alias,
+		Entry: []*bcgo.BlockEntry{
+			&bcgo.BlockEntry{
+				RecordHash: hash,
+				Record:     record,
+			},
+		},
+	}
+	// Find a nonce below the experiment's threshold
+	var ones uint64
+	for block.Nonce = 1; ; block.Nonce++ {
+		hash, err = cryptogo.HashProtobuf(block)
+		testinggo.AssertNoError(t, err)
+		if ones = bcgo.Ones(hash); ones < labgo.THRESHOLD_PUBLIC {
+			break
+		}
+	}
+	err = experiment.Chat.Update(node.Cache, nil, hash, block)
+	testinggo.AssertError(t, fmt.Sprintf(bcgo.ERROR_CHAIN_INVALID, fmt.Sprintf(bcgo.ERROR_HASH_TOO_WEAK, ones, labgo.THRESHOLD_PUBLIC)), err)
+}
+
+func TestSettingsValidator(t *testing.T) {
+	cache := bcgo.NewMemoryCache(10)
+	alice := makeRegisteredNode(t, "Alice", cache)
+	t.Run("Changed", func(t *testing.T) {
+		experiment, err := labgo.CreateFromReader(alice, nil, nil, labgo.THRESHOLD_PUBLIC, "", nil)
+		testinggo.AssertNoError(t, err)
+		_, err = labgo.WriteProto(alice, nil, experiment.Settings, nil, nil, &labgo.Settings{
+			Threshold: labgo.THRESHOLD_LAN,
+		})
+		testinggo.AssertError(t, fmt.Sprintf(bcgo.ERROR_CHAIN_INVALID, fmt.Sprintf(labgo.ERROR_SETTINGS_CHANGED, experiment.Settings.Name)), err)
+	})
+	t.Run("Creator", func(t *testing.T) {
+		// Experiment created by Alice before settings were recorded, which Charlie has not yet cached
+		_, err := labgo.WriteProto(alice, nil, labgo.OpenPathChannel(alice, "foobar"), nil, nil, &labgo.Path{
+			Path: []string{"foo.txt"},
+		})
+		testinggo.AssertNoError(t, err)
+		charlie := makeNode(t, "Charlie")
+		charlie.Network = &cacheNetwork{cache}
+		_, err = labgo.WriteProto(charlie, nil, labgo.OpenSettingsChannel("foobar"), nil, nil, &labgo.Settings{
+			Threshold: labgo.THRESHOLD_LAN,
+		})
+		testinggo.AssertError(t, fmt.Sprintf(bcgo.ERROR_CHAIN_INVALID, fmt.Sprintf(labgo.ERROR_SETTINGS_CREATOR_INVALID, "Charlie")), err)
+	})
+	t.Run("CreatorUnknown", func(t *testing.T) {
+		// Settings not recorded when the experiment was created cannot identify its creator
+		charlie := makeNode(t, "Charlie")
+		charlie.Network = &cacheNetwork{cache}
+		_, err := labgo.WriteProto(charlie, nil, labgo.OpenSettingsChannel("foobaz"), nil, nil, &labgo.Settings{
+			Threshold: bcgo.THRESHOLD_Z,
+		})
+		testinggo.AssertError(t, fmt.Sprintf(bcgo.ERROR_CHAIN_INVALID, fmt.Sprintf(labgo.ERROR_SETTINGS_CREATOR_UNKNOWN, labgo.LAB_PREFIX_SETTINGS+"foobaz")), err)
+	})
+	t.Run("Late", func(t *testing.T) {
+		// Experiment created by Alice before settings were recorded
+		_, err := labgo.WriteProto(alice, nil, labgo.OpenMemberChannel("barfoo"), nil, nil, &labgo.Member{
+			Alias: "Alice",
+			Role:  labgo.Role_OWNER,
+		})
+		testinggo.AssertNoError(t, err)
+		_, err = labgo.WriteProto(alice, nil, labgo.OpenSettingsChannel("barfoo"), nil, nil, &labgo.Settings{
+			Threshold: labgo.THRESHOLD_LAN,
+		})
+		testinggo.AssertError(t, fmt.Sprintf(bcgo.ERROR_CHAIN_INVALID, fmt.Sprintf(labgo.ERROR_SETTINGS_LATE, labgo.LAB_PREFIX_SETTINGS+"barfoo")), err)
+	})
+}
@@ -0,0 +1,205 @@
+/*
+ * Copyright 2020 Aletheia Ware LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package labgo
+
+import (
+	"bytes"
+	"crypto/rsa"
+	"fmt"
+	"github.com/AletheiaWareLLC/bcgo"
+	"github.com/AletheiaWareLLC/cryptogo"
+	"log"
+	"math"
+	"sort"
+)
+
+const (
+	// Number of deltas, or bytes removed and added by deltas, after which a new snapshot is written
+	SNAPSHOT_DELTA_COUNT = 1024
+	SNAPSHOT_DELTA_SIZE  = uint64(64 * 1024 * 1024) // 64Mb
+)
+
+// WriteSnapshot writes the current content of the file to its channel as a snapshot.
+// The snapshot is split into parts as ReaderToChannel splits content, which readers combine instead of replaying every earlier delta.
+func WriteSnapshot(node *Node, listener bcgo.MiningListener, channel *bcgo.Channel, acl map[string]*rsa.PublicKey) error {
+	// Read every delta written
+	if err := flushBatch(node); err != nil {
+		return err
+	}
+	table, err := ChannelToPieceTableAt(node, channel, math.MaxUint64)
+	if err != nil {
+		return err
+	}
+	content := table.Bytes()
+	snapshot := &Snapshot{
+		Hash:   cryptogo.Hash(content),
+		Length: uint64(len(content)),
+	}
+	if len(content) == 0 {
+		_, err := WriteProto(node, listener, channel, acl, nil, &Delta{
+			Snapshot: snapshot,
+		})
+		return err
+	}
+	return writeChunked(node, listener, channel, acl, bytes.NewReader(content), snapshot)
+}
+
+// SnapshotIfNeeded writes a snapshot if the deltas since the latest snapshot exceed SNAPSHOT_DELTA_COUNT or SNAPSHOT_DELTA_SIZE, and returns true if it did.
+// Deltas pending in the node's batch are counted without flushing it, so the batch is only mined when a snapshot is written.
+func SnapshotIfNeeded(node *Node, listener bcgo.MiningListener, channel *bcgo.Channel, acl map[string]*rsa.PublicKey) (bool, error) {
+	var count int
+	var size uint64
+	// countDelta returns true once the deltas counted so far need a snapshot, or the delta is itself a snapshot
+	countDelta := func(entry *bcgo.BlockEntry) (bool, error) {
+		d, err := unmarshalDelta(node, entry)
+		if err != nil {
+			return false, err
+		}
+		if d.Snapshot != nil {
+			return true, nil
+		}
+		count++
+		size += removeLength(d) + addLength(d)
+		return count >= SNAPSHOT_DELTA_COUNT || size >= SNAPSHOT_DELTA_SIZE, nil
+	}
+	stopped := false
+	if batch := getBatch(node); batch != nil {
+		pending := batch.pendingEntries(channel.Name)
+		for i := len(pending) - 1; i >= 0 && !stopped; i-- {
+			var err error
+			if stopped, err = countDelta(pending[i]); err != nil {
+				return false, err
+			}
+		}
+	}
+	if !stopped {
+		if err := bcgo.Iterate(channel.Name, channel.Head, nil, node.Cache, node.Network, func(hash []byte, block *bcgo.Block) error {
+			for i := len(block.Entry) - 1; i >= 0; i-- {
+				stop, err := countDelta(block.Entry[i])
+				if err != nil {
+					return err
+				}
+				if stop {
+					return bcgo.StopIterationError{}
+				}
+			}
+			return nil
+		}); err != nil {
+			switch err.(type) {
+			case bcgo.StopIterationError:
+				// Do nothing
+				break
+			default:
+				return false, err
+			}
+		}
+	}
+	if count < SNAPSHOT_DELTA_COUNT && size < SNAPSHOT_DELTA_SIZE {
+		return false, nil
+	}
+	return true, WriteSnapshot(node, listener, channel, acl)
+}
+
+// ChannelToPieceTableAt reconstructs the content of a file as it was at the given timestamp into a PieceTable.
+// Blocks are read from the head back to the latest complete snapshot created at or before the timestamp, which is then combined with the deltas created after it.
+// Only snapshots the channel's DeltaValidator found to match the content replayed from the deltas before them are trusted, otherwise the content is replayed from the start.
+func ChannelToPieceTableAt(node *Node, channel *bcgo.Channel, at uint64) (*PieceTable, error) {
+	threshold, err := ChannelThreshold(node.Cache, node.Network, channel)
+	if err != nil {
+		return nil, err
+	}
+	var validator *DeltaValidator
+	for _, v := range channel.Validators {
+		if v, ok := v.(*DeltaValidator); ok {
+			validator = v
+		}
+	}
+	table := &PieceTable{}
+	// Deltas after the snapshot, newest first
+	var deltas []*Delta
+	// Parts of the snapshots seen so far, keyed by hash and length
+	parts := make(map[string][]*Delta)
+	if err := bcgo.Iterate(channel.Name, channel.Head, nil, node.Cache, node.Network, func(hash []byte, block *bcgo.Block) error {
+		for i := len(block.Entry) - 1; i >= 0; i-- {
+			entry := block.Entry[i]
+			if entry.Record.Timestamp > at {
+				continue
+			}
+			d, err := unmarshalDelta(node, entry)
+			if err != nil {
+				return err
+			}
+			if err := resolveChunks(node, threshold, d); err != nil {
+				return err
+			}
+			s := d.Snapshot
+			if s == nil {
+				deltas = append(deltas, d)
+				continue
+			}
+			if validator == nil || !validator.Checked(entry.RecordHash) {
+				// Snapshots do not change the file
+				continue
+			}
+			key := fmt.Sprintf("%x-%d", s.Hash, s.Length)
+			parts[key] = append(parts[key], d)
+			if d.Offset == 0 {
+				// First part of the snapshot
+				if t := assembleSnapshot(s, parts[key]); t != nil {
+					table = t
+					return bcgo.StopIterationError{}
+				}
+				log.Println("Ignoring incomplete or invalid snapshot:", key)
+				delete(parts, key)
+			}
+		}
+		return nil
+	}); err != nil {
+		switch err.(type) {
+		case bcgo.StopIterationError:
+			// Do nothing
+			break
+		default:
+			return nil, err
+		}
+	}
+	for i := len(deltas) - 1; i >= 0; i-- {
+		table.Apply(deltas[i])
+	}
+	return table, nil
+}
+
+// assembleSnapshot returns a PieceTable containing the content of the snapshot, or nil if the parts are incomplete or do not match the snapshot's hash.
+func assembleSnapshot(snapshot *Snapshot, parts []*Delta) *PieceTable {
+	sort.SliceStable(parts, func(i, j int) bool {
+		return parts[i].Offset < parts[j].Offset
+	})
+	table := &PieceTable{}
+	for _, p := range parts {
+		if p.Offset != table.Len() {
+			return nil
+		}
+		table.Apply(&Delta{
+			Offset: p.Offset,
+			Add:    p.Add,
+		})
+	}
+	if table.Len() != snapshot.Length || !bytes.Equal(cryptogo.Hash(table.Bytes()), snapshot.Hash) {
+		return nil
+	}
+	return table
+}
@@ -0,0 +1,201 @@
+/*
+ * Copyright 2020 Aletheia Ware LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package labgo_test
+
+import (
+	"crypto/rsa"
+	"encoding/base64"
+	"fmt"
+	"github.com/AletheiaWareLLC/bcgo"
+	"github.com/AletheiaWareLLC/cryptogo"
+	"github.com/AletheiaWareLLC/labgo"
+	"github.com/AletheiaWareLLC/testinggo"
+	"testing"
+)
+
+func TestWriteSnapshot(t *testing.T) {
+	node := makeNode(t, "Alice")
+	channel := labgo.OpenFileChannel(node, "foobar", labgo.THRESHOLD_DEFAULT)
+	node.AddChannel(channel)
+	for _, d := range []*labgo.Delta{
+		&labgo.Delta{
+			Add: []byte("foobar"),
+		},
+		&labgo.Delta{
+			Offset: 3,
+			Remove: []byte("bar"),
+			Add:    []byte("blah"),
+		},
+	} {
+		_, err := labgo.WriteProto(node, nil, channel, nil, nil, d)
+		testinggo.AssertNoError(t, err)
+	}
+	first := channel.Head
+	needed, err := labgo.SnapshotIfNeeded(node, nil, channel, nil)
+	testinggo.AssertNoError(t, err)
+	if needed {
+		t.Fatalf("Expected snapshot not to be needed")
+	}
+	testinggo.AssertNoError(t, labgo.WriteSnapshot(node, nil, channel, nil))
+	_, err = labgo.WriteProto(node, nil, channel, nil, nil, &labgo.Delta{
+		Add: []byte(">"),
+	})
+	testinggo.AssertNoError(t, err)
+
+	// Blocks before the snapshot are not needed to reconstruct the file
+	cache := node.Cache.(*bcgo.MemoryCache)
+	delete(cache.Block, base64.RawURLEncoding.EncodeToString(first))
+	buffer, err := labgo.ChannelToBuffer(node, channel)
+	testinggo.AssertNoError(t, err)
+	if got := string(buffer); got != ">fooblah" {
+		t.Fatalf("Incorrect buffer; expected '%s', got '%s'", ">fooblah", got)
+	}
+}
+
+func TestSnapshotValidation(t *testing.T) {
+	node := makeNode(t, "Alice")
+	channel := labgo.OpenFileChannel(node, "foobar", labgo.THRESHOLD_DEFAULT)
+	_, err := labgo.WriteProto(node, nil, channel, nil, nil, &labgo.Delta{
+		Add: []byte("foobar"),
+	})
+	testinggo.AssertNoError(t, err)
+	_, err = labgo.WriteProto(node, nil, channel, nil, nil, &labgo.Delta{
+		Add: []byte("foo"),
+		Snapshot: &labgo.Snapshot{
+			Hash:   cryptogo.Hash([]byte("foo")),
+			Length: 3,
+		},
+	})
+	testinggo.AssertError(t, fmt.Sprintf(bcgo.ERROR_CHAIN_INVALID, fmt.Sprintf(labgo.ERROR_SNAPSHOT_INVALID, 3, 6)), err)
+
+	// Snapshot content and hash do not match the file
+	channel = labgo.OpenFileChannel(node, "barfoo", labgo.THRESHOLD_DEFAULT)
+	_, err = labgo.WriteProto(node, nil, channel, nil, nil, &labgo.Delta{
+		Add: []byte("foobar"),
+	})
+	testinggo.AssertNoError(t, err)
+	forged := cryptogo.Hash([]byte("barfoo"))
+	_, err = labgo.WriteProto(node, nil, channel, nil, nil, &labgo.Delta{
+		Add: []byte("barfoo"),
+		Snapshot: &labgo.Snapshot{
+			Hash:   forged,
+			Length: 6,
+		},
+	})
+	testinggo.AssertError(t, fmt.Sprintf(bcgo.ERROR_CHAIN_INVALID, fmt.Sprintf(labgo.ERROR_SNAPSHOT_HASH_INVALID, base64.RawURLEncoding.EncodeToString(forged))), err)
+}
+
+func TestChannelToBuffer_IgnoresBadSnapshot(t *testing.T) {
+	node := makeNode(t, "Alice")
+	channel := labgo.OpenFileChannel(node, "foobar", labgo.THRESHOLD_DEFAULT)
+	for _, d := range []*labgo.Delta{
+		&labgo.Delta{
+			Add: []byte("foobar"),
+		},
+		// Snapshot content does not match its hash
+		&labgo.Delta{
+			Add: []byte("barfoo"),
+			Snapshot: &labgo.Snapshot{
+				Hash:   cryptogo.Hash([]byte("foobar")),
+				Length: 6,
+			},
+		},
+	} {
+		_, err := labgo.WriteProto(node, nil, channel, nil, nil, d)
+		testinggo.AssertNoError(t, err)
+	}
+	buffer, err := labgo.ChannelToBuffer(node, channel)
+	testinggo.AssertNoError(t, err)
+	if got := string(buffer); got != "foobar" {
+		t.Fatalf("Incorrect buffer; expected '%s', got '%s'", "foobar", got)
+	}
+}
+
+func TestChannelToBuffer_IgnoresUncheckedSnapshot(t *testing.T) {
+	node := makeNode(t, "Alice")
+	// Validated without the node, so the encrypted content and the snapshot cannot be checked
+	channel := labgo.OpenFileChannel(nil, "foobar", labgo.THRESHOLD_DEFAULT)
+	for _, w := range []struct {
+		acl   map[string]*rsa.PublicKey
+		delta *labgo.Delta
+	}{
+		{
+			acl: map[string]*rsa.PublicKey{
+				node.Alias: &node.Key.PublicKey,
+			},
+			delta: &labgo.Delta{
+				Add: []byte("foo"),
+			},
+		},
+		{
+			delta: &labgo.Delta{
+				Add: []byte("bar"),
+				Snapshot: &labgo.Snapshot{
+					Hash:   cryptogo.Hash([]byte("bar")),
+					Length: 3,
+				},
+			},
+		},
+	} {
+		_, err := labgo.WriteProto(node, nil, channel, w.acl, nil, w.delta)
+		testinggo.AssertNoError(t, err)
+	}
+	opened := labgo.OpenFileChannel(node, "foobar", labgo.THRESHOLD_DEFAULT)
+	testinggo.AssertNoError(t, opened.LoadCachedHead(node.Cache))
+	buffer, err := labgo.ChannelToBuffer(node, opened)
+	testinggo.AssertNoError(t, err)
+	if got := string(buffer); got != "foo" {
+		t.Fatalf("Incorrect buffer; expected '%s', got '%s'", "foo", got)
+	}
+}
+
+func TestSnapshotIfNeeded_Batched(t *testing.T) {
+	node := makeNode(t, "Alice")
+	channel := labgo.OpenFileChannel(node, "foobar", labgo.THRESHOLD_DEFAULT)
+	node.AddChannel(channel)
+	batch, err := labgo.StartBatch(node, nil)
+	testinggo.AssertNoError(t, err)
+	_, err = labgo.WriteProto(node, nil, channel, nil, nil, &labgo.Delta{
+		Add: []byte("foobar"),
+	})
+	testinggo.AssertNoError(t, err)
+	needed, err := labgo.SnapshotIfNeeded(node, nil, channel, nil)
+	testinggo.AssertNoError(t, err)
+	if needed {
+		t.Fatalf("Expected snapshot not to be needed")
+	}
+	// Batch is not flushed unless a snapshot is written
+	if channel.Head != nil {
+		t.Fatalf("Expected records to be pending")
+	}
+	for i := 1; i < labgo.SNAPSHOT_DELTA_COUNT; i++ {
+		_, err = labgo.WriteProto(node, nil, channel, nil, nil, &labgo.Delta{
+			Add: []byte("a"),
+		})
+		testinggo.AssertNoError(t, err)
+	}
+	// Pending deltas are counted
+	needed, err = labgo.SnapshotIfNeeded(node, nil, channel, nil)
+	testinggo.AssertNoError(t, err)
+	if !needed {
+		t.Fatalf("Expected snapshot to be needed")
+	}
+	testinggo.AssertNoError(t, batch.Close())
+	if blocks, entries := countBlocks(t, node, channel); entries != labgo.SNAPSHOT_DELTA_COUNT+1 {
+		t.Fatalf("Incorrect entries; expected '%d', got '%d' in '%d' blocks", labgo.SNAPSHOT_DELTA_COUNT+1, entries, blocks)
+	}
+}
@@ -0,0 +1,129 @@
+/*
+ * Copyright 2020 Aletheia Ware LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package labgo
+
+import (
+	"github.com/AletheiaWareLLC/bcgo"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// SyncSummary lists the paths, relative to the synced directory, of the files changed by Sync.
+type SyncSummary struct {
+	Added    []string
+	Modified []string
+	Deleted  []string
+}
+
+// Sync records the differences between the files under the given root and the current state of the experiment.
+// New files are added to the experiment, modified files are updated with the deltas between the two versions, and files missing from the root are deleted.
+// Records are batched, so each channel is mined into as few blocks as possible.
+func Sync(node *Node, listener bcgo.MiningListener, experiment *Experiment, root string) (*SyncSummary, error) {
+	var summary *SyncSummary
+	if err := batchWrites(node, listener, func() error {
+		var err error
+		summary, err = syncFiles(node, listener, experiment, root)
+		return err
+	}); err != nil {
+		return nil, err
+	}
+	return summary, nil
+}
+
+func syncFiles(node *Node, listener bcgo.MiningListener, experiment *Experiment, root string) (*SyncSummary, error) {
+	files, err := ListFiles(node, experiment.Path)
+	if err != nil {
+		return nil, err
+	}
+	// Maps path to file ID
+	ids := make(map[string]string)
+	for id, p := range files {
+		if err := ValidatePath(p); err != nil {
+			return nil, err
+		}
+		ids[strings.Join(p, "/")] = id
+	}
+	summary := &SyncSummary{}
+	if err := filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			// Skip directories
+			return nil
+		}
+		if info.Mode()&os.ModeSymlink == os.ModeSymlink {
+			// Skip symbolic links
+			return nil
+		}
+		segments, err := RelativePath(root, path)
+		if err != nil {
+			return err
+		}
+		key := strings.Join(segments, "/")
+		id, ok := ids[key]
+		if !ok {
+			_, file, err := CreatePath(node, listener, experiment.Path, experiment.Access, segments)
+			if err != nil {
+				return err
+			}
+			if err := PathToChannel(node, listener, file, experiment.Access, path); err != nil {
+				return err
+			}
+			summary.Added = append(summary.Added, key)
+			return nil
+		}
+		delete(ids, key)
+		file := GetFileChannel(node, id)
+		original, err := ChannelToBuffer(node, file)
+		if err != nil {
+			return err
+		}
+		modified := false
+		if err := DiffPathToDeltas(original, path, MAX_DELTA_LENGTH, func(d *Delta) error {
+			modified = true
+			_, err := WriteDelta(node, listener, file, experiment.Access, d)
+			return err
+		}); err != nil {
+			return err
+		}
+		if modified {
+			summary.Modified = append(summary.Modified, key)
+			if _, err := SnapshotIfNeeded(node, listener, file, experiment.Access); err != nil {
+				return err
+			}
+		}
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+	// Remaining files no longer exist under root
+	var deleted []string
+	for key := range ids {
+		deleted = append(deleted, key)
+	}
+	sort.Strings(deleted)
+	for _, key := range deleted {
+		if _, err := DeletePath(node, listener, experiment.Path, experiment.Access, ids[key]); err != nil {
+			return nil, err
+		}
+		summary.Deleted = append(summary.Deleted, key)
+	}
+	return summary, nil
+}
@@ -0,0 +1,87 @@
+/*
+ * Copyright 2020 Aletheia Ware LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package labgo_test
+
+import (
+	"github.com/AletheiaWareLLC/labgo"
+	"github.com/AletheiaWareLLC/testinggo"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestSync(t *testing.T) {
+	dir, err := ioutil.TempDir("", "lab")
+	testinggo.AssertNoError(t, err)
+	defer os.RemoveAll(dir)
+	write := func(name, content string) {
+		testinggo.AssertNoError(t, ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0666))
+	}
+	write("foo.txt", "foo")
+	write("bar.txt", "bar")
+	write("baz.txt", "baz")
+	node := makeNode(t, "Alice")
+	experiment, err := labgo.CreateFromPaths(node, nil, nil, labgo.THRESHOLD_LAN, dir)
+	testinggo.AssertNoError(t, err)
+
+	// Unchanged
+	summary, err := labgo.Sync(node, nil, experiment, dir)
+	testinggo.AssertNoError(t, err)
+	if want := (&labgo.SyncSummary{}); !reflect.DeepEqual(summary, want) {
+		t.Fatalf("Incorrect summary; expected '%v', got '%v'", want, summary)
+	}
+
+	write("foo.txt", "foobar")
+	testinggo.AssertNoError(t, os.Remove(filepath.Join(dir, "bar.txt")))
+	testinggo.AssertNoError(t, os.Mkdir(filepath.Join(dir, "new"), os.ModePerm))
+	write(filepath.Join("new", "blah.txt"), "blah")
+	summary, err = labgo.Sync(node, nil, experiment, dir)
+	testinggo.AssertNoError(t, err)
+	want := &labgo.SyncSummary{
+		Added:    []string{"new/blah.txt"},
+		Modified: []string{"foo.txt"},
+		Deleted:  []string{"bar.txt"},
+	}
+	if !reflect.DeepEqual(summary, want) {
+		t.Fatalf("Incorrect summary; expected '%v', got '%v'", want, summary)
+	}
+
+	// Deletion is only recorded once
+	summary, err = labgo.Sync(node, nil, experiment, dir)
+	testinggo.AssertNoError(t, err)
+	if want := (&labgo.SyncSummary{}); !reflect.DeepEqual(summary, want) {
+		t.Fatalf("Incorrect summary; expected '%v', got '%v'", want, summary)
+	}
+
+	destination, err := ioutil.TempDir("", "lab")
+	testinggo.AssertNoError(t, err)
+	defer os.RemoveAll(destination)
+	testinggo.AssertNoError(t, labgo.Save(node, experiment, destination))
+	for name, content := range map[string]string{
+		"foo.txt":                        "foobar",
+		"baz.txt":                        "baz",
+		filepath.Join("new", "blah.txt"): "blah",
+	} {
+		data, err := ioutil.ReadFile(filepath.Join(destination, name))
+		testinggo.AssertNoError(t, err)
+		if string(data) != content {
+			t.Fatalf("Incorrect content of '%s'; expected '%s', got '%s'", name, content, data)
+		}
+	}
+}
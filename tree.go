@@ -0,0 +1,75 @@
+/*
+ * Copyright 2020 Aletheia Ware LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package labgo
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Tree maps the path of each file, joined with forward slashes, to its content.
+type Tree map[string][]byte
+
+// ExperimentTree reconstructs the files of the experiment as they were at the given timestamp.
+func ExperimentTree(node *Node, experiment *Experiment, at uint64) (Tree, error) {
+	files, err := ListFilesAt(node, experiment.Path, at)
+	if err != nil {
+		return nil, err
+	}
+	tree := make(Tree)
+	for id, p := range files {
+		buffer, err := ChannelToBufferAt(node, GetFileChannel(node, id), at)
+		if err != nil {
+			return nil, err
+		}
+		tree[strings.Join(p, "/")] = buffer
+	}
+	return tree, nil
+}
+
+// DirectoryTree reads the files under the given root, skipping symbolic links as CreateFromPaths does.
+func DirectoryTree(root string) (Tree, error) {
+	tree := make(Tree)
+	if err := filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			// Skip directories
+			return nil
+		}
+		if info.Mode()&os.ModeSymlink == os.ModeSymlink {
+			// Skip symbolic links
+			return nil
+		}
+		segments, err := RelativePath(root, path)
+		if err != nil {
+			return err
+		}
+		buffer, err := ioutil.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		tree[strings.Join(segments, "/")] = buffer
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+	return tree, nil
+}
@@ -0,0 +1,198 @@
+/*
+ * Copyright 2020 Aletheia Ware LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package labgo
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"sort"
+)
+
+const (
+	// Number of unchanged lines shown around each change
+	DIFF_CONTEXT_LINES = 3
+	DEV_NULL           = "/dev/null"
+)
+
+// Diff writes a unified diff of the files of the experiment between the two timestamps.
+func Diff(node *Node, experiment *Experiment, from, to uint64, writer io.Writer) error {
+	a, err := ExperimentTree(node, experiment, from)
+	if err != nil {
+		return err
+	}
+	b, err := ExperimentTree(node, experiment, to)
+	if err != nil {
+		return err
+	}
+	return DiffTrees(a, b, writer)
+}
+
+// DiffTrees writes a unified diff of every file which differs between the two trees, in order of path.
+func DiffTrees(from, to Tree, writer io.Writer) error {
+	var paths []string
+	for p := range from {
+		paths = append(paths, p)
+	}
+	for p := range to {
+		if _, ok := from[p]; !ok {
+			paths = append(paths, p)
+		}
+	}
+	sort.Strings(paths)
+	for _, p := range paths {
+		a, inA := from[p]
+		b, inB := to[p]
+		if inA && inB && bytes.Equal(a, b) {
+			continue
+		}
+		nameA := "a/" + p
+		if !inA {
+			nameA = DEV_NULL
+		}
+		nameB := "b/" + p
+		if !inB {
+			nameB = DEV_NULL
+		}
+		if err := UnifiedDiff(nameA, nameB, a, b, writer); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// UnifiedDiff writes the differences between two versions of a file in the unified format, or a marker if either is binary.
+func UnifiedDiff(nameA, nameB string, a, b []byte, writer io.Writer) error {
+	if !IsText(a) || !IsText(b) {
+		_, err := fmt.Fprintf(writer, "Binary files %s and %s differ\n", nameA, nameB)
+		return err
+	}
+	linesA := splitLines(a)
+	linesB := splitLines(b)
+	matches := Compare(len(linesA), len(linesB), func(i, j int) bool {
+		return bytes.Equal(linesA[i], linesB[j])
+	})
+	// Collect the regions between matches which differ
+	type change struct {
+		a0, a1, b0, b1 int
+	}
+	var changes []change
+	var i, j int
+	for _, m := range append(matches, Match{
+		A: len(linesA),
+		B: len(linesB),
+	}) {
+		if m.A > i || m.B > j {
+			changes = append(changes, change{i, m.A, j, m.B})
+		}
+		i = m.A + m.Length
+		j = m.B + m.Length
+	}
+	if len(changes) == 0 {
+		return nil
+	}
+	if _, err := fmt.Fprintf(writer, "--- %s\n+++ %s\n", nameA, nameB); err != nil {
+		return err
+	}
+	for len(changes) > 0 {
+		// Group changes separated by no more than twice the context into one hunk
+		end := 1
+		for end < len(changes) && changes[end].a0-changes[end-1].a1 <= 2*DIFF_CONTEXT_LINES {
+			end++
+		}
+		hunk := changes[:end]
+		changes = changes[end:]
+		first, last := hunk[0], hunk[len(hunk)-1]
+		a0 := max(first.a0-DIFF_CONTEXT_LINES, 0)
+		b0 := first.b0 - (first.a0 - a0)
+		a1 := min(last.a1+DIFF_CONTEXT_LINES, len(linesA))
+		b1 := last.b1 + (a1 - last.a1)
+		if _, err := fmt.Fprintf(writer, "@@ -%s +%s @@\n", formatRange(a0, a1-a0), formatRange(b0, b1-b0)); err != nil {
+			return err
+		}
+		k := a0
+		for _, c := range hunk {
+			if err := writeLines(writer, " ", linesA[k:c.a0]); err != nil {
+				return err
+			}
+			if err := writeLines(writer, "-", linesA[c.a0:c.a1]); err != nil {
+				return err
+			}
+			if err := writeLines(writer, "+", linesB[c.b0:c.b1]); err != nil {
+				return err
+			}
+			k = c.a1
+		}
+		if err := writeLines(writer, " ", linesA[k:a1]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// splitLines splits the buffer after each newline.
+func splitLines(buffer []byte) [][]byte {
+	var lines [][]byte
+	for len(buffer) > 0 {
+		i := bytes.IndexByte(buffer, '\n') + 1
+		if i == 0 {
+			i = len(buffer)
+		}
+		lines = append(lines, buffer[:i])
+		buffer = buffer[i:]
+	}
+	return lines
+}
+
+func writeLines(writer io.Writer, prefix string, lines [][]byte) error {
+	for _, l := range lines {
+		if _, err := fmt.Fprintf(writer, "%s%s", prefix, l); err != nil {
+			return err
+		}
+		if !bytes.HasSuffix(l, []byte("\n")) {
+			if _, err := fmt.Fprint(writer, "\n\\ No newline at end of file\n"); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// formatRange formats the zero-based start and length of a range of lines as in GNU diff.
+func formatRange(start, length int) string {
+	switch length {
+	case 0:
+		return fmt.Sprintf("%d,0", start)
+	case 1:
+		return fmt.Sprintf("%d", start+1)
+	}
+	return fmt.Sprintf("%d,%d", start+1, length)
+}
+
+func max(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
+
+func min(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
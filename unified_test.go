@@ -0,0 +1,98 @@
+/*
+ * Copyright 2020 Aletheia Ware LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package labgo_test
+
+import (
+	"bytes"
+	"github.com/AletheiaWareLLC/bcgo"
+	"github.com/AletheiaWareLLC/labgo"
+	"github.com/AletheiaWareLLC/testinggo"
+	"io/ioutil"
+	"strings"
+	"testing"
+)
+
+func TestUnifiedDiff(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b string
+		want string
+	}{
+		{
+			name: "Equal",
+			a:    "foo\n",
+			b:    "foo\n",
+		},
+		{
+			name: "Add",
+			b:    "foo\nbar\n",
+			want: "--- a\n+++ b\n@@ -0,0 +1,2 @@\n+foo\n+bar\n",
+		},
+		{
+			name: "Replace",
+			a:    "1\n2\n3\n4\n5\n6\n7\n8\n9\n",
+			b:    "1\n2\n3\n4\nfive\n6\n7\n8\n9\n",
+			want: "--- a\n+++ b\n@@ -2,7 +2,7 @@\n 2\n 3\n 4\n-5\n+five\n 6\n 7\n 8\n",
+		},
+		{
+			name: "Separate",
+			a:    "1\n2\n3\n4\n5\n6\n7\n8\n9\n10\n",
+			b:    "one\n2\n3\n4\n5\n6\n7\n8\n9\nten\n",
+			want: "--- a\n+++ b\n@@ -1,4 +1,4 @@\n-1\n+one\n 2\n 3\n 4\n@@ -7,4 +7,4 @@\n 7\n 8\n 9\n-10\n+ten\n",
+		},
+		{
+			name: "NoNewline",
+			a:    "foo\nbar",
+			b:    "foo\nbaz",
+			want: "--- a\n+++ b\n@@ -1,2 +1,2 @@\n foo\n-bar\n\\ No newline at end of file\n+baz\n\\ No newline at end of file\n",
+		},
+		{
+			name: "Binary",
+			a:    "foo\x00",
+			b:    "bar\x00",
+			want: "Binary files a and b differ\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buffer bytes.Buffer
+			testinggo.AssertNoError(t, labgo.UnifiedDiff("a", "b", []byte(tt.a), []byte(tt.b), &buffer))
+			if got := buffer.String(); got != tt.want {
+				t.Fatalf("Incorrect diff; expected '%s', got '%s'", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestDiff(t *testing.T) {
+	node := makeNode(t, "Alice")
+	experiment, err := labgo.CreateFromReader(node, nil, nil, labgo.THRESHOLD_LAN, "foo.txt", ioutil.NopCloser(strings.NewReader("foo\n")))
+	testinggo.AssertNoError(t, err)
+	from := bcgo.Timestamp()
+	_, _, err = labgo.CreatePathFromReader(node, nil, experiment.Path, nil, []string{"bar.txt"}, ioutil.NopCloser(strings.NewReader("bar\n")))
+	testinggo.AssertNoError(t, err)
+	id, err := labgo.GetFileId(node, experiment.Path, []string{"foo.txt"})
+	testinggo.AssertNoError(t, err)
+	_, err = labgo.DeletePath(node, nil, experiment.Path, nil, id)
+	testinggo.AssertNoError(t, err)
+	var buffer bytes.Buffer
+	testinggo.AssertNoError(t, labgo.Diff(node, experiment, from, bcgo.Timestamp(), &buffer))
+	want := "--- /dev/null\n+++ b/bar.txt\n@@ -0,0 +1 @@\n+bar\n--- a/foo.txt\n+++ /dev/null\n@@ -1 +0,0 @@\n-foo\n"
+	if got := buffer.String(); got != want {
+		t.Fatalf("Incorrect diff; expected '%s', got '%s'", want, got)
+	}
+}
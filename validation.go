@@ -0,0 +1,391 @@
+/*
+ * Copyright 2020 Aletheia Ware LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package labgo
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"github.com/AletheiaWareLLC/bcgo"
+	"github.com/AletheiaWareLLC/cryptogo"
+	"github.com/golang/protobuf/proto"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+const (
+	ERROR_DELTA_OFFSET_INVALID  = "Delta offset beyond end of file: %d vs %d"
+	ERROR_DELTA_REMOVE_INVALID  = "Delta removes beyond end of file: %d vs %d"
+	ERROR_DRAW_COORDINATE_LARGE = "Draw coordinate too large: %d vs %d"
+	ERROR_DRAW_POINTS_INVALID   = "Draw points not in pairs: %d"
+	ERROR_DRAW_POINTS_TOO_MANY  = "Draw points too many: %d vs %d"
+	ERROR_DRAW_SIZE_TOO_LARGE   = "Draw size too large: %d vs %d"
+	ERROR_PATH_ABSOLUTE         = "Path is absolute: %s"
+	ERROR_PATH_DELETE_INVALID   = "Path deletion does not reference a file"
+	ERROR_PATH_EMPTY            = "Path is empty"
+	ERROR_PATH_SEGMENT_INVALID  = "Path segment invalid: %s"
+	ERROR_PAYLOAD_INVALID       = "Payload invalid: %s"
+	ERROR_SNAPSHOT_HASH_INVALID = "Snapshot hash does not match file: %s"
+	ERROR_SNAPSHOT_INVALID      = "Snapshot length does not match file: %d vs %d"
+	ERROR_SNAPSHOT_PART_INVALID = "Snapshot part invalid: %d+%d-%d vs %d"
+)
+
+// DeltaValidator ensures every record in a file channel is a Delta which only references offsets within the file, and that snapshots match the content of the file.
+// The content of the file is carried forward from the last block validated, so only the deltas in blocks since then are applied.
+// Encrypted records are decrypted if the node is one of their recipients, otherwise the content is unknown to the node from that record on, and later records are only checked to be Deltas.
+type DeltaValidator struct {
+	Node *Node
+	lock sync.Mutex
+	// Hash of the last block validated
+	head []byte
+	// Content of the file at the last block validated, or nil if unknown
+	content *PieceTable
+	// Hash of the snapshot last found to match the content
+	snapshot []byte
+	// Hashes of the snapshot records found to match the content, so only they are trusted when reading the file
+	checked map[string]bool
+}
+
+func (v *DeltaValidator) Validate(channel *bcgo.Channel, cache bcgo.Cache, network bcgo.Network, hash []byte, block *bcgo.Block) error {
+	v.lock.Lock()
+	defer v.lock.Unlock()
+	head := v.head
+	// Reset until the block is valid, as the content is updated in place
+	v.head = nil
+	blocks, found, err := newBlocks(channel, cache, network, head, hash, block)
+	if err != nil {
+		return err
+	}
+	// Chunks referenced by the deltas are read at the threshold of the file
+	threshold, err := ChannelThreshold(cache, network, channel)
+	if err != nil {
+		return err
+	}
+	if !found {
+		// Replay the chain from the start
+		v.content = &PieceTable{}
+		v.snapshot = nil
+		v.checked = make(map[string]bool)
+	}
+	for i := len(blocks) - 1; i >= 0; i-- {
+		for _, entry := range blocks[i].Entry {
+			if err := v.apply(threshold, entry); err != nil {
+				return err
+			}
+		}
+	}
+	v.head = hash
+	return nil
+}
+
+// apply validates the delta in the entry against the content, and applies it.
+func (v *DeltaValidator) apply(threshold uint64, entry *bcgo.BlockEntry) error {
+	payload := entry.Record.Payload
+	if len(entry.Record.Access) > 0 {
+		if v.Node == nil {
+			v.content = nil
+			return nil
+		}
+		p, err := decryptPayload(v.Node, entry)
+		if err != nil {
+			// Not shared with the node
+			v.content = nil
+			return nil
+		}
+		payload = p
+	}
+	d := &Delta{}
+	if err := proto.Unmarshal(payload, d); err != nil {
+		return errors.New(fmt.Sprintf(ERROR_PAYLOAD_INVALID, err.Error()))
+	}
+	if err := DecompressDelta(d); err != nil {
+		return errors.New(fmt.Sprintf(ERROR_PAYLOAD_INVALID, err.Error()))
+	}
+	if v.content == nil {
+		return nil
+	}
+	if err := ValidateDelta(d, v.content.Len()); err != nil {
+		return err
+	}
+	if s := d.Snapshot; s != nil {
+		if !bytes.Equal(s.Hash, v.snapshot) {
+			if !bytes.Equal(s.Hash, cryptogo.Hash(v.content.Bytes())) {
+				return errors.New(fmt.Sprintf(ERROR_SNAPSHOT_HASH_INVALID, base64.RawURLEncoding.EncodeToString(s.Hash)))
+			}
+			v.snapshot = s.Hash
+		}
+		v.checked[base64.RawURLEncoding.EncodeToString(entry.RecordHash)] = true
+		return nil
+	}
+	if len(d.RemoveChunk) > 0 || len(d.AddChunk) > 0 {
+		if v.Node == nil {
+			v.content = nil
+			return nil
+		}
+		if err := resolveChunks(v.Node, threshold, d); err != nil {
+			return err
+		}
+	}
+	v.content.Apply(d)
+	v.snapshot = nil
+	return nil
+}
+
+// Checked returns true if the snapshot record with the given hash was found to match the content of the file replayed from the records before it.
+func (v *DeltaValidator) Checked(recordHash []byte) bool {
+	v.lock.Lock()
+	defer v.lock.Unlock()
+	return v.checked[base64.RawURLEncoding.EncodeToString(recordHash)]
+}
+
+// ValidateDelta ensures the given delta can be applied to a file of the given length, or for a snapshot that it describes a file of the given length.
+func ValidateDelta(delta *Delta, length uint64) error {
+	if s := delta.Snapshot; s != nil {
+		if s.Length != length {
+			return errors.New(fmt.Sprintf(ERROR_SNAPSHOT_INVALID, s.Length, length))
+		}
+		// Compared against the space remaining, as lengths declared by chunks may overflow when added to the offset
+		if removeLength(delta) > 0 || delta.Offset > s.Length || addLength(delta) > s.Length-delta.Offset {
+			return errors.New(fmt.Sprintf(ERROR_SNAPSHOT_PART_INVALID, delta.Offset, addLength(delta), removeLength(delta), s.Length))
+		}
+		return nil
+	}
+	if delta.Offset > length {
+		return errors.New(fmt.Sprintf(ERROR_DELTA_OFFSET_INVALID, delta.Offset, length))
+	}
+	if removeLength(delta) > length-delta.Offset {
+		return errors.New(fmt.Sprintf(ERROR_DELTA_REMOVE_INVALID, addSaturated(delta.Offset, removeLength(delta)), length))
+	}
+	return nil
+}
+
+// PathValidator ensures every record in a path channel is a Path which stays within the experiment.
+// Legacy records with absolute paths are tolerated once a later record has migrated or deleted them.
+// Only the blocks since the last block validated are checked, and encrypted records are decrypted if the node is one of their recipients.
+type PathValidator struct {
+	Node *Node
+	entryValidator
+}
+
+func (v *PathValidator) Validate(channel *bcgo.Channel, cache bcgo.Cache, network bcgo.Network, hash []byte, block *bcgo.Block) error {
+	var invalid []string
+	errs := make(map[string]error)
+	return v.validate(v.Node, channel, cache, network, hash, block, func(entry *bcgo.BlockEntry, payload []byte) error {
+		p := &Path{}
+		if err := unmarshalMessage(payload, p); err != nil {
+			return err
+		}
+		if p.Deleted {
+			if len(entry.Record.Reference) == 0 {
+				return errors.New(ERROR_PATH_DELETE_INVALID)
+			}
+		} else if err := ValidatePath(p.Path); err != nil {
+			key := base64.RawURLEncoding.EncodeToString(entry.RecordHash)
+			invalid = append(invalid, key)
+			errs[key] = err
+			return nil
+		}
+		for _, r := range entry.Record.Reference {
+			delete(errs, base64.RawURLEncoding.EncodeToString(r.RecordHash))
+		}
+		return nil
+	}, func() error {
+		for _, key := range invalid {
+			if err, ok := errs[key]; ok {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// ValidatePath ensures the given path segments are relative and cannot escape the directory they are joined to.
+func ValidatePath(path []string) error {
+	if len(path) == 0 {
+		return errors.New(ERROR_PATH_EMPTY)
+	}
+	joined := strings.Join(path, "/")
+	if path[0] == "" || filepath.IsAbs(path[0]) || filepath.VolumeName(path[0]) != "" {
+		return errors.New(fmt.Sprintf(ERROR_PATH_ABSOLUTE, joined))
+	}
+	for _, s := range path {
+		if s == "" || s == "." || s == ".." || strings.ContainsAny(s, `/\`) {
+			return errors.New(fmt.Sprintf(ERROR_PATH_SEGMENT_INVALID, s))
+		}
+	}
+	return nil
+}
+
+// ValidateDraw ensures the given stroke is coordinate pairs within the bounds of the canvas, and is not too large to render.
+func ValidateDraw(d *Draw) error {
+	if len(d.Points)%2 != 0 {
+		return errors.New(fmt.Sprintf(ERROR_DRAW_POINTS_INVALID, len(d.Points)))
+	}
+	if len(d.Points) > MAX_DRAW_POINTS {
+		return errors.New(fmt.Sprintf(ERROR_DRAW_POINTS_TOO_MANY, len(d.Points), MAX_DRAW_POINTS))
+	}
+	if d.Size > MAX_DRAW_SIZE {
+		return errors.New(fmt.Sprintf(ERROR_DRAW_SIZE_TOO_LARGE, d.Size, MAX_DRAW_SIZE))
+	}
+	for _, p := range d.Points {
+		if p < -MAX_DRAW_COORDINATE || p > MAX_DRAW_COORDINATE {
+			return errors.New(fmt.Sprintf(ERROR_DRAW_COORDINATE_LARGE, p, MAX_DRAW_COORDINATE))
+		}
+	}
+	return nil
+}
+
+// ChatValidator ensures every record in a chat channel is a Chat.
+// Only the blocks since the last block validated are checked, and encrypted records are decrypted if the node is one of their recipients.
+type ChatValidator struct {
+	Node *Node
+	entryValidator
+}
+
+func (v *ChatValidator) Validate(channel *bcgo.Channel, cache bcgo.Cache, network bcgo.Network, hash []byte, block *bcgo.Block) error {
+	return v.validate(v.Node, channel, cache, network, hash, block, func(entry *bcgo.BlockEntry, payload []byte) error {
+		return unmarshalMessage(payload, &Chat{})
+	}, nil)
+}
+
+// DrawValidator ensures every record in a draw channel is a valid Draw.
+// Only the blocks since the last block validated are checked, and encrypted records are decrypted if the node is one of their recipients.
+type DrawValidator struct {
+	Node *Node
+	entryValidator
+}
+
+func (v *DrawValidator) Validate(channel *bcgo.Channel, cache bcgo.Cache, network bcgo.Network, hash []byte, block *bcgo.Block) error {
+	return v.validate(v.Node, channel, cache, network, hash, block, func(entry *bcgo.BlockEntry, payload []byte) error {
+		d := &Draw{}
+		if err := unmarshalMessage(payload, d); err != nil {
+			return err
+		}
+		return ValidateDraw(d)
+	}, nil)
+}
+
+// entryValidator checks the entries of the blocks added to a channel since the last block it validated.
+type entryValidator struct {
+	lock sync.Mutex
+	// Hash of the last block validated
+	head []byte
+}
+
+// validate calls the callback with the payload of each entry in the blocks since the last block validated, oldest first, and then calls done, if set, before accepting the block.
+// The whole chain is replayed if the last block validated is not in it.
+// Encrypted entries are decrypted if the node is one of their recipients, and skipped otherwise.
+func (v *entryValidator) validate(node *Node, channel *bcgo.Channel, cache bcgo.Cache, network bcgo.Network, hash []byte, block *bcgo.Block, callback func(*bcgo.BlockEntry, []byte) error, done func() error) error {
+	v.lock.Lock()
+	defer v.lock.Unlock()
+	blocks, _, err := newBlocks(channel, cache, network, v.head, hash, block)
+	if err != nil {
+		return err
+	}
+	for i := len(blocks) - 1; i >= 0; i-- {
+		for _, entry := range blocks[i].Entry {
+			payload := entry.Record.Payload
+			if len(entry.Record.Access) > 0 {
+				if node == nil {
+					continue
+				}
+				p, err := decryptPayload(node, entry)
+				if err != nil {
+					// Not shared with the node
+					continue
+				}
+				payload = p
+			}
+			if err := callback(entry, payload); err != nil {
+				return err
+			}
+		}
+	}
+	if done != nil {
+		if err := done(); err != nil {
+			return err
+		}
+	}
+	v.head = hash
+	return nil
+}
+
+// iterateSince calls the callback with each block in the chain ending with the given block that follows the block with the given head hash, newest first, and returns whether the head was found.
+// If the head is not found the callback is called with every block in the chain.
+func iterateSince(channel *bcgo.Channel, cache bcgo.Cache, network bcgo.Network, head, hash []byte, block *bcgo.Block, callback func([]byte, *bcgo.Block) error) (bool, error) {
+	found := false
+	if err := bcgo.Iterate(channel.Name, hash, block, cache, network, func(h []byte, b *bcgo.Block) error {
+		if head != nil && bytes.Equal(h, head) {
+			found = true
+			return bcgo.StopIterationError{}
+		}
+		return callback(h, b)
+	}); err != nil {
+		switch err.(type) {
+		case bcgo.StopIterationError:
+			// Do nothing
+			break
+		default:
+			return false, err
+		}
+	}
+	return found, nil
+}
+
+// newBlocks returns the blocks in the chain ending with the given block that follow the block with the given head hash, newest first, and whether the head was found.
+func newBlocks(channel *bcgo.Channel, cache bcgo.Cache, network bcgo.Network, head, hash []byte, block *bcgo.Block) ([]*bcgo.Block, bool, error) {
+	var blocks []*bcgo.Block
+	found, err := iterateSince(channel, cache, network, head, hash, block, func(h []byte, b *bcgo.Block) error {
+		blocks = append(blocks, b)
+		return nil
+	})
+	return blocks, found, err
+}
+
+// iterateEntries calls the callback with each public entry in the chain ending with the given block, oldest first.
+// Unlike bcgo.IterateChronologically this does not require the head block to be cached, as is the case during validation.
+// Encrypted entries are skipped as their payload can only be read by their recipients.
+func iterateEntries(channel *bcgo.Channel, cache bcgo.Cache, network bcgo.Network, hash []byte, block *bcgo.Block, callback func(*bcgo.BlockEntry) error) error {
+	blocks, _, err := newBlocks(channel, cache, network, nil, hash, block)
+	if err != nil {
+		return err
+	}
+	for i := len(blocks) - 1; i >= 0; i-- {
+		for _, entry := range blocks[i].Entry {
+			if len(entry.Record.Access) > 0 {
+				continue
+			}
+			if err := callback(entry); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func unmarshalPayload(entry *bcgo.BlockEntry, message proto.Message) error {
+	return unmarshalMessage(entry.Record.Payload, message)
+}
+
+func unmarshalMessage(payload []byte, message proto.Message) error {
+	if err := proto.Unmarshal(payload, message); err != nil {
+		return errors.New(fmt.Sprintf(ERROR_PAYLOAD_INVALID, err.Error()))
+	}
+	return nil
+}
@@ -0,0 +1,305 @@
+/*
+ * Copyright 2020 Aletheia Ware LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package labgo_test
+
+import (
+	"crypto/rsa"
+	"fmt"
+	"github.com/AletheiaWareLLC/bcgo"
+	"github.com/AletheiaWareLLC/labgo"
+	"github.com/AletheiaWareLLC/testinggo"
+	"math"
+	"testing"
+)
+
+func TestValidateDelta(t *testing.T) {
+	tests := []struct {
+		name   string
+		delta  *labgo.Delta
+		length uint64
+		err    string
+	}{
+		{
+			name:  "Empty",
+			delta: &labgo.Delta{},
+		},
+		{
+			name: "Append",
+			delta: &labgo.Delta{
+				Offset: 6,
+				Add:    []byte("blah"),
+			},
+			length: 6,
+		},
+		{
+			name: "Replace",
+			delta: &labgo.Delta{
+				Offset: 3,
+				Remove: []byte("bar"),
+				Add:    []byte("blah"),
+			},
+			length: 6,
+		},
+		{
+			name: "OffsetInvalid",
+			delta: &labgo.Delta{
+				Offset: 7,
+				Add:    []byte("blah"),
+			},
+			length: 6,
+			err:    fmt.Sprintf(labgo.ERROR_DELTA_OFFSET_INVALID, 7, 6),
+		},
+		{
+			name: "RemoveInvalid",
+			delta: &labgo.Delta{
+				Offset: 4,
+				Remove: []byte("bar"),
+			},
+			length: 6,
+			err:    fmt.Sprintf(labgo.ERROR_DELTA_REMOVE_INVALID, 7, 6),
+		},
+		{
+			name: "RemoveChunkOverflow",
+			delta: &labgo.Delta{
+				Offset: 4,
+				RemoveChunk: []*labgo.ChunkReference{
+					&labgo.ChunkReference{
+						Length: math.MaxUint64 - 2,
+					},
+				},
+			},
+			length: 6,
+			err:    fmt.Sprintf(labgo.ERROR_DELTA_REMOVE_INVALID, uint64(math.MaxUint64), 6),
+		},
+		{
+			name: "RemoveChunksOverflow",
+			delta: &labgo.Delta{
+				RemoveChunk: []*labgo.ChunkReference{
+					&labgo.ChunkReference{
+						Length: math.MaxUint64,
+					},
+					&labgo.ChunkReference{
+						Length: 2,
+					},
+				},
+			},
+			length: 6,
+			err:    fmt.Sprintf(labgo.ERROR_DELTA_REMOVE_INVALID, uint64(math.MaxUint64), 6),
+		},
+		{
+			name: "SnapshotPartOverflow",
+			delta: &labgo.Delta{
+				Offset: 4,
+				AddChunk: []*labgo.ChunkReference{
+					&labgo.ChunkReference{
+						Length: math.MaxUint64 - 2,
+					},
+				},
+				Snapshot: &labgo.Snapshot{
+					Length: 6,
+				},
+			},
+			length: 6,
+			err:    fmt.Sprintf(labgo.ERROR_SNAPSHOT_PART_INVALID, 4, uint64(math.MaxUint64-2), 0, 6),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := labgo.ValidateDelta(tt.delta, tt.length)
+			if tt.err == "" {
+				testinggo.AssertNoError(t, err)
+			} else {
+				testinggo.AssertError(t, tt.err, err)
+			}
+		})
+	}
+}
+
+func TestValidatePath(t *testing.T) {
+	tests := []struct {
+		name string
+		path []string
+		err  string
+	}{
+		{
+			name: "Valid",
+			path: []string{"foo", "bar.go"},
+		},
+		{
+			name: "Empty",
+			err:  labgo.ERROR_PATH_EMPTY,
+		},
+		{
+			name: "Absolute",
+			path: []string{"", "home", "alice", "foo"},
+			err:  fmt.Sprintf(labgo.ERROR_PATH_ABSOLUTE, "/home/alice/foo"),
+		},
+		{
+			name: "Parent",
+			path: []string{"foo", "..", "..", "bar"},
+			err:  fmt.Sprintf(labgo.ERROR_PATH_SEGMENT_INVALID, ".."),
+		},
+		{
+			name: "Separator",
+			path: []string{"foo", "../bar"},
+			err:  fmt.Sprintf(labgo.ERROR_PATH_SEGMENT_INVALID, "../bar"),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := labgo.ValidatePath(tt.path)
+			if tt.err == "" {
+				testinggo.AssertNoError(t, err)
+			} else {
+				testinggo.AssertError(t, tt.err, err)
+			}
+		})
+	}
+}
+
+func TestValidateDraw(t *testing.T) {
+	tests := []struct {
+		name string
+		draw *labgo.Draw
+		err  string
+	}{
+		{
+			name: "Valid",
+			draw: &labgo.Draw{
+				Size:   labgo.MAX_DRAW_SIZE,
+				Points: []int32{-labgo.MAX_DRAW_COORDINATE, 0, 0, labgo.MAX_DRAW_COORDINATE},
+			},
+		},
+		{
+			name: "Unpaired",
+			draw: &labgo.Draw{
+				Points: []int32{0, 0, 1},
+			},
+			err: fmt.Sprintf(labgo.ERROR_DRAW_POINTS_INVALID, 3),
+		},
+		{
+			name: "TooMany",
+			draw: &labgo.Draw{
+				Points: make([]int32, labgo.MAX_DRAW_POINTS+2),
+			},
+			err: fmt.Sprintf(labgo.ERROR_DRAW_POINTS_TOO_MANY, labgo.MAX_DRAW_POINTS+2, labgo.MAX_DRAW_POINTS),
+		},
+		{
+			name: "SizeTooLarge",
+			draw: &labgo.Draw{
+				Size:   4294967295,
+				Points: []int32{0, 0},
+			},
+			err: fmt.Sprintf(labgo.ERROR_DRAW_SIZE_TOO_LARGE, uint32(4294967295), labgo.MAX_DRAW_SIZE),
+		},
+		{
+			name: "CoordinateTooLarge",
+			draw: &labgo.Draw{
+				Points: []int32{-2147483648, -2147483648},
+			},
+			err: fmt.Sprintf(labgo.ERROR_DRAW_COORDINATE_LARGE, -2147483648, labgo.MAX_DRAW_COORDINATE),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := labgo.ValidateDraw(tt.draw)
+			if tt.err == "" {
+				testinggo.AssertNoError(t, err)
+			} else {
+				testinggo.AssertError(t, tt.err, err)
+			}
+		})
+	}
+}
+
+func TestDeltaValidator(t *testing.T) {
+	node := makeNode(t, "Alice")
+	for name, acl := range map[string]map[string]*rsa.PublicKey{
+		"Public": nil,
+		"Encrypted": {
+			node.Alias: &node.Key.PublicKey,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			channel := labgo.OpenFileChannel(node, name, labgo.THRESHOLD_DEFAULT)
+			_, err := labgo.WriteProto(node, nil, channel, acl, nil, &labgo.Delta{
+				Add: []byte("foobar"),
+			})
+			testinggo.AssertNoError(t, err)
+			_, err = labgo.WriteProto(node, nil, channel, acl, nil, &labgo.Delta{
+				Offset: 3,
+				Remove: []byte("barfoo"),
+			})
+			testinggo.AssertError(t, fmt.Sprintf(bcgo.ERROR_CHAIN_INVALID, fmt.Sprintf(labgo.ERROR_DELTA_REMOVE_INVALID, 9, 6)), err)
+		})
+	}
+}
+
+func TestPathValidator(t *testing.T) {
+	node := makeNode(t, "Alice")
+	for name, acl := range map[string]map[string]*rsa.PublicKey{
+		"Public": nil,
+		"Encrypted": {
+			node.Alias: &node.Key.PublicKey,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			channel := labgo.OpenPathChannel(node, name)
+			_, err := labgo.WriteProto(node, nil, channel, acl, nil, &labgo.Path{
+				Path: []string{"foo"},
+			})
+			testinggo.AssertNoError(t, err)
+			_, err = labgo.WriteProto(node, nil, channel, acl, nil, &labgo.Path{
+				Path: []string{"..", "foo"},
+			})
+			testinggo.AssertError(t, fmt.Sprintf(bcgo.ERROR_CHAIN_INVALID, fmt.Sprintf(labgo.ERROR_PATH_SEGMENT_INVALID, "..")), err)
+			_, err = labgo.WriteProto(node, nil, channel, acl, nil, &labgo.Path{
+				Deleted: true,
+			})
+			testinggo.AssertError(t, fmt.Sprintf(bcgo.ERROR_CHAIN_INVALID, labgo.ERROR_PATH_DELETE_INVALID), err)
+			// Rejected records are not mined again with later records
+			_, err = labgo.WriteProto(node, nil, channel, acl, nil, &labgo.Path{
+				Path: []string{"bar"},
+			})
+			testinggo.AssertNoError(t, err)
+		})
+	}
+}
+
+func TestOpenChannel(t *testing.T) {
+	// Maps channel name to expected number of validators
+	for name, validators := range map[string]int{
+		labgo.LAB_PREFIX_CHAT + "foobar":     3,
+		labgo.LAB_PREFIX_CHUNK + "foobar":    2,
+		labgo.LAB_PREFIX_DRAW + "foobar":     3,
+		labgo.LAB_PREFIX_FILE + "foobar":     2,
+		labgo.LAB_PREFIX_MEMBER + "foobar":   2,
+		labgo.LAB_PREFIX_PATH + "foobar":     3,
+		labgo.LAB_PREFIX_SETTINGS + "foobar": 1,
+	} {
+		channel, err := labgo.OpenChannel(nil, name, labgo.THRESHOLD_DEFAULT)
+		testinggo.AssertNoError(t, err)
+		if channel.Name != name {
+			t.Fatalf("Incorrect name; expected '%s', got '%s'", name, channel.Name)
+		}
+		if len(channel.Validators) != validators {
+			t.Fatalf("Incorrect validators for %s; expected '%d', got '%d'", name, validators, len(channel.Validators))
+		}
+	}
+	_, err := labgo.OpenChannel(nil, labgo.LAB_PREFIX+"foobar", labgo.THRESHOLD_DEFAULT)
+	testinggo.AssertError(t, fmt.Sprintf(labgo.ERROR_CHANNEL_UNRECOGNIZED, labgo.LAB_PREFIX+"foobar"), err)
+}
@@ -0,0 +1,177 @@
+/*
+ * Copyright 2020 Aletheia Ware LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package labgo
+
+import (
+	"bytes"
+	"crypto/rsa"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"github.com/AletheiaWareLLC/aliasgo"
+	"github.com/AletheiaWareLLC/bcgo"
+	"github.com/AletheiaWareLLC/cryptogo"
+	"log"
+	"sync"
+)
+
+const (
+	ERROR_ALIAS_UNKNOWN       = "Record created by unknown alias %s: %s"
+	ERROR_RECORD_HASH_INVALID = "Record does not match hash: %s"
+	ERROR_SIGNATURE_INVALID   = "Record signature invalid for %s: %s"
+)
+
+// Verification determines how records are checked when they are read.
+type Verification int
+
+const (
+	// Trust every record
+	VERIFICATION_NONE Verification = iota
+	// Log records which fail verification, but still apply them
+	VERIFICATION_REPORT
+	// Refuse to apply records which fail verification
+	VERIFICATION_REFUSE
+)
+
+// verifier holds a node's verification mode, along with the public keys of the aliases it has looked up.
+type verifier struct {
+	sync.Mutex
+	mode Verification
+	keys map[string]*rsa.PublicKey
+}
+
+// SetVerification sets how the records read by the node are checked against the public keys of their creators, registered through aliasgo.
+// Records are trusted by default.
+func SetVerification(node *Node, mode Verification) {
+	node.verifier.Lock()
+	defer node.verifier.Unlock()
+	node.verifier.mode = mode
+}
+
+// VerifyRecord ensures the record matches its hash, and was signed by the registered public key of its creator.
+func VerifyRecord(node *Node, hash []byte, record *bcgo.Record) error {
+	return node.verifier.verify(node, hash, record)
+}
+
+func (v *verifier) getMode() Verification {
+	v.Lock()
+	defer v.Unlock()
+	return v.mode
+}
+
+func (v *verifier) verify(node *Node, hash []byte, record *bcgo.Record) error {
+	h, err := cryptogo.HashProtobuf(record)
+	if err != nil {
+		return err
+	}
+	encoded := base64.RawURLEncoding.EncodeToString(hash)
+	if !bytes.Equal(h, hash) {
+		return errors.New(fmt.Sprintf(ERROR_RECORD_HASH_INVALID, encoded))
+	}
+	key, err := v.publicKey(node, record.Creator)
+	if err != nil {
+		return errors.New(fmt.Sprintf(ERROR_ALIAS_UNKNOWN, record.Creator, encoded))
+	}
+	if err := cryptogo.VerifySignature(key, cryptogo.Hash(record.Payload), record.Signature, record.SignatureAlgorithm); err != nil {
+		return errors.New(fmt.Sprintf(ERROR_SIGNATURE_INVALID, record.Creator, encoded))
+	}
+	return nil
+}
+
+// publicKey returns the public key registered for the alias, looking it up in the alias channel the first time.
+func (v *verifier) publicKey(node *Node, alias string) (*rsa.PublicKey, error) {
+	v.Lock()
+	defer v.Unlock()
+	if key, ok := v.keys[alias]; ok {
+		return key, nil
+	}
+	key, err := aliasgo.GetPublicKey(getAliasChannel(node), node.Cache, node.Network, alias)
+	if err != nil {
+		return nil, err
+	}
+	v.keys[alias] = key
+	return key, nil
+}
+
+// verifyEntry checks the record in the entry according to the node's verification mode.
+func verifyEntry(node *Node, entry *bcgo.BlockEntry) error {
+	mode := node.verifier.getMode()
+	if mode == VERIFICATION_NONE {
+		return nil
+	}
+	if err := node.verifier.verify(node, entry.RecordHash, entry.Record); err != nil {
+		if mode == VERIFICATION_REFUSE {
+			return err
+		}
+		log.Println(err)
+	}
+	return nil
+}
+
+// VerifyExperiment calls the callback with each record in the experiment's chat, draw, member, path, settings, file, and chunk channels, along with the result of verifying it.
+// Records are verified as stored, so records the node cannot decrypt are verified too.
+func VerifyExperiment(node *Node, experiment *Experiment, callback func(string, []byte, *bcgo.Record, error) error) error {
+	channels := []*bcgo.Channel{
+		experiment.Chat,
+		experiment.Draw,
+		experiment.Member,
+		experiment.Path,
+		experiment.Settings,
+	}
+	// Maps record hash to file ID
+	ids := make(map[string]string)
+	files := make(map[string]bool)
+	if err := bcgo.IterateChronologically(experiment.Path.Name, experiment.Path.Head, nil, node.Cache, node.Network, func(hash []byte, block *bcgo.Block) error {
+		for _, entry := range block.Entry {
+			if id := fileId(ids, experiment.Path.Name, entry); !files[id] {
+				files[id] = true
+				channels = append(channels, GetFileChannel(node, id))
+			}
+		}
+		return nil
+	}); err != nil {
+		return err
+	}
+	threshold, err := GetThreshold(node.Cache, node.Network, experiment.ID)
+	if err != nil {
+		return err
+	}
+	chunks := make(map[string]bool)
+	for _, channel := range channels[len(channels)-len(files):] {
+		if err := iterateChunkReferences(channel.Name, channel.Head, node.Cache, node.Network, func(r *ChunkReference) {
+			if id := base64.RawURLEncoding.EncodeToString(r.Hash); !chunks[id] {
+				chunks[id] = true
+				channels = append(channels, getChunkChannel(node, threshold, r.Hash))
+			}
+		}); err != nil {
+			return err
+		}
+	}
+	for _, channel := range channels {
+		if err := bcgo.IterateChronologically(channel.Name, channel.Head, nil, node.Cache, node.Network, func(hash []byte, block *bcgo.Block) error {
+			for _, entry := range block.Entry {
+				if err := callback(channel.Name, entry.RecordHash, entry.Record, node.verifier.verify(node, entry.RecordHash, entry.Record)); err != nil {
+					return err
+				}
+			}
+			return nil
+		}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
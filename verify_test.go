@@ -0,0 +1,156 @@
+/*
+ * Copyright 2020 Aletheia Ware LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package labgo_test
+
+import (
+	"encoding/base64"
+	"fmt"
+	"github.com/AletheiaWareLLC/bcgo"
+	"github.com/AletheiaWareLLC/cryptogo"
+	"github.com/AletheiaWareLLC/labgo"
+	"github.com/AletheiaWareLLC/testinggo"
+	"io/ioutil"
+	"strings"
+	"testing"
+)
+
+func TestVerifyRecord(t *testing.T) {
+	cache := bcgo.NewMemoryCache(10)
+	alice := makeRegisteredNode(t, "Alice", cache)
+	bob := makeNode(t, "Bob")
+	t.Run("Valid", func(t *testing.T) {
+		hash, record, err := labgo.ProtoToRecord(alice.Alias, alice.Key, 1, nil, &labgo.Chat{Text: "Hello"})
+		testinggo.AssertNoError(t, err)
+		testinggo.AssertNoError(t, labgo.VerifyRecord(alice, hash, record))
+	})
+	t.Run("HashInvalid", func(t *testing.T) {
+		hash, record, err := labgo.ProtoToRecord(alice.Alias, alice.Key, 1, nil, &labgo.Chat{Text: "Hello"})
+		testinggo.AssertNoError(t, err)
+		record.Payload = []byte("Goodbye")
+		testinggo.AssertError(t, fmt.Sprintf(labgo.ERROR_RECORD_HASH_INVALID, base64.RawURLEncoding.EncodeToString(hash)), labgo.VerifyRecord(alice, hash, record))
+	})
+	t.Run("SignatureInvalid", func(t *testing.T) {
+		_, record, err := labgo.ProtoToRecord(alice.Alias, alice.Key, 1, nil, &labgo.Chat{Text: "Hello"})
+		testinggo.AssertNoError(t, err)
+		record.Payload = []byte("Goodbye")
+		hash, err := cryptogo.HashProtobuf(record)
+		testinggo.AssertNoError(t, err)
+		testinggo.AssertError(t, fmt.Sprintf(labgo.ERROR_SIGNATURE_INVALID, "Alice", base64.RawURLEncoding.EncodeToString(hash)), labgo.VerifyRecord(alice, hash, record))
+	})
+	t.Run("ImpersonatedAlias", func(t *testing.T) {
+		// Signed by Bob, claiming to be Alice
+		hash, record, err := labgo.ProtoToRecord(alice.Alias, bob.Key, 1, nil, &labgo.Chat{Text: "Hello"})
+		testinggo.AssertNoError(t, err)
+		testinggo.AssertError(t, fmt.Sprintf(labgo.ERROR_SIGNATURE_INVALID, "Alice", base64.RawURLEncoding.EncodeToString(hash)), labgo.VerifyRecord(alice, hash, record))
+	})
+	t.Run("UnknownAlias", func(t *testing.T) {
+		hash, record, err := labgo.ProtoToRecord(bob.Alias, bob.Key, 1, nil, &labgo.Chat{Text: "Hello"})
+		testinggo.AssertNoError(t, err)
+		testinggo.AssertError(t, fmt.Sprintf(labgo.ERROR_ALIAS_UNKNOWN, "Bob", base64.RawURLEncoding.EncodeToString(hash)), labgo.VerifyRecord(alice, hash, record))
+	})
+}
+
+func TestSetVerification(t *testing.T) {
+	cache := bcgo.NewMemoryCache(10)
+	alice := makeRegisteredNode(t, "Alice", cache)
+	// Bob is not registered
+	bob := makeNode(t, "Bob")
+	bob.Cache = cache
+	experiment, err := labgo.CreateFromReader(bob, nil, nil, labgo.THRESHOLD_LAN, "foo.txt", ioutil.NopCloser(strings.NewReader("foobar")))
+	testinggo.AssertNoError(t, err)
+	opened, err := labgo.Open(alice, experiment.ID)
+	testinggo.AssertNoError(t, err)
+	t.Run("None", func(t *testing.T) {
+		labgo.SetVerification(alice, labgo.VERIFICATION_NONE)
+		_, err := labgo.ListFiles(alice, opened.Path)
+		testinggo.AssertNoError(t, err)
+	})
+	t.Run("Report", func(t *testing.T) {
+		labgo.SetVerification(alice, labgo.VERIFICATION_REPORT)
+		defer labgo.SetVerification(alice, labgo.VERIFICATION_NONE)
+		_, err := labgo.ListFiles(alice, opened.Path)
+		testinggo.AssertNoError(t, err)
+	})
+	t.Run("Refuse", func(t *testing.T) {
+		labgo.SetVerification(alice, labgo.VERIFICATION_REFUSE)
+		defer labgo.SetVerification(alice, labgo.VERIFICATION_NONE)
+		_, err := labgo.ListFiles(alice, opened.Path)
+		if err == nil || !strings.HasPrefix(err.Error(), "Record created by unknown alias Bob") {
+			t.Fatalf("Expected unknown alias error, got '%v'", err)
+		}
+	})
+}
+
+func TestVerifyExperiment(t *testing.T) {
+	cache := bcgo.NewMemoryCache(10)
+	alice := makeRegisteredNode(t, "Alice", cache)
+	bob := makeNode(t, "Bob")
+	bob.Cache = cache
+	experiment, err := labgo.CreateFromReader(alice, nil, nil, labgo.THRESHOLD_LAN, "foo.txt", ioutil.NopCloser(strings.NewReader("foobar")))
+	testinggo.AssertNoError(t, err)
+	// Bob's alias is not registered, so is recorded as a member directly
+	_, err = labgo.WriteProto(alice, nil, experiment.Member, nil, nil, &labgo.Member{
+		Alias: "Bob",
+		Role:  labgo.Role_VIEWER,
+	})
+	testinggo.AssertNoError(t, err)
+	opened, err := labgo.Open(bob, experiment.ID)
+	testinggo.AssertNoError(t, err)
+	_, err = labgo.PostChat(bob, nil, opened.Chat, opened.Access, "Hello Alice")
+	testinggo.AssertNoError(t, err)
+	records := make(map[string]int)
+	var failures []string
+	testinggo.AssertNoError(t, labgo.VerifyExperiment(alice, opened, func(channel string, hash []byte, record *bcgo.Record, err error) error {
+		records[channel]++
+		if err != nil {
+			failures = append(failures, err.Error())
+		}
+		return nil
+	}))
+	// Chat, Member, Path, Settings, and File
+	if len(records) != 5 {
+		t.Fatalf("Incorrect channels; expected '%d', got '%v'", 5, records)
+	}
+	if len(failures) != 1 || !strings.HasPrefix(failures[0], "Record created by unknown alias Bob") {
+		t.Fatalf("Incorrect failures; got '%v'", failures)
+	}
+}
+
+func TestVerifyExperiment_Encrypted(t *testing.T) {
+	cache := bcgo.NewMemoryCache(10)
+	alice := makeRegisteredNode(t, "Alice", cache)
+	bob := makeRegisteredNode(t, "Bob", cache)
+	experiment, err := labgo.CreateFromReader(alice, nil, []string{"Alice"}, labgo.THRESHOLD_LAN, "foo.txt", ioutil.NopCloser(strings.NewReader("foobar")))
+	testinggo.AssertNoError(t, err)
+	// Bob cannot read the records written before he was invited
+	testinggo.AssertNoError(t, labgo.Invite(alice, nil, experiment, "Bob", labgo.Role_VIEWER, false))
+	opened, err := labgo.Open(bob, experiment.ID)
+	testinggo.AssertNoError(t, err)
+	records := make(map[string]int)
+	testinggo.AssertNoError(t, labgo.VerifyExperiment(bob, opened, func(channel string, hash []byte, record *bcgo.Record, err error) error {
+		testinggo.AssertNoError(t, err)
+		records[channel]++
+		return nil
+	}))
+	// Member, Path, Settings, and File
+	if len(records) != 4 {
+		t.Fatalf("Incorrect channels; expected '%d', got '%v'", 4, records)
+	}
+	if records[opened.Settings.Name] != 1 {
+		t.Fatalf("Incorrect settings records; expected '%d', got '%d'", 1, records[opened.Settings.Name])
+	}
+}
@@ -0,0 +1,338 @@
+/*
+ * Copyright 2020 Aletheia Ware LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package labgo
+
+import (
+	"context"
+	"crypto/rsa"
+	"errors"
+	"fmt"
+	"github.com/AletheiaWareLLC/bcgo"
+	"github.com/AletheiaWareLLC/cryptogo"
+	"github.com/golang/protobuf/proto"
+	"sync"
+)
+
+const (
+	// Number of nonces tried between checks for cancellation while mining
+	MINING_CANCEL_INTERVAL = 1024
+
+	ERROR_WRITER_CLOSED = "Writer closed"
+)
+
+// WriteListener is notified as records written through a Writer are queued, mined, and pushed to peers, or fail, as well as of the progress of mining.
+type WriteListener interface {
+	bcgo.MiningListener
+	// OnQueued is called when a record is queued to be mined into the channel.
+	OnQueued(channel *bcgo.Channel, record []byte)
+	// OnMined is called when the queued records are mined into a block, and the channel updated.
+	OnMined(channel *bcgo.Channel, hash []byte, block *bcgo.Block)
+	// OnPushed is called when the channel is pushed to peers after mining.
+	// If peers are unreachable the channel is added to the node's outbox instead, and OnPushed is not called.
+	OnPushed(channel *bcgo.Channel, hash []byte)
+	// OnFailed is called when the queued records could not be mined.
+	OnFailed(channel *bcgo.Channel, records [][]byte, err error)
+}
+
+// Writer mines the records written through it on background workers, so callers are not blocked while mining.
+// Records are queued per channel and mined in order, channels are mined concurrently by up to the given number of workers.
+// Cancelling the context stops any mining in progress, and fails every queued record; records remain in the cache so they can be mined later.
+// The Writer holds the node's update lock while writing to the node's cache, as blocks broadcast by peers are applied, but pushes channels to peers without it; callers reading the cache while writing must use a cache safe for concurrent use.
+type Writer struct {
+	sync.Mutex
+	cond     *sync.Cond
+	ctx      context.Context
+	cancel   context.CancelFunc
+	node     *Node
+	listener WriteListener
+	queues   map[string]*writerQueue
+	ready    []*writerQueue
+	pending  int
+	err      error
+	closed   bool
+	workers  sync.WaitGroup
+}
+
+type writerQueue struct {
+	channel *bcgo.Channel
+	entries []*bcgo.BlockEntry
+	// True while the channel is waiting for, or held by, a worker
+	scheduled bool
+}
+
+// NewWriter starts the given number of workers mining the records written through the returned Writer, until it is closed or the context is cancelled.
+func NewWriter(ctx context.Context, node *Node, listener WriteListener, workers int) *Writer {
+	ctx, cancel := context.WithCancel(ctx)
+	w := &Writer{
+		ctx:      ctx,
+		cancel:   cancel,
+		node:     node,
+		listener: listener,
+		queues:   make(map[string]*writerQueue),
+	}
+	w.cond = sync.NewCond(w)
+	go func() {
+		// Wake workers and waiters when cancelled
+		<-ctx.Done()
+		w.Lock()
+		w.cond.Broadcast()
+		w.Unlock()
+	}()
+	if workers < 1 {
+		workers = 1
+	}
+	for i := 0; i < workers; i++ {
+		w.workers.Add(1)
+		go w.work()
+	}
+	return w
+}
+
+// WriteProto writes the protobuf to the channel in a new record, encrypted for the aliases in the access list unless it is empty, and queues the record to be mined.
+// The hash of the record is returned without waiting for it to be mined.
+func (w *Writer) WriteProto(channel *bcgo.Channel, acl map[string]*rsa.PublicKey, references []*bcgo.Reference, protobuf proto.Message) ([]byte, error) {
+	// Marshal Protobuf
+	data, err := proto.Marshal(protobuf)
+	if err != nil {
+		return nil, err
+	}
+	w.Lock()
+	closed := w.closed
+	w.Unlock()
+	if closed {
+		return nil, errors.New(ERROR_WRITER_CLOSED)
+	}
+	if err := w.ctx.Err(); err != nil {
+		return nil, err
+	}
+	entry, err := createEntry(w.node, channel, acl, references, data)
+	if err != nil {
+		return nil, err
+	}
+	w.Lock()
+	defer w.Unlock()
+	if w.closed {
+		return nil, errors.New(ERROR_WRITER_CLOSED)
+	}
+	if err := w.ctx.Err(); err != nil {
+		// Workers may have stopped
+		return nil, err
+	}
+	q, ok := w.queues[channel.Name]
+	if !ok {
+		q = &writerQueue{
+			channel: channel,
+		}
+		w.queues[channel.Name] = q
+	}
+	q.entries = append(q.entries, entry)
+	w.pending++
+	if !q.scheduled {
+		q.scheduled = true
+		w.ready = append(w.ready, q)
+		w.cond.Signal()
+	}
+	if w.listener != nil {
+		w.listener.OnQueued(channel, entry.RecordHash)
+	}
+	return entry.RecordHash, nil
+}
+
+// Wait blocks until every queued record has been mined and pushed, or has failed, and returns the first failure since the last call.
+func (w *Writer) Wait() error {
+	w.Lock()
+	defer w.Unlock()
+	for w.pending > 0 {
+		w.cond.Wait()
+	}
+	err := w.err
+	w.err = nil
+	return err
+}
+
+// Close stops accepting records, waits for the queued records to be mined and pushed, and stops the workers.
+func (w *Writer) Close() error {
+	w.Lock()
+	w.closed = true
+	w.cond.Broadcast()
+	w.Unlock()
+	w.workers.Wait()
+	w.cancel()
+	w.Lock()
+	defer w.Unlock()
+	return w.err
+}
+
+// work mines the queued records of each ready channel until the writer is closed or cancelled.
+func (w *Writer) work() {
+	defer w.workers.Done()
+	for {
+		w.Lock()
+		for len(w.ready) == 0 && !w.closed && w.ctx.Err() == nil {
+			w.cond.Wait()
+		}
+		if len(w.ready) == 0 {
+			// Closed or cancelled, with nothing left to mine
+			w.Unlock()
+			return
+		}
+		q := w.ready[0]
+		w.ready = w.ready[1:]
+		// Take as many records as fit in a block
+		count := 0
+		size := uint64(0)
+		for count < len(q.entries) {
+			s := uint64(proto.Size(q.entries[count]))
+			if count > 0 && size+s > MAX_BATCH_SIZE {
+				break
+			}
+			size += s
+			count++
+		}
+		entries := q.entries[:count]
+		q.entries = q.entries[count:]
+		w.Unlock()
+
+		err := w.mine(q.channel, entries)
+
+		w.Lock()
+		if err != nil {
+			if w.err == nil {
+				w.err = err
+			}
+			if w.ctx.Err() != nil {
+				// Cancelled, fail the remaining records too
+				entries = append(entries, q.entries...)
+				q.entries = nil
+			}
+			if w.listener != nil {
+				var records [][]byte
+				for _, e := range entries {
+					records = append(records, e.RecordHash)
+				}
+				w.listener.OnFailed(q.channel, records, err)
+			}
+		}
+		w.pending -= len(entries)
+		if len(q.entries) > 0 {
+			w.ready = append(w.ready, q)
+		} else {
+			q.scheduled = false
+		}
+		w.cond.Broadcast()
+		w.Unlock()
+	}
+}
+
+// mine mines the entries into a new block of the channel, and pushes the channel to peers.
+func (w *Writer) mine(channel *bcgo.Channel, entries []*bcgo.BlockEntry) error {
+	hash, block, err := mineBlock(w.ctx, w.node, w.listener, channel, entries)
+	if err != nil {
+		return err
+	}
+	if w.listener != nil {
+		w.listener.OnMined(channel, hash, block)
+	}
+	pushed, err := pushChannel(w.node, channel)
+	if err != nil {
+		return err
+	}
+	if pushed && w.listener != nil {
+		w.listener.OnPushed(channel, hash)
+	}
+	return nil
+}
+
+// mineBlock mines the entries into a new block on top of the channel's head, and updates the channel with it.
+// The node's update lock is held while reading and updating the channel, but not while mining, so other channels are not held up.
+func mineBlock(ctx context.Context, node *Node, listener bcgo.MiningListener, channel *bcgo.Channel, entries []*bcgo.BlockEntry) ([]byte, *bcgo.Block, error) {
+	block := &bcgo.Block{
+		Timestamp:   bcgo.Timestamp(),
+		ChannelName: channel.Name,
+		Length:      1,
+		Miner:       node.Alias,
+		Entry:       entries,
+	}
+	node.update.Lock()
+	if channel.Head != nil {
+		previous, err := node.Cache.GetBlock(channel.Head)
+		if err != nil {
+			node.update.Unlock()
+			return nil, nil, err
+		}
+		block.Length = previous.Length + 1
+		block.Previous = channel.Head
+	}
+	threshold, err := ChannelThreshold(node.Cache, node.Network, channel)
+	node.update.Unlock()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	hash, err := MineBlock(ctx, channel, threshold, listener, block)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	node.update.Lock()
+	defer node.update.Unlock()
+	if err := channel.Update(node.Cache, node.Network, hash, block); err != nil {
+		return nil, nil, err
+	}
+	return hash, block, nil
+}
+
+// MineBlock searches for a nonce which gives the block a hash with more ones than the threshold, as bcgo.Node.MineBlock does, but stops when the context is cancelled.
+// The channel is not updated.
+func MineBlock(ctx context.Context, channel *bcgo.Channel, threshold uint64, listener bcgo.MiningListener, block *bcgo.Block) ([]byte, error) {
+	size := uint64(proto.Size(block))
+	if size > bcgo.MAX_BLOCK_SIZE_BYTES {
+		return nil, errors.New(fmt.Sprintf(bcgo.ERROR_BLOCK_TOO_LARGE, bcgo.BinarySizeToString(size), bcgo.BinarySizeToString(bcgo.MAX_BLOCK_SIZE_BYTES)))
+	}
+
+	if listener != nil {
+		listener.OnMiningStarted(channel, size)
+	}
+
+	var max uint64
+	for nonce := uint64(1); nonce > 0; nonce++ {
+		if nonce%MINING_CANCEL_INTERVAL == 0 {
+			if err := ctx.Err(); err != nil {
+				return nil, err
+			}
+		}
+		block.Nonce = nonce
+		hash, err := cryptogo.HashProtobuf(block)
+		if err != nil {
+			return nil, err
+		}
+		ones := bcgo.Ones(hash)
+		if ones > max {
+			if listener != nil {
+				listener.OnNewMaxOnes(channel, nonce, ones)
+			}
+			max = ones
+		}
+		if ones > threshold {
+			if listener != nil {
+				listener.OnMiningThresholdReached(channel, hash, block)
+			}
+			return hash, nil
+		}
+	}
+	return nil, errors.New(bcgo.ERROR_NONCE_WRAP_AROUND)
+}
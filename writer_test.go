@@ -0,0 +1,136 @@
+/*
+ * Copyright 2020 Aletheia Ware LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package labgo_test
+
+import (
+	"context"
+	"fmt"
+	"github.com/AletheiaWareLLC/bcgo"
+	"github.com/AletheiaWareLLC/labgo"
+	"github.com/AletheiaWareLLC/testinggo"
+	"sync"
+	"testing"
+)
+
+type recordingListener struct {
+	sync.Mutex
+	queued, mined, pushed, failed int
+}
+
+func (l *recordingListener) OnMiningStarted(channel *bcgo.Channel, size uint64) {}
+
+func (l *recordingListener) OnNewMaxOnes(channel *bcgo.Channel, nonce, ones uint64) {}
+
+func (l *recordingListener) OnMiningThresholdReached(channel *bcgo.Channel, hash []byte, block *bcgo.Block) {
+}
+
+func (l *recordingListener) OnQueued(channel *bcgo.Channel, record []byte) {
+	l.Lock()
+	defer l.Unlock()
+	l.queued++
+}
+
+func (l *recordingListener) OnMined(channel *bcgo.Channel, hash []byte, block *bcgo.Block) {
+	l.Lock()
+	defer l.Unlock()
+	l.mined += len(block.Entry)
+}
+
+func (l *recordingListener) OnPushed(channel *bcgo.Channel, hash []byte) {
+	l.Lock()
+	defer l.Unlock()
+	l.pushed++
+}
+
+func (l *recordingListener) OnFailed(channel *bcgo.Channel, records [][]byte, err error) {
+	l.Lock()
+	defer l.Unlock()
+	l.failed += len(records)
+}
+
+func TestWriter(t *testing.T) {
+	node := makeNode(t, "Alice")
+	experiment, err := labgo.CreateFromReader(node, nil, nil, labgo.THRESHOLD_LAN, "", nil)
+	testinggo.AssertNoError(t, err)
+	chat := experiment.Chat
+	path := experiment.Path
+	listener := &recordingListener{}
+	writer := labgo.NewWriter(context.Background(), node, listener, 2)
+	for i := 0; i < 5; i++ {
+		_, err := writer.WriteProto(chat, nil, nil, &labgo.Chat{
+			Text: fmt.Sprintf("Message %d", i),
+		})
+		testinggo.AssertNoError(t, err)
+	}
+	_, err = writer.WriteProto(path, nil, nil, &labgo.Path{
+		Path: []string{"foo.txt"},
+	})
+	testinggo.AssertNoError(t, err)
+	testinggo.AssertNoError(t, writer.Wait())
+	if listener.queued != 6 || listener.mined != 6 || listener.failed != 0 {
+		t.Fatalf("Incorrect progress; expected '6' queued and mined, got '%d' queued, '%d' mined, '%d' failed", listener.queued, listener.mined, listener.failed)
+	}
+	if _, entries := countBlocks(t, node, chat); entries != 5 {
+		t.Fatalf("Incorrect chat entries; expected '5', got '%d'", entries)
+	}
+	if _, entries := countBlocks(t, node, path); entries != 1 {
+		t.Fatalf("Incorrect path entries; expected '1', got '%d'", entries)
+	}
+	testinggo.AssertNoError(t, writer.Close())
+	_, err = writer.WriteProto(chat, nil, nil, &labgo.Chat{
+		Text: "After Close",
+	})
+	testinggo.AssertError(t, labgo.ERROR_WRITER_CLOSED, err)
+}
+
+func TestWriter_Invalid(t *testing.T) {
+	node := makeNode(t, "Alice")
+	listener := &recordingListener{}
+	writer := labgo.NewWriter(context.Background(), node, listener, 1)
+	defer writer.Close()
+	_, err := writer.WriteProto(labgo.OpenPathChannel(node, "foobar"), nil, nil, &labgo.Path{
+		Path: []string{"..", "foo"},
+	})
+	testinggo.AssertNoError(t, err)
+	testinggo.AssertError(t, fmt.Sprintf(bcgo.ERROR_CHAIN_INVALID, fmt.Sprintf(labgo.ERROR_PATH_SEGMENT_INVALID, "..")), writer.Wait())
+	if listener.failed != 1 {
+		t.Fatalf("Incorrect failures; expected '1', got '%d'", listener.failed)
+	}
+}
+
+func TestWriter_Cancel(t *testing.T) {
+	node := makeNode(t, "Alice")
+	ctx, cancel := context.WithCancel(context.Background())
+	writer := labgo.NewWriter(ctx, node, nil, 1)
+	cancel()
+	_, err := writer.WriteProto(labgo.OpenChatChannel(node, "foobar"), nil, nil, &labgo.Chat{
+		Text: "Hello",
+	})
+	testinggo.AssertError(t, context.Canceled.Error(), err)
+	testinggo.AssertNoError(t, writer.Close())
+}
+
+func TestMineBlock_Cancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	channel := labgo.OpenChatChannel(nil, "foobar")
+	// No hash has more ones than it has bits, so mining only stops when cancelled
+	_, err := labgo.MineBlock(ctx, channel, 512, nil, &bcgo.Block{
+		ChannelName: channel.Name,
+	})
+	testinggo.AssertError(t, context.Canceled.Error(), err)
+}